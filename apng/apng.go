@@ -0,0 +1,410 @@
+// Package apng は画像をアニメーション PNG (APNG) 形式で出力する。
+// GIF とは違い、フレームを 256 色に減色せずにそのまま保存できる。
+package apng
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+)
+
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// PNG の色の種類
+const (
+	colorTypeRGB      = 2
+	colorTypePaletted = 3
+	colorTypeRGBA     = 6
+	bitDepth          = 8
+)
+
+// フレームの表示時間の分母と、分子の最大値
+const (
+	delayMilliseconds = 1000
+	delayCentiseconds = 100
+	maxDelayNumerator = 0xffff
+)
+
+// フレームの合成方法と、次のフレームを描く前の処理
+const (
+	blendOpSource = 0
+	blendOpOver   = 1
+	disposeOpNone = 0
+)
+
+// 透明な画素が存在しないことを表す
+const transparentInvalid = -1
+
+var (
+	errNoFrames     = errors.New("apng: no frames")
+	errFrameSize    = errors.New("apng: all frames must be the same size")
+	errDelayLengths = errors.New("apng: mismatched image and delay lengths")
+)
+
+// APNG はアニメーション PNG の各フレームと表示時間。
+type APNG struct {
+	Image     []image.Image
+	Delay     []int // 各フレームの表示時間 (ミリ秒)
+	LoopCount int   // ループ回数。0 の場合は無限にループする
+}
+
+// frame は書き出すフレーム。
+type frame struct {
+	pix   []byte // 画像全体の画素
+	delay int
+}
+
+// encoder はフレームを書き出すときの色の形式。
+type encoder struct {
+	w         io.Writer
+	width     int
+	height    int
+	colorType byte
+	bpp       int // 1画素のバイト数
+	palette   color.Palette
+	// 透明な画素の値。存在しない場合は transparentInvalid
+	transparent int
+	seq         uint32
+}
+
+// EncodeAll は a を APNG として w に出力する。
+//
+// 全てのフレームが同じパレットのパレット画像の場合はパレット形式で、それ以外は
+// RGB か RGBA 形式で出力する。
+// 連続する同じフレームは1つにまとめ、2フレーム目以降は前のフレームから変化した
+// 範囲だけを書き出す。
+func EncodeAll(w io.Writer, a *APNG) error {
+	if len(a.Image) < 1 {
+		return errNoFrames
+	}
+	if len(a.Image) != len(a.Delay) {
+		return errDelayLengths
+	}
+	size := a.Image[0].Bounds().Size()
+	for _, img := range a.Image {
+		if img.Bounds().Size() != size {
+			return errFrameSize
+		}
+	}
+
+	e := newEncoder(w, a.Image)
+	var frames []frame
+	for j, img := range a.Image {
+		pix := e.pixels(img)
+		if 0 < len(frames) && bytes.Equal(frames[len(frames)-1].pix, pix) {
+			frames[len(frames)-1].delay += a.Delay[j]
+			continue
+		}
+		frames = append(frames, frame{pix: pix, delay: a.Delay[j]})
+	}
+
+	if _, err := io.WriteString(w, pngSignature); err != nil {
+		return err
+	}
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr, uint32(e.width))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(e.height))
+	ihdr[8] = bitDepth
+	ihdr[9] = e.colorType
+	if err := e.writeChunk("IHDR", ihdr); err != nil {
+		return err
+	}
+	actl := make([]byte, 8)
+	binary.BigEndian.PutUint32(actl, uint32(len(frames)))
+	binary.BigEndian.PutUint32(actl[4:], uint32(max(a.LoopCount, 0)))
+	if err := e.writeChunk("acTL", actl); err != nil {
+		return err
+	}
+	if err := e.writePalette(); err != nil {
+		return err
+	}
+
+	var prev []byte
+	for j, f := range frames {
+		rect, blend, pix := e.region(prev, f.pix)
+		if err := e.writeFrameControl(rect, f.delay, blend); err != nil {
+			return err
+		}
+		data, err := e.compress(pix, rect.Dx(), rect.Dy())
+		if err != nil {
+			return err
+		}
+		// 1フレーム目は APNG に対応していないビューアでも表示できるように
+		// IDAT に書き出す
+		if j == 0 {
+			err = e.writeChunk("IDAT", data)
+		} else {
+			err = e.writeChunk("fdAT", append(e.nextSequence(), data...))
+		}
+		if err != nil {
+			return err
+		}
+		prev = f.pix
+	}
+	return e.writeChunk("IEND", nil)
+}
+
+func newEncoder(w io.Writer, imgs []image.Image) *encoder {
+	size := imgs[0].Bounds().Size()
+	e := &encoder{
+		w:           w,
+		width:       size.X,
+		height:      size.Y,
+		transparent: transparentInvalid,
+	}
+
+	if pal, ok := sharedPalette(imgs); ok {
+		e.colorType = colorTypePaletted
+		e.bpp = 1
+		e.palette = pal
+		for j, c := range pal {
+			if _, _, _, a := c.RGBA(); a == 0 {
+				e.transparent = j
+				break
+			}
+		}
+		return e
+	}
+
+	e.colorType = colorTypeRGB
+	e.bpp = 3
+	for _, img := range imgs {
+		if !opaque(img) {
+			e.colorType = colorTypeRGBA
+			e.bpp = 4
+			e.transparent = 0
+			break
+		}
+	}
+	return e
+}
+
+// sharedPalette は全てのフレームが同じパレットのパレット画像の場合に、その
+// パレットを返す。
+func sharedPalette(imgs []image.Image) (color.Palette, bool) {
+	var pal color.Palette
+	for j, img := range imgs {
+		p, ok := img.(*image.Paletted)
+		if !ok || 256 < len(p.Palette) {
+			return nil, false
+		}
+		if j == 0 {
+			pal = p.Palette
+			continue
+		}
+		if len(pal) != len(p.Palette) {
+			return nil, false
+		}
+		for k := range pal {
+			if pal[k] != p.Palette[k] {
+				return nil, false
+			}
+		}
+	}
+	return pal, true
+}
+
+func opaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xffff {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// pixels は img の画素を書き出す形式のバイト列に変換する。
+func (e *encoder) pixels(img image.Image) []byte {
+	b := img.Bounds()
+	if e.colorType == colorTypePaletted {
+		p := img.(*image.Paletted)
+		pix := make([]byte, 0, e.width*e.height)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			i := p.PixOffset(b.Min.X, y)
+			pix = append(pix, p.Pix[i:i+e.width]...)
+		}
+		return pix
+	}
+
+	nrgba := image.NewNRGBA(image.Rect(0, 0, e.width, e.height))
+	draw.Draw(nrgba, nrgba.Rect, img, b.Min, draw.Src)
+	if e.colorType == colorTypeRGBA {
+		return nrgba.Pix
+	}
+	pix := make([]byte, 0, e.width*e.height*3)
+	for j := 0; j < len(nrgba.Pix); j += 4 {
+		pix = append(pix, nrgba.Pix[j:j+3]...)
+	}
+	return pix
+}
+
+// region は前のフレーム prev から cur に変化した範囲と、その範囲の合成方法と
+// 画素を返す。
+//
+// 変化した画素が全て不透明な場合は、変化していない画素を透明にして前の
+// フレームに重ねる。同じ色が続いて圧縮しやすくなる。
+func (e *encoder) region(prev, cur []byte) (image.Rectangle, byte, []byte) {
+	full := image.Rect(0, 0, e.width, e.height)
+	if prev == nil {
+		return full, blendOpSource, cur
+	}
+
+	var (
+		rect    image.Rectangle
+		canOver = e.transparent != transparentInvalid
+	)
+	for y := 0; y < e.height; y++ {
+		for x := 0; x < e.width; x++ {
+			i := (y*e.width + x) * e.bpp
+			if bytes.Equal(prev[i:i+e.bpp], cur[i:i+e.bpp]) {
+				continue
+			}
+			rect = rect.Union(image.Rect(x, y, x+1, y+1))
+			canOver = canOver && e.isOpaque(cur[i:i+e.bpp])
+		}
+	}
+	// 同じフレームはまとめているので変化がないことはないが、念のため
+	// 1画素だけ書き出す
+	if rect.Empty() {
+		rect = image.Rect(0, 0, 1, 1)
+		canOver = false
+	}
+
+	blend := byte(blendOpSource)
+	if canOver {
+		blend = blendOpOver
+	}
+	pix := make([]byte, 0, rect.Dx()*rect.Dy()*e.bpp)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			i := (y*e.width + x) * e.bpp
+			if canOver && bytes.Equal(prev[i:i+e.bpp], cur[i:i+e.bpp]) {
+				pix = append(pix, e.transparentPixel()...)
+				continue
+			}
+			pix = append(pix, cur[i:i+e.bpp]...)
+		}
+	}
+	return rect, blend, pix
+}
+
+func (e *encoder) isOpaque(p []byte) bool {
+	switch e.colorType {
+	case colorTypePaletted:
+		_, _, _, a := e.palette[p[0]].RGBA()
+		return a == 0xffff
+	case colorTypeRGBA:
+		return p[3] == 0xff
+	}
+	return true
+}
+
+func (e *encoder) transparentPixel() []byte {
+	if e.colorType == colorTypePaletted {
+		return []byte{byte(e.transparent)}
+	}
+	return make([]byte, e.bpp)
+}
+
+func (e *encoder) writePalette() error {
+	if e.colorType != colorTypePaletted {
+		return nil
+	}
+	var (
+		plte = make([]byte, 0, 3*len(e.palette))
+		trns = make([]byte, 0, len(e.palette))
+		last = -1
+	)
+	for j, c := range e.palette {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		plte = append(plte, n.R, n.G, n.B)
+		trns = append(trns, n.A)
+		if n.A != 0xff {
+			last = j
+		}
+	}
+	if err := e.writeChunk("PLTE", plte); err != nil {
+		return err
+	}
+	if last < 0 {
+		return nil
+	}
+	return e.writeChunk("tRNS", trns[:last+1])
+}
+
+func (e *encoder) writeFrameControl(rect image.Rectangle, delay int, blend byte) error {
+	// 分母が 1000 で表現できない長さは 1/100 秒単位にする
+	num, den := max(delay, 0), delayMilliseconds
+	if maxDelayNumerator < num {
+		num, den = min(num/10, maxDelayNumerator), delayCentiseconds
+	}
+
+	fctl := append(e.nextSequence(), make([]byte, 22)...)
+	binary.BigEndian.PutUint32(fctl[4:], uint32(rect.Dx()))
+	binary.BigEndian.PutUint32(fctl[8:], uint32(rect.Dy()))
+	binary.BigEndian.PutUint32(fctl[12:], uint32(rect.Min.X))
+	binary.BigEndian.PutUint32(fctl[16:], uint32(rect.Min.Y))
+	binary.BigEndian.PutUint16(fctl[20:], uint16(num))
+	binary.BigEndian.PutUint16(fctl[22:], uint16(den))
+	fctl[24] = disposeOpNone
+	fctl[25] = blend
+	return e.writeChunk("fcTL", fctl)
+}
+
+// nextSequence は fcTL と fdAT で共通の連番を返す。
+func (e *encoder) nextSequence() []byte {
+	b := binary.BigEndian.AppendUint32(nil, e.seq)
+	e.seq++
+	return b
+}
+
+// compress は幅 w 高さ h の画素をフィルタして圧縮する。
+func (e *encoder) compress(pix []byte, w, h int) ([]byte, error) {
+	var (
+		buf    bytes.Buffer
+		stride = w * e.bpp
+		prior  = make([]byte, stride)
+	)
+	zw := zlib.NewWriter(&buf)
+	for y := 0; y < h; y++ {
+		row := pix[y*stride : (y+1)*stride]
+		if _, err := zw.Write(filter(row, prior, e.bpp, e.colorType == colorTypePaletted)); err != nil {
+			return nil, err
+		}
+		prior = row
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (e *encoder) writeChunk(tag string, data []byte) error {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+	copy(header[4:], tag)
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+
+	if _, err := e.w.Write(header); err != nil {
+		return err
+	}
+	if _, err := e.w.Write(data); err != nil {
+		return err
+	}
+	_, err := e.w.Write(binary.BigEndian.AppendUint32(nil, crc.Sum32()))
+	return err
+}
@@ -0,0 +1,260 @@
+package apng
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type chunk struct {
+	tag  string
+	data []byte
+}
+
+func readChunks(t *testing.T, data []byte) (ret []chunk) {
+	assert.Equal(t, pngSignature, string(data[:8]))
+	data = data[8:]
+	for 12 <= len(data) {
+		n := int(binary.BigEndian.Uint32(data))
+		ret = append(ret, chunk{tag: string(data[4:8]), data: data[8 : 8+n]})
+		data = data[12+n:]
+	}
+	assert.Empty(t, data)
+	return
+}
+
+// decodeFrames は APNG の各フレームを合成した画像と表示時間を返す。
+func decodeFrames(t *testing.T, data []byte) (frames []*image.NRGBA, delays []int, loop int) {
+	var (
+		chunks  = readChunks(t, data)
+		ihdr    []byte
+		palette []chunk
+		canvas  *image.NRGBA
+		fctl    []byte
+	)
+	for _, c := range chunks {
+		switch c.tag {
+		case "IHDR":
+			ihdr = c.data
+			w, h := binary.BigEndian.Uint32(c.data), binary.BigEndian.Uint32(c.data[4:])
+			canvas = image.NewNRGBA(image.Rect(0, 0, int(w), int(h)))
+		case "acTL":
+			loop = int(binary.BigEndian.Uint32(c.data[4:]))
+		case "PLTE", "tRNS":
+			palette = append(palette, c)
+		case "fcTL":
+			fctl = c.data
+		case "IDAT", "fdAT":
+			d := c.data
+			if c.tag == "fdAT" {
+				d = d[4:]
+			}
+			var (
+				w, h  = binary.BigEndian.Uint32(fctl[4:]), binary.BigEndian.Uint32(fctl[8:])
+				x, y  = int(binary.BigEndian.Uint32(fctl[12:])), int(binary.BigEndian.Uint32(fctl[16:]))
+				num   = int(binary.BigEndian.Uint16(fctl[20:]))
+				den   = int(binary.BigEndian.Uint16(fctl[22:]))
+				blend = fctl[25]
+			)
+
+			// フレームだけの PNG にしてデコードする
+			var buf bytes.Buffer
+			e := &encoder{w: &buf}
+			buf.WriteString(pngSignature)
+			h2 := append([]byte{}, ihdr...)
+			binary.BigEndian.PutUint32(h2, w)
+			binary.BigEndian.PutUint32(h2[4:], h)
+			assert.NoError(t, e.writeChunk("IHDR", h2))
+			for _, p := range palette {
+				assert.NoError(t, e.writeChunk(p.tag, p.data))
+			}
+			assert.NoError(t, e.writeChunk("IDAT", d))
+			assert.NoError(t, e.writeChunk("IEND", nil))
+			img, err := png.Decode(&buf)
+			assert.NoError(t, err)
+
+			op := draw.Src
+			if blend == blendOpOver {
+				op = draw.Over
+			}
+			r := image.Rect(x, y, x+int(w), y+int(h))
+			draw.Draw(canvas, r, img, image.Point{}, op)
+
+			f := image.NewNRGBA(canvas.Rect)
+			copy(f.Pix, canvas.Pix)
+			frames = append(frames, f)
+			delays = append(delays, num*1000/den)
+		}
+	}
+	return
+}
+
+func newImage(w, h int, f func(x, y int) color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, f(x, y))
+		}
+	}
+	return img
+}
+
+func toNRGBA(img image.Image) *image.NRGBA {
+	b := img.Bounds()
+	ret := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(ret, ret.Rect, img, b.Min, draw.Src)
+	return ret
+}
+
+func TestEncodeAll(t *testing.T) {
+	var (
+		black = color.NRGBA{A: 255}
+		white = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+		// 文字が1つずつ増えていくフレーム
+		typing = func(n int) *image.NRGBA {
+			return newImage(20, 10, func(x, y int) color.NRGBA {
+				if x < n*4 && 2 <= y && y < 8 && x%4 != 3 {
+					return white
+				}
+				return black
+			})
+		}
+		// 透明な背景に不透明な文字が増えていくフレーム
+		typingClear = func(n int) *image.NRGBA {
+			return newImage(20, 10, func(x, y int) color.NRGBA {
+				if x < n*4 && 2 <= y && y < 8 && x%4 != 3 {
+					return white
+				}
+				return color.NRGBA{}
+			})
+		}
+		gradient = func(shift int) *image.NRGBA {
+			return newImage(16, 16, func(x, y int) color.NRGBA {
+				return color.NRGBA{R: uint8(x*16 + shift), G: uint8(y * 16), B: 128, A: uint8(255 - x*8)}
+			})
+		}
+		pal      = color.Palette{black, white, color.NRGBA{R: 255, A: 255}, color.NRGBA{}}
+		paletted = func(n int) image.Image {
+			p := image.NewPaletted(image.Rect(0, 0, 8, 8), pal)
+			for j := range p.Pix {
+				p.Pix[j] = uint8((j + n) % len(pal))
+			}
+			return p
+		}
+	)
+	tests := []struct {
+		desc          string
+		a             *APNG
+		wantColorType byte
+		wantDelays    []int
+		wantFrames    []int // 期待するフレームの元画像の添字
+		wantBlends    []byte
+	}{
+		{
+			desc:          "正常系: 不透明なフレームは RGB で変化した範囲だけを重ねる",
+			a:             &APNG{Image: []image.Image{typing(1), typing(2), typing(3)}, Delay: []int{100, 200, 300}, LoopCount: 2},
+			wantColorType: colorTypeRGB,
+			wantDelays:    []int{100, 200, 300},
+			wantFrames:    []int{0, 1, 2},
+		},
+		{
+			desc:          "正常系: 半透明のフレームは RGBA",
+			a:             &APNG{Image: []image.Image{gradient(0), gradient(3)}, Delay: []int{50, 70}},
+			wantColorType: colorTypeRGBA,
+			wantDelays:    []int{50, 70},
+			wantFrames:    []int{0, 1},
+		},
+		{
+			desc:          "正常系: 変化した画素が不透明なら変化していない画素を透明にして重ねる",
+			a:             &APNG{Image: []image.Image{typingClear(1), typingClear(3)}, Delay: []int{100, 100}},
+			wantColorType: colorTypeRGBA,
+			wantDelays:    []int{100, 100},
+			wantFrames:    []int{0, 1},
+			wantBlends:    []byte{blendOpSource, blendOpOver},
+		},
+		{
+			desc:          "正常系: 同じフレームはまとめる",
+			a:             &APNG{Image: []image.Image{typing(1), typing(1), typing(2)}, Delay: []int{100, 100, 100}},
+			wantColorType: colorTypeRGB,
+			wantDelays:    []int{200, 100},
+			wantFrames:    []int{0, 2},
+		},
+		{
+			desc:          "正常系: 同じパレットのフレームはパレット形式",
+			a:             &APNG{Image: []image.Image{paletted(0), paletted(1)}, Delay: []int{100, 100}},
+			wantColorType: colorTypePaletted,
+			wantDelays:    []int{100, 100},
+			wantFrames:    []int{0, 1},
+		},
+		{
+			desc:          "正常系: 長い表示時間",
+			a:             &APNG{Image: []image.Image{typing(1)}, Delay: []int{100000}},
+			wantColorType: colorTypeRGB,
+			wantDelays:    []int{100000},
+			wantFrames:    []int{0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			var buf bytes.Buffer
+			err := EncodeAll(&buf, tt.a)
+			assert.NoError(err)
+
+			// 1フレーム目は通常の PNG としても読める
+			first, err := png.Decode(bytes.NewReader(buf.Bytes()))
+			assert.NoError(err)
+			assert.Equal(toNRGBA(tt.a.Image[0]).Pix, toNRGBA(first).Pix)
+
+			chunks := readChunks(t, buf.Bytes())
+			assert.Equal(tt.wantColorType, chunks[0].data[9])
+
+			if tt.wantBlends != nil {
+				var blends []byte
+				for _, c := range chunks {
+					if c.tag == "fcTL" {
+						blends = append(blends, c.data[25])
+					}
+				}
+				assert.Equal(tt.wantBlends, blends)
+			}
+
+			frames, delays, loop := decodeFrames(t, buf.Bytes())
+			assert.Equal(tt.a.LoopCount, loop)
+			assert.Equal(tt.wantDelays, delays)
+			assert.Len(frames, len(tt.wantFrames))
+			for j, f := range frames {
+				assert.Equal(toNRGBA(tt.a.Image[tt.wantFrames[j]]).Pix, f.Pix)
+			}
+		})
+	}
+}
+
+func TestEncodeAll_error(t *testing.T) {
+	tests := []struct {
+		desc string
+		a    *APNG
+	}{
+		{desc: "異常系: フレームがない", a: &APNG{}},
+		{desc: "異常系: フレームと表示時間の数が違う", a: &APNG{Image: []image.Image{image.NewRGBA(image.Rect(0, 0, 1, 1))}}},
+		{
+			desc: "異常系: フレームの大きさが違う",
+			a: &APNG{
+				Image: []image.Image{image.NewRGBA(image.Rect(0, 0, 1, 1)), image.NewRGBA(image.Rect(0, 0, 2, 1))},
+				Delay: []int{1, 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert.Error(t, EncodeAll(&bytes.Buffer{}, tt.a))
+		})
+	}
+}
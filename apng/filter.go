@@ -0,0 +1,76 @@
+package apng
+
+// PNG のフィルタの種類
+const (
+	filterNone = iota
+	filterSub
+	filterUp
+	filterAverage
+	filterPaeth
+	nFilters
+)
+
+// filter は1行分の画素 row をフィルタした結果を、先頭にフィルタの種類を付けて
+// 返す。prior は1行前の画素。
+// フィルタは差分の絶対値の合計が最も小さくなるものを選ぶ。パレット画像は
+// 差分をとっても意味がないのでフィルタしない。
+func filter(row, prior []byte, bpp int, paletted bool) []byte {
+	if paletted {
+		return append([]byte{filterNone}, row...)
+	}
+
+	var (
+		best    []byte
+		bestSum = -1
+	)
+	for f := filterNone; f < nFilters; f++ {
+		out := make([]byte, len(row)+1)
+		out[0] = byte(f)
+		sum := 0
+		for j, v := range row {
+			var left, upLeft byte
+			if bpp <= j {
+				left, upLeft = row[j-bpp], prior[j-bpp]
+			}
+			up := prior[j]
+			var d byte
+			switch f {
+			case filterNone:
+				d = v
+			case filterSub:
+				d = v - left
+			case filterUp:
+				d = v - up
+			case filterAverage:
+				d = v - byte((int(left)+int(up))/2)
+			case filterPaeth:
+				d = v - paeth(left, up, upLeft)
+			}
+			out[j+1] = d
+			sum += abs(int(int8(d)))
+		}
+		if bestSum < 0 || sum < bestSum {
+			best, bestSum = out, sum
+		}
+	}
+	return best
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -8,7 +8,7 @@ complete -c textimg -s e -l emoji-fontfile -d 'emoji font file.'
 complete -c textimg -s X -l emoji-fontindex
 complete -c textimg -s i -l use-emoji-font -d 'use emoji font'
 complete -c textimg -s z -l shellgei-emoji-fontfile -d 'emoji font file for shellgei-bot'
-complete -c textimg -s F -l fontdize
+complete -c textimg -s F -l fontsize
 complete -c textimg -s o -l out -d 'output image file path.'
 complete -c textimg -s t -l timestamp -d 'add time stamp to output image file path.'
 complete -c textimg -s n -l numbered -d 'add number-suffix to filename when the output file was existed.'
@@ -21,5 +21,57 @@ complete -c textimg -s W -l slide-width -d 'sliding animation width (default 1)'
 complete -c textimg -s E -l forever -d 'sliding forever'
 complete -c textimg      -l environments -d 'print environment variables'
 complete -c textimg      -l slack -d 'resize to slack icon size (128x128 px)'
+complete -c textimg      -l background-image -r -F -d 'background image file path'
+complete -c textimg      -l background-image-mode -x -a 'scale tile center' -d 'how to place background image.'
+complete -c textimg      -l format -x -a 'png apng jpeg gif webp svg pdf html' -d 'output format. overrides the format detected from the extension of output file path.'
+complete -c textimg      -l dither -x -a 'none floyd-steinberg ordered' -d 'dithering method for gif color reduction'
+complete -c textimg      -l global-palette -d 'use a single palette shared across all animation gif frames'
+complete -c textimg      -l loop -x -d 'animation loop count. 0 means infinite'
+complete -c textimg      -l last-frame-delay -x -d 'delay time of the last animation frame. 0 means the same as --delay'
+complete -c textimg      -l frame-separator -x -d 'line prefix that separates animation frames in input instead of "line-count" option.'
+complete -c textimg      -l playback -x -a 'forward reverse pingpong' -d 'playback order of animation frames.'
+complete -c textimg      -l transition -x -a 'none scroll fade wipe' -d 'transition effect between animation frames.'
+complete -c textimg      -l transition-steps -x -d 'number of intermediate frames of transition effect'
+complete -c textimg      -l typewriter -d 'use typewriter animation that shows text little by little with a cursor'
+complete -c textimg      -l type-unit -x -a 'char word token' -d 'unit of text to show at once in typewriter animation.'
+complete -c textimg      -l type-step -x -d 'number of units to show per frame in typewriter animation'
+complete -c textimg      -l newline-pause -x -d 'pause time at the end of lines in typewriter animation. the cursor blinks while pausing'
+complete -c textimg      -l cursor -x -a 'block underline bar none' -d 'cursor style of typewriter animation.'
+complete -c textimg      -l marquee -d 'use marquee animation that scrolls text horizontally through a fixed width viewport'
+complete -c textimg      -l marquee-width -x -d 'viewport width of marquee animation (columns)'
+complete -c textimg      -l marquee-step -x -d 'scroll amount per frame of marquee animation.'
+complete -c textimg      -l html-fragment -d 'output only the <pre> element instead of a standalone html page'
+complete -c textimg      -l html-classes -d 'use css classes instead of inline styles for the 16 palette colors in html'
+complete -c textimg      -l page-rows -x -d 'number of rows per pdf page. 0 puts all rows on a single page'
+complete -c textimg      -l page-margin -x -d 'margin of pdf pages (pt)'
+complete -c textimg      -l embed-font -d 'embed the subset of the font into the svg file'
+complete -c textimg      -l preset -x -a 'slack discord twitter ogp github line' -d 'resize for the platform and fit within its file size limit'
+complete -c textimg      -l resize-width -x -d 'resize width'
+complete -c textimg      -l resize-height -x -d 'resize height'
+complete -c textimg      -l fit -x -a 'fill contain cover pad' -d 'how to keep aspect ratio when resizing'
+complete -c textimg      -l scale -x -d 'render at N times font size, padding and spacing for HiDPI displays'
+complete -c textimg      -l resample -x -a 'nearest bilinear catmullrom lanczos' -d 'resampling filter for resizing'
+complete -c textimg      -l anchor -x -a 'center top bottom left right top-left top-right bottom-left bottom-right' -d 'position to crop or pad when resizing'
+complete -c textimg      -l padding -x -d 'padding of image (px).'
+complete -c textimg      -l line-spacing -x -d 'additional spacing between lines (px)'
+complete -c textimg      -l letter-spacing -x -d 'additional spacing between letters (px)'
+complete -c textimg      -l line-numbers -d 'show line numbers'
+complete -c textimg      -l start-line -x -d 'line number of the first input line'
+complete -c textimg      -l highlight-lines -x -d 'highlight lines.'
+complete -c textimg      -l highlight-color -x -a 'black red green yellow blue magenta cyan white' -d 'color to tint highlighted lines.'
+complete -c textimg      -l lines -x -d 'range of input lines to draw.'
+complete -c textimg      -l columns -x -d 'wrap lines at this display width (columns)'
+complete -c textimg      -l wrap-mode -x -a 'char word none' -d 'how to wrap lines with "columns" option.'
+complete -c textimg      -l truncate -d 'truncate lines with ellipsis instead of wrapping with "columns" option'
+complete -c textimg      -l cols -x -d 'number of columns of canvas like terminal.'
+complete -c textimg      -l rows -x -d 'number of rows of canvas like terminal.'
+complete -c textimg      -l tail -d 'keep the last lines instead of the first lines with "rows" option'
+complete -c textimg      -l split-rows -x -d 'split output into multiple images of N rows each.'
+complete -c textimg      -l frame -x -a 'none mac windows' -d 'window frame style around the image.'
+complete -c textimg      -l title -x -d 'title of window frame'
+complete -c textimg      -l frame-margin -x -d 'margin around window frame (px)'
+complete -c textimg      -l transparent -d 'make background transparent.'
+complete -c textimg      -l keep-ansi-background -d 'keep background colors of escape sequences opaque with "transparent" option'
+complete -c textimg      -l frame-background -x -a 'black red green yellow blue magenta cyan white' -d 'color of margin around window frame.'
 complete -c textimg -s h -l help -d 'help for textimg'
 complete -c textimg -s v -l version -d 'version for textimg'
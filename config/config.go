@@ -3,6 +3,8 @@ package config
 import (
 	"bufio"
 	"fmt"
+	stdimage "image"
+	stdcolor "image/color"
 	"io"
 	"os"
 	"path/filepath"
@@ -11,8 +13,10 @@ import (
 	"time"
 
 	"github.com/jiro4989/textimg/v3/color"
+	"github.com/jiro4989/textimg/v3/image"
 	"github.com/jiro4989/textimg/v3/log"
-	"golang.org/x/image/font"
+	"github.com/jiro4989/textimg/v3/quantize"
+	"github.com/jiro4989/textimg/v3/token"
 	"golang.org/x/term"
 )
 
@@ -20,6 +24,7 @@ type Config struct {
 	Foreground               string // 文字色
 	Background               string // 背景色
 	Outpath                  string // 画像の出力ファイルパス
+	Format                   string // 出力形式。空の場合は出力ファイルパスの拡張子から判定する
 	AddTimeStamp             bool   // ファイル名末尾にタイムスタンプ付与
 	SaveNumberedFile         bool   // 保存しようとしたファイルがすでに存在する場合に連番を付与する
 	FontFile                 string // フォントファイルのパス
@@ -30,25 +35,92 @@ type Config struct {
 	FontSize                 int    // フォントサイズ
 	UseAnimation             bool   // アニメーションGIFを生成する
 	Delay                    int    // アニメーションのディレイ時間
+	Loop                     int    // アニメーションのループ回数。0 の場合は無限にループする
+	LastFrameDelay           int    // アニメーションの最後のフレームのディレイ時間。0 の場合は Delay と同じ
+	Dither                   string // GIF の減色時のディザリングの方法
+	GlobalPalette            bool   // アニメーションGIFの全てのフレームで同じパレットを使う
 	LineCount                int    // 入力データのうち何行を1フレーム画像に使うか
+	FrameSeparator           string // 入力データをアニメーションのフレームに区切る行
+	Transition               string // アニメーションのフレームの切り替え方
+	TransitionSteps          int    // フレームの間に挿入する切り替え途中のフレームの数
+	Playback                 string // アニメーションの再生方法
 	UseSlideAnimation        bool   // スライドアニメーションする
 	SlideWidth               int    // スライドする幅
 	SlideForever             bool   // スライドを無限にスライドするように描画する
+	Typewriter               bool   // 文字が1つずつ現れるアニメーションにする
+	TypeUnit                 string // タイプライターアニメーションで一度に表示する単位
+	TypeStep                 int    // タイプライターアニメーションの1フレームで表示する単位の数
+	NewlinePause             int    // タイプライターアニメーションの行末で止まる時間
+	Cursor                   string // タイプライターアニメーションのカーソルのスタイル
+	Marquee                  bool   // 横にスクロールするマーキーアニメーションにする
+	MarqueeWidth             int    // マーキーアニメーションの表示領域の列数
+	MarqueeStep              string // マーキーアニメーションの1フレームでスクロールする幅
 	ToSlackIcon              bool   // Slackのアイコンサイズにする
 	PrintEnvironments        bool
 	UseShellgeiImagedir      bool
 	UseShellgeiEmojiFontfile bool
-	ResizeWidth              int // 画像の横幅
-	ResizeHeight             int // 画像の縦幅
+	ResizeWidth              int    // 画像の横幅
+	ResizeHeight             int    // 画像の縦幅
+	Fit                      string // リサイズ時の縦横比の扱い
+	Anchor                   string // リサイズ時の基準位置
+	Resample                 string // リサイズ時の補間方法
+	Scale                    int    // 描画倍率
+	Preset                   string // 出力先のサービスに合わせた画像の設定
+	EmbedFont                bool   // SVG にフォントを埋め込む
+	HTMLFragment             bool   // HTML を <pre> 要素だけで出力する
+	HTMLClasses              bool   // HTML の16色の色指定をクラスにする
+	PageRows                 int    // PDF の1ページの行数
+	PageMargin               int    // PDF のページの余白
+	Padding                  string // 画像の余白
+	LineSpacing              int    // 行間
+	LetterSpacing            int    // 文字間
+	Frame                    string // ウィンドウ枠のスタイル
+	Title                    string // ウィンドウ枠のタイトル
+	FrameMargin              int    // ウィンドウ枠の外側の余白
+	FrameBackground          string // ウィンドウ枠の外側の余白の色
+	Transparent              bool   // 背景を透過する
+	KeepANSIBackground       bool   // 透過時もエスケープシーケンスの背景色は描画する
+	BackgroundImageFile      string // 背景画像のファイルパス
+	BackgroundImageMode      string // 背景画像の配置方法
+	LineNumbers              bool   // 行番号を表示する
+	StartLine                int    // 入力の1行目の行番号
+	HighlightLines           string // 強調表示する行番号
+	HighlightColor           string // 強調表示する行に重ねる色
+	Lines                    string // 入力のうち描画する行の範囲
+	Columns                  int    // 折り返す表示幅
+	WrapMode                 string // 折り返し方法
+	Truncate                 bool   // 折り返さずに切り詰める
+	Cols                     int    // キャンバスの列数
+	Rows                     int    // キャンバスの行数
+	Tail                     bool   // 行数を超える場合に末尾の行を残す
+	SplitRows                int    // 出力を分割する1枚あたりの行数。0 の場合は分割しない
 
 	ForegroundColor color.RGBA // 文字色
 	BackgroundColor color.RGBA // 背景色
-	Texts           []string
-	FileExtension   string
-	Writer          io.WriteCloser
-	FontFace        font.Face
-	EmojiFontFace   font.Face
-	EmojiDir        string
+	PaddingTop      int        // 上の余白
+	PaddingRight    int        // 右の余白
+	PaddingBottom   int        // 下の余白
+	PaddingLeft     int        // 左の余白
+
+	MarqueeStepColumns int // マーキーアニメーションの1フレームでスクロールする列数
+	MarqueeStepPixels  int // マーキーアニメーションの1フレームでスクロールする幅 (px)
+
+	FrameBackgroundColor color.RGBA // ウィンドウ枠の外側の余白の色
+	BackgroundGradient   *image.Gradient
+	BackgroundImage      stdimage.Image
+	HighlightLineNumbers []image.LineRange // 強調表示する行の範囲
+	HighlightLineColor   color.RGBA        // 強調表示する行に重ねる色
+	LinesFrom            int               // 描画する最初の行 (1始まり)
+	LinesTo              int               // 描画する最後の行。0の場合は最後まで
+	MaxBytes             int               // 出力するファイルの最大サイズ (byte)。0の場合は制限なし
+	FontData             []byte            // フォントファイルの中身
+	EmojiFontData        []byte            // 絵文字用のフォントファイルの中身
+	Texts                []string
+	Input                io.Reader // SplitRows を指定した場合に逐次読み込む入力
+	FileExtension        string
+	OutputFormat         image.Format // 出力形式
+	Writer               io.WriteCloser
+	EmojiDir             string
 }
 
 type osDefaultFont struct {
@@ -95,63 +167,382 @@ func (a *Config) Adjust(args []string, ev EnvVars) error {
 		a.UseAnimation = true
 	}
 
+	if a.Typewriter {
+		if err := a.adjustTypewriter(); err != nil {
+			return err
+		}
+	}
+
+	if a.Marquee {
+		if err := a.adjustMarquee(); err != nil {
+			return err
+		}
+	}
+
+	if err := a.adjustTransition(); err != nil {
+		return err
+	}
+
+	if a.Playback == "" {
+		a.Playback = image.PlaybackForward
+	}
+	if err := image.ValidatePlayback(a.Playback); err != nil {
+		return err
+	}
+
 	var err error
 	a.ForegroundColor, err = optionColorStringToRGBA(a.Foreground)
 	if err != nil {
 		return err
 	}
 
-	a.BackgroundColor, err = optionColorStringToRGBA(a.Background)
+	a.BackgroundColor, a.BackgroundGradient, err = parseBackground(a.Background)
 	if err != nil {
 		return err
 	}
+	if a.Transparent {
+		a.BackgroundColor = color.RGBA{}
+	}
 
-	// 引数にテキストの指定がなければ標準入力を使用する
-	a.Texts = readInputText(args)
+	a.PaddingTop, a.PaddingRight, a.PaddingBottom, a.PaddingLeft, err = parsePadding(a.Padding)
+	if err != nil {
+		return err
+	}
+	if a.FrameMargin < 0 {
+		return fmt.Errorf("frame margin must not be negative. frame margin = %d", a.FrameMargin)
+	}
 
-	// textsが空のときは警告メッセージを出力して異常終了
-	if err := validateInputText(a.Texts); err != nil {
+	if a.BackgroundImageFile != "" {
+		if a.BackgroundImageMode == "" {
+			a.BackgroundImageMode = image.BackgroundImageModeScale
+		}
+		if err := image.ValidateBackgroundImageMode(a.BackgroundImageMode); err != nil {
+			return err
+		}
+		a.BackgroundImage, err = readImage(a.BackgroundImageFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	if a.Frame == "" {
+		a.Frame = image.FrameStyleNone
+	}
+	if err := image.ValidateFrameStyle(a.Frame); err != nil {
 		return err
 	}
 
-	// スライドアニメーションを使うときはテキストを加工する
-	if a.UseSlideAnimation {
-		a.Texts = toSlideStrings(a.Texts, a.LineCount, a.SlideWidth, a.SlideForever)
+	if a.FrameBackground != "" {
+		a.FrameBackgroundColor, err = optionColorStringToRGBA(a.FrameBackground)
+		if err != nil {
+			return err
+		}
+	}
+
+	a.HighlightLineNumbers, err = parseLineRanges(a.HighlightLines)
+	if err != nil {
+		return err
+	}
+
+	if a.HighlightColor != "" {
+		a.HighlightLineColor, err = optionColorStringToRGBA(a.HighlightColor)
+		if err != nil {
+			return err
+		}
+	}
+
+	if a.WrapMode == "" {
+		a.WrapMode = token.WrapModeChar
+	}
+	if err := token.ValidateWrapMode(a.WrapMode); err != nil {
+		return err
+	}
+
+	a.LinesFrom, a.LinesTo, err = parseLineRange(a.Lines)
+	if err != nil {
+		return err
+	}
+
+	if a.SplitRows != 0 {
+		// 分割する場合は入力を全て読み込まずに、描画しながら逐次読み込む
+		if err := a.adjustSplit(args); err != nil {
+			return err
+		}
+	} else {
+		if err := a.readTexts(args); err != nil {
+			return err
+		}
 	}
 
 	// 拡張子のみ取得
 	a.FileExtension = filepath.Ext(strings.ToLower(a.Outpath))
+	// 出力先の指定がない場合は、形式の指定がなければPNGかGIFとして出力する
+	if a.Outpath == "" && a.Format == "" {
+		if a.UseAnimation {
+			a.FileExtension = ".gif"
+		} else {
+			a.FileExtension = ".png"
+		}
+	}
 
-	if err := a.setWriter(); err != nil {
+	if err := a.setOutputFormat(); err != nil {
 		return err
 	}
 
-	if err := validateFileExtension(a.FileExtension); err != nil {
-		return err
+	if a.Transparent && !a.OutputFormat.Transparency {
+		return fmt.Errorf("%s does not support transparent background. %s", a.FileExtension,
+			image.FormatHint(func(f image.Format) bool { return f.Transparency }))
+	}
+	if a.UseAnimation && !a.OutputFormat.Animation {
+		return fmt.Errorf("%s does not support animation. %s", a.FileExtension, image.FormatHint(isAnimation))
 	}
 
-	a.Texts = normalizeTexts(a.Texts)
+	if a.LineCount < 1 {
+		return fmt.Errorf("line count must be positive. line count = %d", a.LineCount)
+	}
+	if a.Loop < 0 {
+		return fmt.Errorf("loop must be 0 or greater. loop = %d", a.Loop)
+	}
+	if a.LastFrameDelay < 0 {
+		return fmt.Errorf("last frame delay must be positive. last frame delay = %d", a.LastFrameDelay)
+	}
 
-	a.FontFace, err = readFace(a.FontFile, a.FontIndex, float64(a.FontSize))
+	a.FontData, err = readFontFile(a.FontFile, a.FontIndex)
 	if err != nil {
 		return err
 	}
 
 	if a.EmojiFontFile != "" {
-		a.EmojiFontFace, err = readFace(a.EmojiFontFile, a.EmojiFontIndex, float64(a.FontSize))
+		a.EmojiFontData, err = readFontFile(a.EmojiFontFile, a.EmojiFontIndex)
 		if err != nil {
 			return err
 		}
 	}
 
-	if a.ToSlackIcon {
-		a.ResizeWidth = 128
-		a.ResizeHeight = 128
+	if err := a.applyPreset(); err != nil {
+		return err
+	}
+
+	if a.Fit == "" {
+		a.Fit = image.FitFill
+	}
+	if err := image.ValidateFitMode(a.Fit); err != nil {
+		return err
+	}
+	if a.Anchor == "" {
+		a.Anchor = image.AnchorCenter
+	}
+	if err := image.ValidateAnchor(a.Anchor); err != nil {
+		return err
+	}
+	if a.Resample == "" {
+		a.Resample = image.ResampleCatmullRom
+	}
+	if err := image.ValidateResample(a.Resample); err != nil {
+		return err
+	}
+
+	if a.Dither == "" {
+		a.Dither = quantize.DitherFloydSteinberg
+	}
+	if err := quantize.ValidateDither(a.Dither); err != nil {
+		return err
+	}
+
+	if a.OutputFormat.Vector {
+		if err := a.validateVector(); err != nil {
+			return err
+		}
+	}
+
+	// 行数や行間などの描画の設定は描画する前に検証する
+	if err := a.Options().Validate(); err != nil {
+		return err
+	}
+
+	// 設定の誤りで空のファイルが残らないように、出力先のファイルは全ての検証の
+	// 後で作る。分割する場合は画像ごとに出力先のファイルを作る
+	if a.SplitRows == 0 {
+		return a.setWriter()
+	}
+	return nil
+}
+
+// readTexts は入力のテキストを全て読み込む。
+// 行の選択やアニメーションのフレームへの分割は描画する時にする。
+func (a *Config) readTexts(args []string) error {
+	// 引数にテキストの指定がなければ標準入力を使用する
+	a.Texts = readInputText(args)
+
+	// textsが空のときは警告メッセージを出力して異常終了
+	if err := validateInputText(a.Texts); err != nil {
+		return err
+	}
+
+	// 描画する範囲の行が空の場合も、出力先のファイルを作る前に異常終了する
+	if len(a.Texts) < a.LinesFrom {
+		return validateInputText(nil)
+	}
+	to := len(a.Texts)
+	if 0 < a.LinesTo && a.LinesTo < to {
+		to = a.LinesTo
+	}
+	return validateInputText(a.Texts[max(a.LinesFrom, 1)-1 : to])
+}
+
+// adjustSplit は出力を分割する設定を検証して、逐次読み込む入力を設定する。
+// 入力の全ての行が必要なオプションとは併用できない。
+func (a *Config) adjustSplit(args []string) error {
+	if a.SplitRows < 0 {
+		return fmt.Errorf("split rows must be positive. split rows = %d", a.SplitRows)
+	}
+	if a.Outpath == "" {
+		return fmt.Errorf("split rows needs output file path. use -o or -s")
+	}
+	if a.UseAnimation {
+		return fmt.Errorf("split rows can not be used with animation")
+	}
+	if a.Tail {
+		return fmt.Errorf("split rows can not be used with tail")
+	}
+	if a.Lines != "" {
+		return fmt.Errorf("split rows can not be used with lines")
+	}
+	if a.Rows != 0 {
+		return fmt.Errorf("split rows can not be used with rows. split rows sets rows of each image")
+	}
+
+	if len(args) < 1 {
+		a.Input = os.Stdin
+	} else {
+		a.Input = strings.NewReader(strings.Join(args, "\n"))
+	}
+	return nil
+}
+
+// ChunkPath は出力を分割した n 枚目 (1始まり) の画像のファイルパスを返す。
+// 例: out.png -> out_001.png
+func (a *Config) ChunkPath(n int) string {
+	ext := filepath.Ext(a.Outpath)
+	return fmt.Sprintf("%s_%03d%s", strings.TrimSuffix(a.Outpath, ext), n, ext)
+}
+
+// adjustTypewriter はタイプライターアニメーションの設定を検証して、未指定の
+// 値を補う。
+func (a *Config) adjustTypewriter() error {
+	if a.UseSlideAnimation {
+		return fmt.Errorf("typewriter animation can not be used with slide animation")
+	}
+	a.UseAnimation = true
+
+	if a.TypeUnit == "" {
+		a.TypeUnit = image.TypeUnitChar
+	}
+	if err := image.ValidateTypeUnit(a.TypeUnit); err != nil {
+		return err
+	}
+	if a.Cursor == "" {
+		a.Cursor = image.CursorBlock
+	}
+	if err := image.ValidateCursorStyle(a.Cursor); err != nil {
+		return err
 	}
 
+	if a.TypeStep < 0 {
+		return fmt.Errorf("type step must be positive. type step = %d", a.TypeStep)
+	}
+	if a.TypeStep == 0 {
+		a.TypeStep = 1
+	}
+	if a.NewlinePause < 0 {
+		return fmt.Errorf("newline pause must be positive. newline pause = %d", a.NewlinePause)
+	}
 	return nil
 }
 
+// マーキーアニメーションの表示領域の列数の既定値
+const defaultMarqueeWidth = 20
+
+// adjustMarquee はマーキーアニメーションの設定を検証して、未指定の値を補う。
+func (a *Config) adjustMarquee() error {
+	switch {
+	case a.UseSlideAnimation:
+		return fmt.Errorf("marquee animation can not be used with slide animation")
+	case a.Typewriter:
+		return fmt.Errorf("marquee animation can not be used with typewriter animation")
+	}
+	a.UseAnimation = true
+
+	if a.MarqueeWidth < 0 {
+		return fmt.Errorf("marquee width must be positive. marquee width = %d", a.MarqueeWidth)
+	}
+	if a.MarqueeWidth == 0 {
+		a.MarqueeWidth = defaultMarqueeWidth
+	}
+
+	var err error
+	a.MarqueeStepColumns, a.MarqueeStepPixels, err = parseMarqueeStep(a.MarqueeStep)
+	return err
+}
+
+// adjustTransition はアニメーションのフレームの切り替え方を検証して、未指定の
+// 値を補う。
+// 切り替え途中のフレームは行ごとに区切ったフレームの間にだけ作れる。
+func (a *Config) adjustTransition() error {
+	if a.Transition == "" {
+		a.Transition = image.TransitionNone
+	}
+	if err := image.ValidateTransition(a.Transition); err != nil {
+		return err
+	}
+	if a.TransitionSteps < 0 {
+		return fmt.Errorf("transition steps must be positive. transition steps = %d", a.TransitionSteps)
+	}
+	if a.Transition == image.TransitionNone {
+		return nil
+	}
+
+	switch {
+	case a.Typewriter:
+		return fmt.Errorf("transition can not be used with typewriter animation")
+	case a.Marquee:
+		return fmt.Errorf("transition can not be used with marquee animation")
+	}
+	return nil
+}
+
+// validateVector は SVG や PDF で表現できないオプションが指定されていないかを
+// 検証する。
+func (a *Config) validateVector() error {
+	var (
+		opt   string
+		match = isRaster
+	)
+	switch {
+	case a.UseAnimation:
+		opt, match = "animation", isAnimation
+	case a.Frame != image.FrameStyleNone:
+		opt = "frame"
+	case a.BackgroundGradient != nil:
+		opt = "gradient background"
+	case a.BackgroundImageFile != "":
+		opt = "background image"
+	default:
+		return nil
+	}
+	return fmt.Errorf("%s does not support %s. %s", a.FileExtension, opt, image.FormatHint(match))
+}
+
+// isAnimation はアニメーションに対応した出力形式かを判定する。
+func isAnimation(f image.Format) bool {
+	return f.Animation
+}
+
+// isRaster は画像として描画する出力形式かを判定する。
+func isRaster(f image.Format) bool {
+	return !f.Vector && !f.Text
+}
+
 func (a *Config) SetFontFileAndFontIndex(runtimeOS string) {
 	if a.FontFile != "" {
 		return
@@ -250,12 +641,6 @@ func (a *Config) setWriter() error {
 			return fmt.Errorf("no output target error")
 		}
 		a.Writer = os.Stdout
-		if a.UseAnimation {
-			a.FileExtension = ".gif"
-		} else {
-			a.FileExtension = ".png"
-		}
-
 		return nil
 	}
 
@@ -287,33 +672,25 @@ func validateInputText(texts []string) error {
 	return nil
 }
 
-// validateFileExtension はファイル拡張子をチェックする。
-func validateFileExtension(ext string) error {
-	switch ext {
-	case ".png", ".jpg", ".jpeg", ".gif":
-		// 何もしない
-	default:
-		err := fmt.Errorf("%s is not supported extension.", ext)
-		return err
-	}
-	return nil
-}
-
-// normalizeTexts はテキストを正規化する。
-func normalizeTexts(texts []string) []string {
-	result := texts
-
-	// タブ文字は画像描画時に表示されないので暫定対応で半角スペースに置換
-	for i, text := range result {
-		result[i] = strings.Replace(text, "\t", "  ", -1)
+// setOutputFormat は出力形式を設定する。
+// Format を指定した場合は、出力ファイルパスの拡張子よりも優先する。
+func (a *Config) setOutputFormat() error {
+	if a.Format == "" {
+		f, err := image.LookupFormat(a.FileExtension)
+		if err != nil {
+			return fmt.Errorf("%s is not supported extension.", a.FileExtension)
+		}
+		a.OutputFormat = f
+		return nil
 	}
 
-	// ゼロ幅文字を削除
-	for i, text := range result {
-		result[i] = removeZeroWidthCharacters(text)
+	f, err := image.LookupFormat(a.Format)
+	if err != nil {
+		return err
 	}
-
-	return result
+	a.OutputFormat = f
+	a.FileExtension = f.Extensions[0]
+	return nil
 }
 
 func readInputText(args []string) []string {
@@ -345,11 +722,80 @@ func outputImageDir(outDir string, useAnimation bool) (string, error) {
 	return filepath.Join(outDir, "t.png"), nil
 }
 
-// オプション引数のbackgroundは２つの書き方を許容する。
+// readImage は画像ファイルを読み込む。
+func readImage(path string) (stdimage.Image, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	img, _, err := stdimage.Decode(fp)
+	if err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// parseBackground はオプション引数のbackgroundをパースする。
+// 色の指定に加えて、以下のグラデーションの書き方を許容する。
+//  1. 線形グラデーション: linear(#000,#335)
+//     先頭に角度を指定できる: linear(90deg,#000,#335)
+//  2. 円形グラデーション: radial(#335,#000)
+//
+// グラデーションの色は色名称か16進数で指定する。
+// グラデーションの場合は先頭の色を背景色として返す。
+func parseBackground(s string) (color.RGBA, *image.Gradient, error) {
+	var (
+		str  = strings.TrimSpace(strings.ToLower(s))
+		kind string
+	)
+	for _, k := range []string{image.GradientLinear, image.GradientRadial} {
+		if strings.HasPrefix(str, k+"(") && strings.HasSuffix(str, ")") {
+			kind = k
+			str = strings.TrimSuffix(strings.TrimPrefix(str, k+"("), ")")
+			break
+		}
+	}
+	if kind == "" {
+		col, err := optionColorStringToRGBA(s)
+		return col, nil, err
+	}
+
+	g := &image.Gradient{
+		Kind:  kind,
+		Angle: 180,
+	}
+	args := strings.Split(str, ",")
+	if kind == image.GradientLinear && strings.HasSuffix(args[0], "deg") {
+		angle, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(args[0], "deg")), 64)
+		if err != nil {
+			return color.RGBA{}, nil, err
+		}
+		g.Angle = angle
+		args = args[1:]
+	}
+	if len(args) < 2 {
+		return color.RGBA{}, nil, fmt.Errorf("gradient needs 2 or more colors: %s", s)
+	}
+	for _, v := range args {
+		col, err := optionColorStringToRGBA(strings.TrimSpace(v))
+		if err != nil {
+			return color.RGBA{}, nil, err
+		}
+		g.Colors = append(g.Colors, stdcolor.RGBA(col))
+	}
+	return color.RGBA(g.Colors[0]), g, nil
+}
+
+// オプション引数のbackgroundは３つの書き方を許容する。
 //  1. black といった色の直接指定
 //  2. RGBAのカンマ区切り指定
 //     書式: R,G,B,A
 //     赤色の例: 255,0,0,255
+//  3. 16進数での指定
+//     書式: #RGB, #RRGGBB, #RRGGBBAA
+//     赤色の例: #ff0000
 func optionColorStringToRGBA(colstr string) (color.RGBA, error) {
 	// "black"といった色名称でマッチするものがあれば返す
 	colstr = strings.ToLower(colstr)
@@ -359,6 +805,10 @@ func optionColorStringToRGBA(colstr string) (color.RGBA, error) {
 		return col, nil
 	}
 
+	if strings.HasPrefix(colstr, "#") {
+		return hexColorStringToRGBA(colstr)
+	}
+
 	// カンマ区切りでの指定があれば返す
 	rgba := strings.Split(colstr, ",")
 	if len(rgba) != 4 {
@@ -401,67 +851,154 @@ func optionColorStringToRGBA(colstr string) (color.RGBA, error) {
 	return c, nil
 }
 
-// toSlideStrings は文字列をスライドアニメーション用の文字列に変換する。
-func toSlideStrings(src []string, lineCount, slideWidth int, slideForever bool) (ret []string) {
-	if 1 < slideWidth {
-		var loopCount int
-		for i := 0; i < len(src); i += slideWidth {
-			loopCount++
+// parsePadding はオプション引数の余白指定をパースする。
+// CSS の padding と同じように、以下の書き方を許容する。
+//  1. 全辺: 10
+//  2. 上下,左右: 10,20
+//  3. 上,左右,下: 10,20,30
+//  4. 上,右,下,左: 10,20,30,40
+func parsePadding(s string) (top, right, bottom, left int, err error) {
+	if s == "" {
+		return
+	}
+
+	var ns []int
+	for _, v := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return 0, 0, 0, 0, err
 		}
-		for i := 0; i < (loopCount*slideWidth+1)-len(src); i++ {
-			if !slideForever {
-				src = append(src, "")
-			}
+		if n < 0 {
+			return 0, 0, 0, 0, fmt.Errorf("padding must not be negative: %s", s)
 		}
+		ns = append(ns, n)
 	}
 
-	for i := 0; i < len(src); i += slideWidth {
-		n := i + lineCount
-		if len(src) < n {
-			if slideForever {
-				for j := i; j < n; j++ {
-					m := j
-					if len(src) <= m {
-						m -= len(src)
-					}
-					line := src[m]
-					ret = append(ret, line)
-				}
-				continue
-			}
-			return
+	switch len(ns) {
+	case 1:
+		return ns[0], ns[0], ns[0], ns[0], nil
+	case 2:
+		return ns[0], ns[1], ns[0], ns[1], nil
+	case 3:
+		return ns[0], ns[1], ns[2], ns[1], nil
+	case 4:
+		return ns[0], ns[1], ns[2], ns[3], nil
+	}
+	return 0, 0, 0, 0, fmt.Errorf("illegal padding format: %s", s)
+}
+
+// parseMarqueeStep はオプション引数のマーキーアニメーションのスクロール幅を
+// パースする。
+// 以下の書き方を許容する。未指定の場合は1列とする。
+//  1. 列数: 2
+//  2. ピクセル数: 4px
+func parseMarqueeStep(s string) (columns, pixels int, err error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if s == "" {
+		return 1, 0, nil
+	}
+
+	ns, isPixel := strings.CutSuffix(s, "px")
+	n, err := strconv.Atoi(strings.TrimSpace(ns))
+	if err != nil {
+		return 0, 0, err
+	}
+	if n < 1 {
+		return 0, 0, fmt.Errorf("marquee step must be positive: %s", s)
+	}
+	if isPixel {
+		return 0, n, nil
+	}
+	return n, 0, nil
+}
+
+// hexColorStringToRGBA は16進数の色指定をRGBAに変換する。
+func hexColorStringToRGBA(colstr string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(colstr, "#")
+	switch len(hex) {
+	case 3:
+		// #RGB は #RRGGBB の省略形
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		fallthrough
+	case 6:
+		hex += "ff"
+	case 8:
+		// 何もしない
+	default:
+		return color.RGBA{}, fmt.Errorf("illegal hex color format: %s", colstr)
+	}
+
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, err
+	}
+	c := color.RGBA{
+		R: uint8(n >> 24),
+		G: uint8(n >> 16),
+		B: uint8(n >> 8),
+		A: uint8(n),
+	}
+	return c, nil
+}
+
+// parseLineRange は行の範囲指定をパースする。
+// 以下の書き方を許容する。終わりを省略した場合は 0 を返す。
+//  1. 10-40
+//  2. 10- (10行目から最後まで)
+//  3. -40 (1行目から40行目まで)
+//  4. 10 (10行目のみ)
+func parseLineRange(s string) (from, to int, err error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 1, 0, nil
+	}
+
+	fs, ts, found := strings.Cut(s, "-")
+	if !found {
+		ts = fs
+	}
+
+	from = 1
+	if fs != "" {
+		from, err = strconv.Atoi(strings.TrimSpace(fs))
+		if err != nil {
+			return 0, 0, err
 		}
-		// lineCountの数ずつ行を取得して戻り値に追加
-		for j := i; j < n; j++ {
-			line := src[j]
-			ret = append(ret, line)
+	}
+	if ts != "" {
+		to, err = strconv.Atoi(strings.TrimSpace(ts))
+		if err != nil {
+			return 0, 0, err
 		}
 	}
-	return
+
+	if from < 1 || to < 0 || (to != 0 && to < from) {
+		return 0, 0, fmt.Errorf("illegal line range: %s", s)
+	}
+	return from, to, nil
 }
 
-// removeZeroWidthSpace はゼロ幅文字が存在したときに削除する。
-//
-// 参考
-// * ゼロ幅スペース https://ja.wikipedia.org/wiki/%E3%82%BC%E3%83%AD%E5%B9%85%E3%82%B9%E3%83%9A%E3%83%BC%E3%82%B9
-func removeZeroWidthCharacters(s string) string {
-	zwc := []rune{
-		0x200b, // zero width space
-		0x200c, // zero width joiner
-		0x200d, // zero width joiner
-		0xfeff, // zero width no-break-space
-	}
-	var ret []rune
-chars:
-	for _, v := range s {
-		for _, c := range zwc {
-			if v == c {
-				continue chars
-			}
+// parseLineRanges はカンマ区切りの行番号と行の範囲の指定をパースして、行の
+// 範囲の配列を返す。
+// 範囲が広くてもメモリを使わないように、行番号には展開しない。
+// 例: 3,7-9 は [{3 3} {7 9}] になる。
+func parseLineRanges(s string) ([]image.LineRange, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var ret []image.LineRange
+	for _, v := range strings.Split(s, ",") {
+		from, to, err := parseLineRange(v)
+		if err != nil {
+			return nil, err
+		}
+		if to == 0 {
+			return nil, fmt.Errorf("line range must have the end: %s", v)
 		}
-		ret = append(ret, v)
+		ret = append(ret, image.LineRange{From: from, To: to})
 	}
-	return string(ret)
+	return ret, nil
 }
 
 // readStdin は標準入力を文字列の配列として返す。
@@ -1,12 +1,15 @@
 package config
 
 import (
+	stdcolor "image/color"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/jiro4989/textimg/v3/color"
+	"github.com/jiro4989/textimg/v3/image"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -35,6 +38,8 @@ func newDefaultConfig() Config {
 		UseShellgeiEmojiFontfile: false,
 		ResizeWidth:              0,
 		ResizeHeight:             0,
+		StartLine:                1,
+		HighlightColor:           "255,255,0,64",
 		Writer:                   NewMockWriter(false, false),
 	}
 }
@@ -241,6 +246,41 @@ func TestConfig_Adjust(t *testing.T) {
 			}(),
 			wantErr: false,
 		},
+		{
+			desc: "正常系: Transparentが有効な時は背景色が透過色になる",
+			config: func() Config {
+				c := newDefaultConfig()
+				c.Outpath = "t.png"
+				c.Transparent = true
+				return c
+			}(),
+			args: []string{"hello"},
+			ev:   EnvVars{},
+			want: func() Config {
+				c := newDefaultConfig()
+				c.Outpath = "t.png"
+				c.Transparent = true
+				c.ForegroundColor = color.RGBAWhite
+				c.BackgroundColor = color.RGBA{}
+				c.Texts = []string{"hello"}
+				c.FileExtension = ".png"
+				return c
+			}(),
+			wantErr: false,
+		},
+		{
+			desc: "異常系: Transparentが有効な時にJPEGを指定するとエラーを返す",
+			config: func() Config {
+				c := newDefaultConfig()
+				c.Outpath = "t.jpg"
+				c.Transparent = true
+				return c
+			}(),
+			args:    []string{"hello"},
+			ev:      EnvVars{},
+			want:    Config{},
+			wantErr: true,
+		},
 		{
 			desc: "異常系: Foregroundに不正な色指定をした時はエラーを返す",
 			config: func() Config {
@@ -265,6 +305,86 @@ func TestConfig_Adjust(t *testing.T) {
 			want:    Config{},
 			wantErr: true,
 		},
+		{
+			desc: "異常系: LineCountが1未満の時はエラーを返す",
+			config: func() Config {
+				c := newDefaultConfig()
+				c.Outpath = "t.gif"
+				c.UseAnimation = true
+				c.LineCount = 0
+				return c
+			}(),
+			args:    []string{"hello"},
+			ev:      EnvVars{},
+			want:    Config{},
+			wantErr: true,
+		},
+		{
+			desc: "異常系: Loopが負の時はエラーを返す",
+			config: func() Config {
+				c := newDefaultConfig()
+				c.Outpath = "t.gif"
+				c.UseAnimation = true
+				c.Loop = -1
+				return c
+			}(),
+			args:    []string{"hello"},
+			ev:      EnvVars{},
+			want:    Config{},
+			wantErr: true,
+		},
+		{
+			desc: "異常系: 行間で1セルの高さが1未満になる時はエラーを返す",
+			config: func() Config {
+				c := newDefaultConfig()
+				c.Outpath = "t.png"
+				c.LineSpacing = -100
+				return c
+			}(),
+			args:    []string{"hello"},
+			ev:      EnvVars{},
+			want:    Config{},
+			wantErr: true,
+		},
+		{
+			desc: "異常系: 文字間で1セルの幅が1未満になる時はエラーを返す",
+			config: func() Config {
+				c := newDefaultConfig()
+				c.Outpath = "t.png"
+				c.LetterSpacing = -100
+				return c
+			}(),
+			args:    []string{"hello"},
+			ev:      EnvVars{},
+			want:    Config{},
+			wantErr: true,
+		},
+		{
+			desc: "異常系: StartLineが負の時はエラーを返す",
+			config: func() Config {
+				c := newDefaultConfig()
+				c.Outpath = "t.png"
+				c.StartLine = -100
+				return c
+			}(),
+			args:    []string{"hello"},
+			ev:      EnvVars{},
+			want:    Config{},
+			wantErr: true,
+		},
+		{
+			desc: "異常系: FrameMarginが負の時はエラーを返す",
+			config: func() Config {
+				c := newDefaultConfig()
+				c.Outpath = "t.png"
+				c.FrameMargin = -1
+				return c
+			}(),
+			args:    []string{"hello"},
+			ev:      EnvVars{},
+			want:    Config{},
+			wantErr: true,
+		},
 		{
 			desc: "異常系: textsが空の時はエラーを返す",
 			config: func() Config {
@@ -324,6 +444,304 @@ func TestConfig_Adjust(t *testing.T) {
 	}
 }
 
+func TestConfig_AdjustOutputFile(t *testing.T) {
+	tests := []struct {
+		desc     string
+		edit     func(c *Config)
+		wantFile bool
+	}{
+		{desc: "正常系: 出力先のファイルを作る", edit: func(c *Config) {}, wantFile: true},
+		{desc: "異常系: 描画倍率が負の時はファイルを作らない", edit: func(c *Config) { c.Scale = -1 }},
+		{desc: "異常系: フォントサイズが負の時はファイルを作らない", edit: func(c *Config) { c.FontSize = -5 }},
+		{desc: "異常系: 行間で1セルの高さが1未満になる時はファイルを作らない", edit: func(c *Config) { c.LineSpacing = -100 }},
+		{desc: "異常系: ループ回数が負の時はファイルを作らない", edit: func(c *Config) { c.Loop = -1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			c := newDefaultConfig()
+			c.Writer = nil
+			c.Outpath = filepath.Join(t.TempDir(), "t.png")
+			tt.edit(&c)
+
+			err := c.Adjust([]string{"hello"}, EnvVars{})
+			_, statErr := os.Stat(c.Outpath)
+			if !tt.wantFile {
+				assert.Error(err)
+				assert.True(os.IsNotExist(statErr))
+				return
+			}
+			assert.NoError(err)
+			assert.NoError(statErr)
+			assert.NoError(c.Writer.Close())
+		})
+	}
+}
+
+func TestConfig_AdjustFit(t *testing.T) {
+	tests := []struct {
+		desc       string
+		fit        string
+		anchor     string
+		resample   string
+		slack      bool
+		wantFit    string
+		wantAnchor string
+		wantErr    bool
+	}{
+		{desc: "正常系: 未指定の場合は引き伸ばす", wantFit: "fill", wantAnchor: "center"},
+		{desc: "正常系: ToSlackIconが有効な時は縦横比を保って縮小する", slack: true, wantFit: "contain", wantAnchor: "center"},
+		{desc: "正常系: ToSlackIconが有効な時も指定した値を優先する", fit: "cover", anchor: "top-left", slack: true, wantFit: "cover", wantAnchor: "top-left"},
+		{desc: "異常系: 不正なfit", fit: "sushi", wantErr: true},
+		{desc: "異常系: 不正なanchor", anchor: "sushi", wantErr: true},
+		{desc: "異常系: 不正なresample", resample: "sushi", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			c := newDefaultConfig()
+			c.Outpath = "t.png"
+			c.Fit = tt.fit
+			c.Anchor = tt.anchor
+			c.Resample = tt.resample
+			c.ToSlackIcon = tt.slack
+			err := c.Adjust([]string{"hello"}, EnvVars{})
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tt.wantFit, c.Fit)
+			assert.Equal(tt.wantAnchor, c.Anchor)
+		})
+	}
+}
+
+func TestConfig_AdjustDither(t *testing.T) {
+	tests := []struct {
+		desc    string
+		dither  string
+		want    string
+		wantErr bool
+	}{
+		{desc: "正常系: 未指定の場合はFloyd-Steinberg", want: "floyd-steinberg"},
+		{desc: "正常系: ディザリングしない", dither: "none", want: "none"},
+		{desc: "異常系: 不正なdither", dither: "sushi", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			c := newDefaultConfig()
+			c.Outpath = "t.gif"
+			c.Dither = tt.dither
+			err := c.Adjust([]string{"hello"}, EnvVars{})
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tt.want, c.Dither)
+		})
+	}
+}
+
+func TestConfig_AdjustTypewriter(t *testing.T) {
+	tests := []struct {
+		desc     string
+		typeUnit string
+		typeStep int
+		cursor   string
+		want     Config
+		wantErr  bool
+	}{
+		{
+			desc: "正常系: 未指定の場合は1文字ずつブロックのカーソル",
+			want: Config{TypeUnit: "char", TypeStep: 1, Cursor: "block"},
+		},
+		{
+			desc:     "正常系: 単語ごと",
+			typeUnit: "word",
+			typeStep: 3,
+			cursor:   "underline",
+			want:     Config{TypeUnit: "word", TypeStep: 3, Cursor: "underline"},
+		},
+		{desc: "異常系: 不正な単位", typeUnit: "sushi", wantErr: true},
+		{desc: "異常系: 不正なカーソル", cursor: "sushi", wantErr: true},
+		{desc: "異常系: 負の値", typeStep: -1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			c := newDefaultConfig()
+			c.Outpath = "t.gif"
+			c.Typewriter = true
+			c.TypeUnit = tt.typeUnit
+			c.TypeStep = tt.typeStep
+			c.Cursor = tt.cursor
+			err := c.Adjust([]string{"hello"}, EnvVars{})
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.True(c.UseAnimation)
+			assert.Equal(tt.want.TypeUnit, c.TypeUnit)
+			assert.Equal(tt.want.TypeStep, c.TypeStep)
+			assert.Equal(tt.want.Cursor, c.Cursor)
+		})
+	}
+}
+
+func TestConfig_AdjustTransition(t *testing.T) {
+	tests := []struct {
+		desc       string
+		transition string
+		steps      int
+		typewriter bool
+		want       string
+		wantErr    bool
+	}{
+		{desc: "正常系: 未指定の場合は切り替え途中のフレームを作らない", want: "none"},
+		{desc: "正常系: フェード", transition: "fade", steps: 4, want: "fade"},
+		{desc: "正常系: 切り替えない場合はタイプライターアニメーションと併用できる", typewriter: true, want: "none"},
+		{desc: "異常系: 不正なtransition", transition: "sushi", wantErr: true},
+		{desc: "異常系: 負の値", transition: "wipe", steps: -1, wantErr: true},
+		{desc: "異常系: タイプライターアニメーションとは併用できない", transition: "scroll", typewriter: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			c := newDefaultConfig()
+			c.Outpath = "t.gif"
+			c.UseAnimation = true
+			c.Transition = tt.transition
+			c.TransitionSteps = tt.steps
+			c.Typewriter = tt.typewriter
+			err := c.Adjust([]string{"hello"}, EnvVars{})
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tt.want, c.Transition)
+		})
+	}
+}
+
+func TestConfig_setOutputFormat(t *testing.T) {
+	tests := []struct {
+		desc     string
+		config   Config
+		wantName string
+		wantExt  string
+		wantErr  bool
+	}{
+		{desc: "正常系: 拡張子から判定する", config: Config{FileExtension: ".gif"}, wantName: "gif", wantExt: ".gif"},
+		{desc: "正常系: 別名の拡張子", config: Config{FileExtension: ".jpeg"}, wantName: "jpeg", wantExt: ".jpeg"},
+		{desc: "正常系: 形式名を拡張子より優先する", config: Config{FileExtension: ".png", Format: "webp"}, wantName: "webp", wantExt: ".webp"},
+		{desc: "正常系: 形式名に拡張子も使える", config: Config{Format: "HTM"}, wantName: "html", wantExt: ".html"},
+		{desc: "異常系: 未対応の拡張子", config: Config{FileExtension: ".bmp"}, wantErr: true},
+		{desc: "異常系: 拡張子がない", config: Config{FileExtension: ""}, wantErr: true},
+		{desc: "異常系: 未対応の形式名", config: Config{FileExtension: ".png", Format: "bmp"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			err := tt.config.setOutputFormat()
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tt.wantName, tt.config.OutputFormat.Name)
+			assert.Equal(tt.wantExt, tt.config.FileExtension)
+		})
+	}
+}
+
+func TestConfig_adjustSplit(t *testing.T) {
+	tests := []struct {
+		desc    string
+		config  Config
+		args    []string
+		wantErr bool
+	}{
+		{desc: "正常系: 引数のテキストを読み込む", config: Config{SplitRows: 2, Outpath: "t.png"}, args: []string{"1\n2", "3"}},
+		{desc: "異常系: 負の数", config: Config{SplitRows: -1, Outpath: "t.png"}, wantErr: true},
+		{desc: "異常系: 出力先のファイルがない", config: Config{SplitRows: 2}, wantErr: true},
+		{desc: "異常系: アニメーション", config: Config{SplitRows: 2, Outpath: "t.gif", UseAnimation: true}, wantErr: true},
+		{desc: "異常系: 末尾の行を残す", config: Config{SplitRows: 2, Outpath: "t.png", Tail: true}, wantErr: true},
+		{desc: "異常系: 描画する行の範囲", config: Config{SplitRows: 2, Outpath: "t.png", Lines: "1-3"}, wantErr: true},
+		{desc: "異常系: キャンバスの行数", config: Config{SplitRows: 2, Outpath: "t.png", Rows: 3}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			err := tt.config.adjustSplit(tt.args)
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			b, err := io.ReadAll(tt.config.Input)
+			assert.NoError(err)
+			assert.Equal("1\n2\n3", string(b))
+		})
+	}
+}
+
+func TestConfig_ChunkPath(t *testing.T) {
+	tests := []struct {
+		desc    string
+		outpath string
+		n       int
+		want    string
+	}{
+		{desc: "正常系: 拡張子の前に番号を付ける", outpath: "out/t.png", n: 1, want: "out/t_001.png"},
+		{desc: "正常系: 3桁を超える番号", outpath: "t.png", n: 1234, want: "t_1234.png"},
+		{desc: "正常系: 拡張子がない", outpath: "t", n: 12, want: "t_012"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			c := Config{Outpath: tt.outpath}
+			assert.Equal(t, tt.want, c.ChunkPath(tt.n))
+		})
+	}
+}
+
+func TestConfig_validateVector(t *testing.T) {
+	tests := []struct {
+		desc    string
+		config  Config
+		wantErr bool
+	}{
+		{desc: "正常系: SVGで表現できるオプション", config: Config{Frame: "none", LineNumbers: true}},
+		{desc: "異常系: アニメーション", config: Config{Frame: "none", UseAnimation: true}, wantErr: true},
+		{desc: "異常系: ウィンドウ枠", config: Config{Frame: "mac"}, wantErr: true},
+		{desc: "異常系: グラデーション", config: Config{Frame: "none", BackgroundGradient: &image.Gradient{}}, wantErr: true},
+		{desc: "異常系: 背景画像", config: Config{Frame: "none", BackgroundImageFile: "bg.png"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			err := tt.config.validateVector()
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+		})
+	}
+}
+
 func TestOptionColorStringToRGBA(t *testing.T) {
 	type TestData struct {
 		desc   string
@@ -343,6 +761,10 @@ func TestOptionColorStringToRGBA(t *testing.T) {
 		{desc: "0,0,0,255", colstr: "0,0,0,255", expect: color.RGBA{R: 0, G: 0, B: 0, A: 255}},
 		{desc: "255,255,255,255", colstr: "255,255,255,255", expect: color.RGBA{R: 255, G: 255, B: 255, A: 255}},
 		{desc: "0,0,0,0", colstr: "0,0,0,0", expect: color.RGBA{R: 0, G: 0, B: 0, A: 0}},
+		{desc: "#f00", colstr: "#f00", expect: color.RGBA{R: 255, G: 0, B: 0, A: 255}},
+		{desc: "#336699", colstr: "#336699", expect: color.RGBA{R: 0x33, G: 0x66, B: 0x99, A: 255}},
+		{desc: "#33669980", colstr: "#33669980", expect: color.RGBA{R: 0x33, G: 0x66, B: 0x99, A: 0x80}},
+		{desc: "#ABCDEF", colstr: "#ABCDEF", expect: color.RGBA{R: 0xab, G: 0xcd, B: 0xef, A: 255}},
 	}
 	for _, v := range tds {
 		t.Run(v.desc, func(t *testing.T) {
@@ -362,6 +784,8 @@ func TestOptionColorStringToRGBA(t *testing.T) {
 		{desc: "RGBAの書式不正(255以上の値)", colstr: "1,2,3,256"},
 		{desc: "RGBAの書式不正(負の値)", colstr: "-1,2,3,255"},
 		{desc: "RGBAの書式不正(空文字)", colstr: ""},
+		{desc: "16進数の書式不正(桁数)", colstr: "#1234"},
+		{desc: "16進数の書式不正(値に文字が混じっている)", colstr: "#gggggg"},
 	}
 	for _, v := range tds {
 		t.Run(v.desc, func(t *testing.T) {
@@ -371,239 +795,6 @@ func TestOptionColorStringToRGBA(t *testing.T) {
 	}
 }
 
-func TestToSlideStrings(t *testing.T) {
-	type TestData struct {
-		desc                  string
-		src, expect           []string
-		lineCount, slideWidth int
-		slideForever          bool
-	}
-	tds := []TestData{
-		{
-			desc: "2行描画、スライド幅1、無限なし",
-			src:  []string{"1", "2", "3", "4", "5"},
-			expect: []string{
-				"1", "2",
-				"2", "3",
-				"3", "4",
-				"4", "5",
-			},
-			lineCount:    2,
-			slideWidth:   1,
-			slideForever: false,
-		},
-		{
-			desc: "2行描画、スライド幅2、無限なし",
-			src:  []string{"1", "2", "3", "4", "5"},
-			expect: []string{
-				"1", "2",
-				"3", "4",
-				"5", "",
-			},
-			lineCount:    2,
-			slideWidth:   2,
-			slideForever: false,
-		},
-		{
-			desc: "3行描画、スライド幅1、無限なし",
-			src:  []string{"1", "2", "3", "4", "5"},
-			expect: []string{
-				"1", "2", "3",
-				"2", "3", "4",
-				"3", "4", "5",
-			},
-			lineCount:    3,
-			slideWidth:   1,
-			slideForever: false,
-		},
-		{
-			desc: "3行描画、スライド幅2、無限なし、不足あり",
-			src:  []string{"1", "2", "3", "4", "5", "6"},
-			expect: []string{
-				"1", "2", "3",
-				"3", "4", "5",
-				"5", "6", "",
-			},
-			lineCount:    3,
-			slideWidth:   2,
-			slideForever: false,
-		},
-		{
-			desc: "3行描画、スライド幅2、無限なし、不足なし",
-			src:  []string{"1", "2", "3", "4", "5", "6", "7"},
-			expect: []string{
-				"1", "2", "3",
-				"3", "4", "5",
-				"5", "6", "7",
-			},
-			lineCount:    3,
-			slideWidth:   2,
-			slideForever: false,
-		},
-		{
-			desc: "3行描画、スライド幅3、無限なし、不足なし",
-			src:  []string{"1", "2", "3", "4", "5", "6"},
-			expect: []string{
-				"1", "2", "3",
-				"4", "5", "6",
-			},
-			lineCount:    3,
-			slideWidth:   3,
-			slideForever: false,
-		},
-		{
-			desc: "3行描画、スライド幅3、無限なし、不足あり",
-			src:  []string{"1", "2", "3", "4", "5", "6", "7"},
-			expect: []string{
-				"1", "2", "3",
-				"4", "5", "6",
-				"7", "", "",
-			},
-			lineCount:    3,
-			slideWidth:   3,
-			slideForever: false,
-		},
-		{
-			desc: "3行描画、スライド幅3、無限なし、不足あり",
-			src:  []string{"1", "2", "3", "4", "5", "6", "7", "8"},
-			expect: []string{
-				"1", "2", "3",
-				"4", "5", "6",
-				"7", "8", "",
-			},
-			lineCount:    3,
-			slideWidth:   3,
-			slideForever: false,
-		},
-		{
-			desc: "2行描画、スライド幅2、無限あり",
-			src:  []string{"1", "2", "3", "4", "5"},
-			expect: []string{
-				"1", "2",
-				"3", "4",
-				"5", "1",
-			},
-			lineCount:    2,
-			slideWidth:   2,
-			slideForever: true,
-		},
-		{
-			desc: "2行描画、スライド幅2、無限あり",
-			src:  []string{"1", "2", "3", "4", "5", "6"},
-			expect: []string{
-				"1", "2",
-				"3", "4",
-				"5", "6",
-			},
-			lineCount:    2,
-			slideWidth:   2,
-			slideForever: true,
-		},
-		{
-			desc: "3行描画、スライド幅1、無限あり",
-			src:  []string{"1", "2", "3", "4", "5"},
-			expect: []string{
-				"1", "2", "3",
-				"2", "3", "4",
-				"3", "4", "5",
-				"4", "5", "1",
-				"5", "1", "2",
-			},
-			lineCount:    3,
-			slideWidth:   1,
-			slideForever: true,
-		},
-		{
-			desc: "3行描画、スライド幅1、無限あり",
-			src:  []string{"1", "2", "3", "4", "5", "6"},
-			expect: []string{
-				"1", "2", "3",
-				"2", "3", "4",
-				"3", "4", "5",
-				"4", "5", "6",
-				"5", "6", "1",
-				"6", "1", "2",
-			},
-			lineCount:    3,
-			slideWidth:   1,
-			slideForever: true,
-		},
-		{
-			desc: "3行描画、スライド幅2、無限あり",
-			src:  []string{"1", "2", "3", "4", "5"},
-			expect: []string{
-				"1", "2", "3",
-				"3", "4", "5",
-				"5", "1", "2",
-			},
-			lineCount:    3,
-			slideWidth:   2,
-			slideForever: true,
-		},
-		{
-			desc: "3行描画、スライド幅2、無限あり",
-			src:  []string{"1", "2", "3", "4", "5", "6"},
-			expect: []string{
-				"1", "2", "3",
-				"3", "4", "5",
-				"5", "6", "1",
-			},
-			lineCount:    3,
-			slideWidth:   2,
-			slideForever: true,
-		},
-		{
-			desc: "3行描画、スライド幅3、無限あり",
-			src:  []string{"1", "2", "3", "4", "5", "6"},
-			expect: []string{
-				"1", "2", "3",
-				"4", "5", "6",
-			},
-			lineCount:    3,
-			slideWidth:   3,
-			slideForever: true,
-		},
-		{
-			desc: "3行描画、スライド幅3、無限あり",
-			src:  []string{"1", "2", "3", "4", "5", "6", "7"},
-			expect: []string{
-				"1", "2", "3",
-				"4", "5", "6",
-				"7", "1", "2",
-			},
-			lineCount:    3,
-			slideWidth:   3,
-			slideForever: true,
-		},
-	}
-	for _, v := range tds {
-		t.Run(v.desc, func(t *testing.T) {
-			got := toSlideStrings(v.src, v.lineCount, v.slideWidth, v.slideForever)
-			assert.Equal(t, v.expect, got, v.desc)
-		})
-	}
-}
-
-func TestRemoveZeroWidthCharacters(t *testing.T) {
-	type TestData struct {
-		desc   string
-		s      string
-		expect string
-	}
-	tds := []TestData{
-		{desc: "Zero width space (U+200B)が削除される", s: "A\u200bB", expect: "AB"},
-		{desc: "Zero width joiner (U+200C)が削除される", s: "A\u200cB", expect: "AB"},
-		{desc: "Zero width joiner (U+200D)が削除される", s: "A\u200dB", expect: "AB"},
-		{desc: "U+200B ~ U+200Dが削除される", s: "あ\u200bい\u200cう\u200dえ", expect: "あいうえ"},
-	}
-	for _, v := range tds {
-		t.Run(v.desc, func(t *testing.T) {
-			got := removeZeroWidthCharacters(v.s)
-			assert.Equal(t, v.expect, got, v.desc)
-		})
-	}
-}
-
 func TestApplicationConfigSetFontFileAndFontIndex(t *testing.T) {
 	type TestData struct {
 		desc          string
@@ -830,3 +1021,191 @@ func TestApplicationConfig_AddNumberSuffixToOutPath(t *testing.T) {
 		})
 	}
 }
+
+func TestParsePadding(t *testing.T) {
+	tests := []struct {
+		desc    string
+		s       string
+		want    [4]int
+		wantErr bool
+	}{
+		{desc: "正常系: 空文字の場合は余白なし", s: "", want: [4]int{0, 0, 0, 0}},
+		{desc: "正常系: 0 は余白なし", s: "0", want: [4]int{0, 0, 0, 0}},
+		{desc: "正常系: 1つの場合は全辺", s: "10", want: [4]int{10, 10, 10, 10}},
+		{desc: "正常系: 2つの場合は上下と左右", s: "10,20", want: [4]int{10, 20, 10, 20}},
+		{desc: "正常系: 3つの場合は上、左右、下", s: "10,20,30", want: [4]int{10, 20, 30, 20}},
+		{desc: "正常系: 4つの場合は上、右、下、左", s: "10, 20, 30, 40", want: [4]int{10, 20, 30, 40}},
+		{desc: "異常系: 値が多すぎる", s: "1,2,3,4,5", wantErr: true},
+		{desc: "異常系: 数値でない", s: "a", wantErr: true},
+		{desc: "異常系: 負の値", s: "-1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			top, right, bottom, left, err := parsePadding(tt.s)
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tt.want, [4]int{top, right, bottom, left})
+		})
+	}
+}
+
+func TestParseMarqueeStep(t *testing.T) {
+	tests := []struct {
+		desc        string
+		s           string
+		wantColumns int
+		wantPixels  int
+		wantErr     bool
+	}{
+		{desc: "正常系: 未指定の場合は1列", s: "", wantColumns: 1},
+		{desc: "正常系: 列数", s: "3", wantColumns: 3},
+		{desc: "正常系: ピクセル数", s: "4px", wantPixels: 4},
+		{desc: "正常系: 前後の空白と大文字", s: " 4PX ", wantPixels: 4},
+		{desc: "異常系: 0", s: "0", wantErr: true},
+		{desc: "異常系: 負の値", s: "-2px", wantErr: true},
+		{desc: "異常系: 数値でない", s: "sushi", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			columns, pixels, err := parseMarqueeStep(tt.s)
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tt.wantColumns, columns)
+			assert.Equal(tt.wantPixels, pixels)
+		})
+	}
+}
+
+func TestParseBackground(t *testing.T) {
+	tests := []struct {
+		desc         string
+		s            string
+		wantColor    color.RGBA
+		wantGradient *image.Gradient
+		wantErr      bool
+	}{
+		{
+			desc:      "正常系: 色の指定",
+			s:         "red",
+			wantColor: color.RGBARed,
+		},
+		{
+			desc:      "正常系: 線形グラデーション",
+			s:         "linear(#000,#335)",
+			wantColor: color.RGBA{A: 255},
+			wantGradient: &image.Gradient{
+				Kind:   image.GradientLinear,
+				Angle:  180,
+				Colors: []stdcolor.RGBA{{A: 255}, {R: 0x33, G: 0x33, B: 0x55, A: 255}},
+			},
+		},
+		{
+			desc:      "正常系: 角度を指定した線形グラデーション",
+			s:         "linear(90deg, red, green, blue)",
+			wantColor: color.RGBARed,
+			wantGradient: &image.Gradient{
+				Kind:   image.GradientLinear,
+				Angle:  90,
+				Colors: []stdcolor.RGBA{{R: 255, A: 255}, {G: 255, A: 255}, {B: 255, A: 255}},
+			},
+		},
+		{
+			desc:      "正常系: 円形グラデーション",
+			s:         "Radial(#fff,#000)",
+			wantColor: color.RGBAWhite,
+			wantGradient: &image.Gradient{
+				Kind:   image.GradientRadial,
+				Angle:  180,
+				Colors: []stdcolor.RGBA{{R: 255, G: 255, B: 255, A: 255}, {A: 255}},
+			},
+		},
+		{desc: "異常系: 色が1つしかない", s: "linear(#000)", wantErr: true},
+		{desc: "異常系: 色が不正", s: "linear(#000,sushi)", wantErr: true},
+		{desc: "異常系: 角度が不正", s: "linear(adeg,#000,#fff)", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			col, g, err := parseBackground(tt.s)
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tt.wantColor, col)
+			assert.Equal(tt.wantGradient, g)
+		})
+	}
+}
+
+func TestParseLineRange(t *testing.T) {
+	tests := []struct {
+		desc     string
+		s        string
+		wantFrom int
+		wantTo   int
+		wantErr  bool
+	}{
+		{desc: "正常系: 空文字の場合は全行", s: "", wantFrom: 1, wantTo: 0},
+		{desc: "正常系: 範囲指定", s: "10-40", wantFrom: 10, wantTo: 40},
+		{desc: "正常系: 終わりを省略", s: "10-", wantFrom: 10, wantTo: 0},
+		{desc: "正常系: 始まりを省略", s: "-40", wantFrom: 1, wantTo: 40},
+		{desc: "正常系: 1行のみ", s: "3", wantFrom: 3, wantTo: 3},
+		{desc: "異常系: 始まりが終わりより大きい", s: "40-10", wantErr: true},
+		{desc: "異常系: 0行目", s: "0-10", wantErr: true},
+		{desc: "異常系: 数値でない", s: "a-b", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			from, to, err := parseLineRange(tt.s)
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tt.wantFrom, from)
+			assert.Equal(tt.wantTo, to)
+		})
+	}
+}
+
+func TestParseLineRanges(t *testing.T) {
+	tests := []struct {
+		desc    string
+		s       string
+		want    []image.LineRange
+		wantErr bool
+	}{
+		{desc: "正常系: 空文字の場合はなし", s: "", want: nil},
+		{desc: "正常系: 行番号と範囲の組み合わせ", s: "3,7-9", want: []image.LineRange{{From: 3, To: 3}, {From: 7, To: 9}}},
+		{desc: "正常系: 広い範囲も展開しない", s: "1000-1000000000", want: []image.LineRange{{From: 1000, To: 1000000000}}},
+		{desc: "異常系: 終わりのない範囲", s: "3-", wantErr: true},
+		{desc: "異常系: 不正な範囲", s: "3,9-7", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			got, err := parseLineRanges(tt.s)
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tt.want, got)
+		})
+	}
+}
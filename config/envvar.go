@@ -2,7 +2,10 @@ package config
 
 import (
 	"fmt"
+	"io"
 	"os"
+
+	"github.com/jiro4989/textimg/v3/internal/global"
 )
 
 type EnvVars struct {
@@ -12,34 +15,25 @@ type EnvVars struct {
 	EmojiFontFile string
 }
 
-const (
-	envNameOutputDir     = "TEXTIMG_OUTPUT_DIR"
-	envNameFontFile      = "TEXTIMG_FONT_FILE"
-	envNameEmojiDir      = "TEXTIMG_EMOJI_DIR"
-	envNameEmojiFontFile = "TEXTIMG_EMOJI_FONT_FILE"
-)
-
-var (
-	envs = map[string]string{
-		envNameOutputDir:     os.Getenv(envNameOutputDir),
-		envNameFontFile:      os.Getenv(envNameFontFile),
-		envNameEmojiDir:      os.Getenv(envNameEmojiDir),
-		envNameEmojiFontFile: os.Getenv(envNameEmojiFontFile),
-	}
-)
-
+// NewEnvVars は環境変数から EnvVars を生成する。
 func NewEnvVars() EnvVars {
 	return EnvVars{
-		OutputDir:     envs[envNameOutputDir],
-		FontFile:      envs[envNameFontFile],
-		EmojiDir:      envs[envNameEmojiDir],
-		EmojiFontFile: envs[envNameEmojiFontFile],
+		OutputDir:     os.Getenv(global.EnvNameOutputDir),
+		FontFile:      os.Getenv(global.EnvNameFontFile),
+		EmojiDir:      os.Getenv(global.EnvNameEmojiDir),
+		EmojiFontFile: os.Getenv(global.EnvNameEmojiFontFile),
 	}
 }
 
-func PrintEnvs() {
-	for k, v := range envs {
-		text := fmt.Sprintf("%s=%s", k, v)
-		fmt.Println(text)
+// PrintEnvs は textimg が参照する環境変数を常に同じ順番で w に出力する。
+func (e EnvVars) PrintEnvs(w io.Writer) {
+	values := map[string]string{
+		global.EnvNameOutputDir:     e.OutputDir,
+		global.EnvNameFontFile:      e.FontFile,
+		global.EnvNameEmojiDir:      e.EmojiDir,
+		global.EnvNameEmojiFontFile: e.EmojiFontFile,
+	}
+	for _, k := range global.EnvNames {
+		fmt.Fprintf(w, "%s=%s\n", k, values[k])
 	}
 }
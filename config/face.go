@@ -2,58 +2,26 @@ package config
 
 import (
 	"os"
-	"path/filepath"
-	"strings"
 
+	"github.com/jiro4989/textimg/v3/fontfile"
 	"github.com/jiro4989/textimg/v3/log"
-	"golang.org/x/image/font"
 	"golang.org/x/image/font/gofont/gomono"
-	"golang.org/x/image/font/opentype"
 )
 
-// readFace はfontPathのフォントファイルからfaceを返す。
-func readFace(fontPath string, fontIndex int, fontSize float64) (font.Face, error) {
-	var ft *opentype.Font
-
+// readFontFile はfontPathのフォントファイルの中身を返す。
+// フォントコレクションの場合はfontIndex番目のフォントを読み込めるかも検証する。
+func readFontFile(fontPath string, fontIndex int) ([]byte, error) {
 	// ファイルが存在しなければビルトインのフォントをデフォルトとして使う
-	_, err := os.Stat(fontPath)
-	if err == nil {
-		fontData, err := os.ReadFile(fontPath)
-		if err != nil {
-			return nil, err
-		}
-		switch strings.ToLower(filepath.Ext(fontPath)) {
-		case ".otc", ".ttc":
-			ftc, err := opentype.ParseCollection(fontData)
-			if err != nil {
-				return nil, err
-			}
-			ft, err = ftc.Font(fontIndex)
-			if err != nil {
-				return nil, err
-			}
-		default:
-			ft, err = opentype.Parse(fontData)
-			if err != nil {
-				return nil, err
-			}
-		}
-	} else {
+	if _, err := os.Stat(fontPath); err != nil {
 		log.Warnf("%s is not found. please set font path with `-f` option", fontPath)
-		ft, err = opentype.Parse(gomono.TTF)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	opt := opentype.FaceOptions{
-		Size:    fontSize,
-		DPI:     72,
-		Hinting: 0,
+		return gomono.TTF, nil
 	}
-	face, err := opentype.NewFace(ft, &opt)
+	fontData, err := os.ReadFile(fontPath)
 	if err != nil {
 		return nil, err
 	}
-	return face, nil
+	if _, err := fontfile.NewFace(fontData, fontIndex, 1); err != nil {
+		return nil, err
+	}
+	return fontData, nil
 }
@@ -5,28 +5,31 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/font/gofont/gomono"
 )
 
-func TestReadFace(t *testing.T) {
+func TestReadFontFile(t *testing.T) {
 	testdataDir := filepath.Join("..", "testdata", "in")
 
 	type TestData struct {
 		desc        string
 		inFontPath  string
 		inFontIndex int
+		want        []byte
 		wantErr     bool
 	}
 	tests := []TestData{
 		{
-			desc:        "正常系: font.Faceが取得できる",
+			desc:        "正常系: フォントファイルの中身が取得できる",
 			inFontPath:  "/tmp/MyricaM.TTC",
 			inFontIndex: 0,
 			wantErr:     false,
 		},
 		{
-			desc:        "正常系: 存在しないファイルの場合もエラーにはならない",
+			desc:        "正常系: 存在しないファイルの場合はビルトインのフォントを返す",
 			inFontPath:  "/tmp/寿司",
 			inFontIndex: 0,
+			want:        gomono.TTF,
 			wantErr:     false,
 		},
 		{
@@ -58,7 +61,7 @@ func TestReadFace(t *testing.T) {
 		t.Run(tt.desc, func(t *testing.T) {
 			assert := assert.New(t)
 
-			got, err := readFace(tt.inFontPath, tt.inFontIndex, 20)
+			got, err := readFontFile(tt.inFontPath, tt.inFontIndex)
 			if tt.wantErr {
 				assert.Nil(got)
 				assert.Error(err)
@@ -67,6 +70,9 @@ func TestReadFace(t *testing.T) {
 
 			assert.NotNil(got)
 			assert.NoError(err)
+			if tt.want != nil {
+				assert.Equal(tt.want, got)
+			}
 		})
 	}
 }
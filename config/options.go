@@ -0,0 +1,98 @@
+package config
+
+import (
+	stdcolor "image/color"
+	"os"
+
+	"github.com/jiro4989/textimg/v3/image"
+	"github.com/jiro4989/textimg/v3/textimg"
+	"github.com/mattn/go-runewidth"
+)
+
+// Options は Adjust で調整した設定から描画の設定を返す。
+func (a *Config) Options() textimg.Options {
+	opt := textimg.Options{
+		ForegroundColor:     stdcolor.RGBA(a.ForegroundColor),
+		BackgroundColor:     stdcolor.RGBA(a.BackgroundColor),
+		Transparent:         a.Transparent,
+		KeepANSIBackground:  a.KeepANSIBackground,
+		BackgroundGradient:  a.BackgroundGradient,
+		BackgroundImage:     a.BackgroundImage,
+		BackgroundImageMode: a.BackgroundImageMode,
+		FontData:            a.FontData,
+		FontIndex:           a.FontIndex,
+		FontSize:            a.FontSize,
+		EmojiFontData:       a.EmojiFontData,
+		EmojiFontIndex:      a.EmojiFontIndex,
+		UseEmojiFont:        a.UseEmojiFont,
+		EastAsianWidth:      runewidth.EastAsianWidth,
+		EmbedFont:           a.EmbedFont,
+		Padding: image.Padding{
+			Top:    a.PaddingTop,
+			Right:  a.PaddingRight,
+			Bottom: a.PaddingBottom,
+			Left:   a.PaddingLeft,
+		},
+		LineSpacing:        a.LineSpacing,
+		LetterSpacing:      a.LetterSpacing,
+		Scale:              a.Scale,
+		FrameStyle:         a.Frame,
+		Title:              a.Title,
+		FrameMargin:        a.FrameMargin,
+		FrameMarginColor:   stdcolor.RGBA(a.FrameBackgroundColor),
+		LineNumbers:        a.LineNumbers,
+		StartLineNumber:    a.StartLine,
+		HighlightLines:     a.HighlightLineNumbers,
+		HighlightColor:     stdcolor.RGBA(a.HighlightLineColor),
+		LinesFrom:          a.LinesFrom,
+		LinesTo:            a.LinesTo,
+		Columns:            a.Columns,
+		WrapMode:           a.WrapMode,
+		Truncate:           a.Truncate,
+		Cols:               a.Cols,
+		Rows:               a.Rows,
+		Tail:               a.Tail,
+		UseAnimation:       a.UseAnimation,
+		AnimationLineCount: a.LineCount,
+		FrameSeparator:     a.FrameSeparator,
+		Delay:              a.Delay,
+		Loop:               a.Loop,
+		LastFrameDelay:     a.LastFrameDelay,
+		Dither:             a.Dither,
+		GlobalPalette:      a.GlobalPalette,
+		Slide:              a.UseSlideAnimation,
+		SlideWidth:         a.SlideWidth,
+		SlideForever:       a.SlideForever,
+		Typewriter:         a.Typewriter,
+		TypeUnit:           a.TypeUnit,
+		TypeStep:           a.TypeStep,
+		NewlinePause:       a.NewlinePause,
+		Cursor:             a.Cursor,
+		MarqueeStep:        a.MarqueeStepColumns,
+		MarqueeStepPixels:  a.MarqueeStepPixels,
+		MarqueeForever:     a.SlideForever,
+		Transition:         a.Transition,
+		TransitionSteps:    a.TransitionSteps,
+		Playback:           a.Playback,
+		ResizeWidth:        a.ResizeWidth,
+		ResizeHeight:       a.ResizeHeight,
+		Fit:                a.Fit,
+		Anchor:             a.Anchor,
+		Resample:           a.Resample,
+		MaxBytes:           a.MaxBytes,
+		PageRows:           a.PageRows,
+		PageMargin:         a.PageMargin,
+		HTMLFragment:       a.HTMLFragment,
+		HTMLClasses:        a.HTMLClasses,
+	}
+
+	if a.EmojiDir != "" {
+		opt.EmojiFS = os.DirFS(a.EmojiDir)
+	}
+
+	// マーキーアニメーションの表示領域を指定すると、フレームの幅が変わる
+	if a.Marquee {
+		opt.MarqueeWidth = a.MarqueeWidth
+	}
+	return opt
+}
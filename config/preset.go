@@ -0,0 +1,27 @@
+package config
+
+import "github.com/jiro4989/textimg/v3/textimg"
+
+// applyPreset はプリセットの大きさと最大サイズを設定する。
+// 縦横比の扱いは明示的に指定されていない場合のみプリセットの値を使う。
+func (a *Config) applyPreset() error {
+	// --slack は --preset slack と同じ
+	if a.ToSlackIcon && a.Preset == "" {
+		a.Preset = textimg.PresetSlack
+	}
+	if a.Preset == "" {
+		return nil
+	}
+
+	p, err := textimg.FindPreset(a.Preset)
+	if err != nil {
+		return err
+	}
+	a.ResizeWidth = p.Width
+	a.ResizeHeight = p.Height
+	a.MaxBytes = p.MaxBytes
+	if a.Fit == "" {
+		a.Fit = p.Fit
+	}
+	return nil
+}
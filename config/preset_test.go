@@ -0,0 +1,60 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_applyPreset(t *testing.T) {
+	tests := []struct {
+		desc    string
+		config  Config
+		want    Config
+		wantErr bool
+	}{
+		{
+			desc:   "正常系: 未指定の場合は何もしない",
+			config: Config{ResizeWidth: 10},
+			want:   Config{ResizeWidth: 10},
+		},
+		{
+			desc:   "正常系: プリセットの大きさと最大サイズを設定する",
+			config: Config{Preset: "twitter", ResizeWidth: 10},
+			want:   Config{Preset: "twitter", ResizeWidth: 1200, ResizeHeight: 675, Fit: "contain", MaxBytes: 5 * 1024 * 1024},
+		},
+		{
+			desc:   "正常系: 縦横比の扱いは指定した値を優先する",
+			config: Config{Preset: "ogp", Fit: "cover"},
+			want:   Config{Preset: "ogp", ResizeWidth: 1200, ResizeHeight: 630, Fit: "cover", MaxBytes: 8 * 1024 * 1024},
+		},
+		{
+			desc:   "正常系: ToSlackIconはslackのプリセットと同じ",
+			config: Config{ToSlackIcon: true},
+			want:   Config{ToSlackIcon: true, Preset: "slack", ResizeWidth: 128, ResizeHeight: 128, Fit: "contain", MaxBytes: 128 * 1024},
+		},
+		{
+			desc:   "正常系: ToSlackIconよりプリセットを優先する",
+			config: Config{ToSlackIcon: true, Preset: "line"},
+			want:   Config{ToSlackIcon: true, Preset: "line", ResizeWidth: 370, ResizeHeight: 320, Fit: "contain", MaxBytes: 1024 * 1024},
+		},
+		{
+			desc:    "異常系: 存在しないプリセット",
+			config:  Config{Preset: "sushi"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			err := tt.config.applyPreset()
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tt.want, tt.config)
+		})
+	}
+}
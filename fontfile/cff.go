@@ -0,0 +1,456 @@
+package fontfile
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// CFF の DICT のオペレータ
+const (
+	cffOpCharset     = 15
+	cffOpEncoding    = 16
+	cffOpCharStrings = 17
+	cffOpPrivate     = 18
+	cffOpSubrs       = 19
+	cffOpROS         = 12<<8 | 30
+	cffOpFDArray     = 12<<8 | 36
+	cffOpFDSelect    = 12<<8 | 37
+)
+
+// cffOpEndChar はグリフの終わりを表す CharString のオペレータ。
+const cffOpEndChar = 14
+
+// cffFont は CFF テーブルのうち、サブセットと CID の変換に使う情報。
+type cffFont struct {
+	data        []byte
+	tops        cffIndex      // Top DICT の INDEX
+	top         map[int][]int // 先頭のフォントの Top DICT
+	charStrings cffIndex      // グリフのアウトライン
+	charset     int           // charset の位置。0 から 2 は定義済みの charset
+	cidKeyed    bool          // CID で字形を指定するフォントか
+}
+
+// cffIndex は CFF の INDEX 構造。
+type cffIndex struct {
+	pos     int      // INDEX の先頭の位置
+	offSize int      // オフセットのバイト数
+	items   [][2]int // 各要素の CFF テーブル内の範囲
+	end     int      // INDEX の直後の位置
+}
+
+// IsCFF は data が CFF のアウトライン (CFF テーブル) を持つフォントかを判定す
+// る。
+func IsCFF(data []byte) bool {
+	_, tables, err := readTables(data, 0)
+	if err != nil {
+		return false
+	}
+	return findTable(tables, "CFF ") != nil
+}
+
+// CIDs はグリフの番号ごとの CID を返す。
+// CID で字形を指定しないフォントは、グリフの番号をそのまま使うので nil を返す。
+func CIDs(data []byte) ([]int, error) {
+	_, tables, err := readTables(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	t := findTable(tables, "CFF ")
+	if t == nil {
+		return nil, nil
+	}
+	f, err := parseCFF(t.data)
+	if err != nil {
+		return nil, err
+	}
+	if !f.cidKeyed {
+		return nil, nil
+	}
+	return f.cids()
+}
+
+// parseCFF は CFF テーブルを読み込む。
+// 複数のフォントを含む CFF テーブルは先頭のフォントだけを読み込む。
+func parseCFF(data []byte) (*cffFont, error) {
+	if len(data) < 4 {
+		return nil, errInvalidFont
+	}
+	names, err := readCFFIndex(data, int(data[2]))
+	if err != nil {
+		return nil, err
+	}
+	tops, err := readCFFIndex(data, names.end)
+	if err != nil {
+		return nil, err
+	}
+	if len(tops.items) < 1 {
+		return nil, errInvalidFont
+	}
+	top, err := parseCFFDict(data[tops.items[0][0]:tops.items[0][1]])
+	if err != nil {
+		return nil, err
+	}
+	if len(top[cffOpCharStrings]) < 1 {
+		return nil, errInvalidFont
+	}
+	charStrings, err := readCFFIndex(data, top[cffOpCharStrings][0])
+	if err != nil {
+		return nil, err
+	}
+
+	f := &cffFont{data: data, tops: tops, top: top, charStrings: charStrings}
+	if v := top[cffOpCharset]; 0 < len(v) {
+		f.charset = v[0]
+	}
+	_, f.cidKeyed = top[cffOpROS]
+	return f, nil
+}
+
+// cids は charset からグリフの番号ごとの CID を返す。
+func (f *cffFont) cids() ([]int, error) {
+	var (
+		n   = len(f.charStrings.items)
+		ret = make([]int, 1, n)
+		p   = f.charset
+	)
+	// CID で字形を指定するフォントは定義済みの charset を使わない
+	if p < 3 || len(f.data) < p+1 {
+		return nil, errInvalidFont
+	}
+	format := f.data[p]
+	p++
+	for len(ret) < n {
+		switch format {
+		case 0:
+			if len(f.data) < p+2 {
+				return nil, errInvalidFont
+			}
+			ret = append(ret, int(binary.BigEndian.Uint16(f.data[p:])))
+			p += 2
+		case 1, 2:
+			size := int(format) + 2
+			if len(f.data) < p+size {
+				return nil, errInvalidFont
+			}
+			first := int(binary.BigEndian.Uint16(f.data[p:]))
+			left := int(f.data[p+2])
+			if format == 2 {
+				left = int(binary.BigEndian.Uint16(f.data[p+2:]))
+			}
+			for cid := first; cid <= first+left && len(ret) < n; cid++ {
+				ret = append(ret, cid)
+			}
+			p += size
+		default:
+			return nil, errInvalidFont
+		}
+	}
+	return ret[:n], nil
+}
+
+// cffRegion は CFF テーブルの from から to までを data に置き換える範囲。
+type cffRegion struct {
+	from, to int
+	data     []byte
+}
+
+// replaceCharStrings は CharStrings の INDEX を charStrings に置き換えた CFF
+// テーブルを返す。
+//
+// INDEX の大きさが変わると後ろの構造の位置がずれるので、Top DICT と FDArray の
+// Font DICT のオフセットを書き直す。書き直したオフセットは常に5バイトの整数に
+// するので、DICT の大きさはオフセットの値によらない。
+func (f *cffFont) replaceCharStrings(charStrings []byte) ([]byte, error) {
+	if len(f.tops.items) != 1 {
+		return nil, errInvalidFont
+	}
+	var (
+		charStringsRegion = cffRegion{from: f.charStrings.pos, to: f.charStrings.end, data: charStrings}
+		regions           = []*cffRegion{&charStringsRegion}
+		fdArray           cffIndex
+		fdArrayRegion     cffRegion
+	)
+	if v := f.top[cffOpFDArray]; 0 < len(v) {
+		var err error
+		fdArray, err = readCFFIndex(f.data, v[0])
+		if err != nil {
+			return nil, err
+		}
+		fdArrayRegion = cffRegion{from: fdArray.pos, to: fdArray.end, data: f.data[fdArray.pos:fdArray.end]}
+		regions = append(regions, &fdArrayRegion)
+	}
+	sort.Slice(regions, func(a, b int) bool {
+		return regions[a].from < regions[b].from
+	})
+	for j, r := range regions {
+		if r.from < f.tops.end || (0 < j && r.from < regions[j-1].to) {
+			return nil, errInvalidFont
+		}
+	}
+
+	// Top DICT の INDEX と FDArray を書き直す
+	rewrite := func(reloc func(int) int) ([]byte, error) {
+		top, err := f.rewriteDict(f.data[f.tops.items[0][0]:f.tops.items[0][1]], reloc)
+		if err != nil {
+			return nil, err
+		}
+		if 0 < len(fdArray.items) {
+			fonts := make([][]byte, len(fdArray.items))
+			for j, item := range fdArray.items {
+				fonts[j], err = f.rewriteDict(f.data[item[0]:item[1]], reloc)
+				if err != nil {
+					return nil, err
+				}
+			}
+			fdArrayRegion.data = writeCFFIndex(fonts)
+		}
+		return writeCFFIndex([][]byte{top}), nil
+	}
+
+	// 書き直した後の大きさはオフセットの値によらないので、先に大きさを求めてか
+	// らオフセットを書き直す
+	tops, err := rewrite(func(off int) int { return off })
+	if err != nil {
+		return nil, err
+	}
+	reloc := func(off int) int {
+		ret := off + len(tops) - (f.tops.end - f.tops.pos)
+		for _, r := range regions {
+			if r.from < off {
+				ret += len(r.data) - (r.to - r.from)
+			}
+		}
+		return ret
+	}
+	if tops, err = rewrite(reloc); err != nil {
+		return nil, err
+	}
+
+	ret := append([]byte{}, f.data[:f.tops.pos]...)
+	ret = append(ret, tops...)
+	p := f.tops.end
+	for _, r := range regions {
+		ret = append(ret, f.data[p:r.from]...)
+		ret = append(ret, r.data...)
+		p = r.to
+	}
+	return append(ret, f.data[p:]...), nil
+}
+
+// rewriteDict は Top DICT か Font DICT のオフセットを reloc で書き直す。
+// Private DICT から Subrs へのオフセットは相対位置なので、Private DICT と Subrs
+// の間の大きさが変わる場合は対応しない。
+func (f *cffFont) rewriteDict(b []byte, reloc func(int) int) ([]byte, error) {
+	return rewriteCFFDict(b, func(op int, operands []int) ([]int, error) {
+		if len(operands) < 1 {
+			return nil, nil
+		}
+		v := operands[len(operands)-1]
+		switch op {
+		case cffOpCharset:
+			// 0 から 2 は定義済みの charset
+			if v < 3 {
+				return nil, nil
+			}
+		case cffOpEncoding:
+			// 0 と 1 は定義済みの encoding
+			if v < 2 {
+				return nil, nil
+			}
+		case cffOpPrivate:
+			size := operands[0]
+			if len(operands) != 2 || v < 0 || size < 0 || len(f.data) < v+size {
+				return nil, errInvalidFont
+			}
+			private, err := parseCFFDict(f.data[v : v+size])
+			if err != nil {
+				return nil, err
+			}
+			if subrs := private[cffOpSubrs]; 0 < len(subrs) && reloc(v+subrs[0])-reloc(v) != subrs[0] {
+				return nil, errUnsupportedCFF
+			}
+			return []int{size, reloc(v)}, nil
+		case cffOpCharStrings, cffOpFDArray, cffOpFDSelect:
+		default:
+			return nil, nil
+		}
+		return []int{reloc(v)}, nil
+	})
+}
+
+// writeCFFIndex は items を要素とする INDEX を返す。
+func writeCFFIndex(items [][]byte) []byte {
+	if len(items) == 0 {
+		return []byte{0, 0}
+	}
+	size := 1
+	for _, item := range items {
+		size += len(item)
+	}
+	offSize := 1
+	for 1<<(8*offSize) <= size {
+		offSize++
+	}
+
+	ret := []byte{byte(len(items) >> 8), byte(len(items)), byte(offSize)}
+	putOffset := func(off int) {
+		for k := offSize - 1; 0 <= k; k-- {
+			ret = append(ret, byte(off>>(8*k)))
+		}
+	}
+	// オフセットはデータ部分の直前のバイトを 1 とした位置
+	off := 1
+	putOffset(off)
+	for _, item := range items {
+		off += len(item)
+		putOffset(off)
+	}
+	for _, item := range items {
+		ret = append(ret, item...)
+	}
+	return ret
+}
+
+// readCFFIndex は p の位置の INDEX を読み込む。
+func readCFFIndex(data []byte, p int) (cffIndex, error) {
+	if p < 0 || len(data) < p+2 {
+		return cffIndex{}, errInvalidFont
+	}
+	count := int(binary.BigEndian.Uint16(data[p:]))
+	if count == 0 {
+		return cffIndex{pos: p, end: p + 2}, nil
+	}
+	if len(data) < p+3 {
+		return cffIndex{}, errInvalidFont
+	}
+	offSize := int(data[p+2])
+	if offSize < 1 || 4 < offSize || len(data) < p+3+(count+1)*offSize {
+		return cffIndex{}, errInvalidFont
+	}
+	offset := func(j int) int {
+		var v int
+		for _, b := range data[p+3+j*offSize : p+3+(j+1)*offSize] {
+			v = v<<8 | int(b)
+		}
+		return v
+	}
+	// オフセットは INDEX のデータ部分の直前のバイトを 1 とした位置
+	base := p + 2 + (count+1)*offSize
+	idx := cffIndex{pos: p, offSize: offSize}
+	for j := 0; j < count; j++ {
+		from, to := base+offset(j), base+offset(j+1)
+		if to < from || len(data) < to {
+			return cffIndex{}, errInvalidFont
+		}
+		idx.items = append(idx.items, [2]int{from, to})
+	}
+	idx.end = base + offset(count)
+	return idx, nil
+}
+
+// parseCFFDict は DICT をオペレータごとのオペランドにして返す。
+// 実数のオペランドは使わないので 0 として読み込む。
+func parseCFFDict(b []byte) (map[int][]int, error) {
+	ret := make(map[int][]int)
+	err := walkCFFDict(b, func(op int, operands []int, _ []byte) error {
+		ret[op] = operands
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// rewriteCFFDict は DICT のオペランドを fix で書き換えた DICT を返す。
+// fix が nil を返したオペレータは元のまま、それ以外は fix が返したオペランドを
+// 5バイトの整数にして書き出す。
+func rewriteCFFDict(b []byte, fix func(op int, operands []int) ([]int, error)) ([]byte, error) {
+	var ret []byte
+	err := walkCFFDict(b, func(op int, operands []int, raw []byte) error {
+		v, err := fix(op, operands)
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			ret = append(ret, raw...)
+			return nil
+		}
+		for _, x := range v {
+			ret = append(ret, 29, byte(x>>24), byte(x>>16), byte(x>>8), byte(x))
+		}
+		if op>>8 == 12 {
+			ret = append(ret, 12)
+		}
+		ret = append(ret, byte(op))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// walkCFFDict は DICT のオペレータごとに、オペランドと、オペランドからオペレー
+// タまでのバイト列を fn に渡す。
+// 実数のオペランドは使わないので 0 として読み込む。
+func walkCFFDict(b []byte, fn func(op int, operands []int, raw []byte) error) error {
+	var (
+		operands []int
+		start    int
+	)
+	for p := 0; p < len(b); {
+		b0 := int(b[p])
+		switch {
+		case b0 <= 21:
+			op := b0
+			if b0 == 12 {
+				if len(b) < p+2 {
+					return errInvalidFont
+				}
+				op = 12<<8 | int(b[p+1])
+				p++
+			}
+			p++
+			if err := fn(op, operands, b[start:p]); err != nil {
+				return err
+			}
+			operands = nil
+			start = p
+		case b0 == 28:
+			if len(b) < p+3 {
+				return errInvalidFont
+			}
+			operands = append(operands, int(int16(binary.BigEndian.Uint16(b[p+1:]))))
+			p += 3
+		case b0 == 29:
+			if len(b) < p+5 {
+				return errInvalidFont
+			}
+			operands = append(operands, int(int32(binary.BigEndian.Uint32(b[p+1:]))))
+			p += 5
+		case b0 == 30:
+			// 実数は 0xf のニブルまで続く
+			for p++; p < len(b) && b[p]&0x0f != 0x0f && b[p]&0xf0 != 0xf0; p++ {
+			}
+			operands = append(operands, 0)
+			p++
+		case 32 <= b0 && b0 <= 246:
+			operands = append(operands, b0-139)
+			p++
+		case 247 <= b0 && b0 <= 254:
+			if len(b) < p+2 {
+				return errInvalidFont
+			}
+			v := (b0-247)*256 + int(b[p+1]) + 108
+			if 251 <= b0 {
+				v = -(b0-251)*256 - int(b[p+1]) - 108
+			}
+			operands = append(operands, v)
+			p += 2
+		default:
+			return errInvalidFont
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package fontfile
+
+import (
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+)
+
+// NewFace は data のフォントファイルから size ポイントの font.Face を返す。
+// フォントコレクション (.ttc, .otc) の場合は index 番目のフォントを使う。
+func NewFace(data []byte, index int, size float64) (font.Face, error) {
+	var (
+		ft  *opentype.Font
+		err error
+	)
+	if isCollection(data) {
+		ftc, err := opentype.ParseCollection(data)
+		if err != nil {
+			return nil, err
+		}
+		ft, err = ftc.Font(index)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		ft, err = opentype.Parse(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	opt := opentype.FaceOptions{
+		Size:    size,
+		DPI:     72,
+		Hinting: 0,
+	}
+	return opentype.NewFace(ft, &opt)
+}
+
+// isCollection は data がフォントコレクションかどうかを判定する。
+func isCollection(data []byte) bool {
+	return 12 <= len(data) && string(data[:4]) == "ttcf"
+}
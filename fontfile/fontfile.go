@@ -0,0 +1,139 @@
+// Package fontfile はフォントファイルを他の形式のファイルに埋め込むための処理を
+// 提供する。
+package fontfile
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// table はフォントファイルのテーブル。
+type table struct {
+	tag  string
+	data []byte
+}
+
+var (
+	errInvalidFont    = errors.New("invalid font file")
+	errNoOutlines     = errors.New("font has neither TrueType nor CFF outlines")
+	errUnsupportedCFF = errors.New("unsupported layout of CFF table")
+)
+
+// Extract は data のフォントファイルから index 番目のフォントを単体のフォント
+// ファイルとして返す。
+// フォントコレクション (.ttc, .otc) でない場合は data をそのまま返す。
+func Extract(data []byte, index int) ([]byte, error) {
+	if !isCollection(data) {
+		return data, nil
+	}
+
+	numFonts := int(binary.BigEndian.Uint32(data[8:]))
+	if index < 0 || numFonts <= index {
+		return nil, fmt.Errorf("font index %d is out of range. the collection has %d fonts", index, numFonts)
+	}
+	p := 12 + 4*index
+	if len(data) < p+4 {
+		return nil, errInvalidFont
+	}
+	offset := int(binary.BigEndian.Uint32(data[p:]))
+
+	version, tables, err := readTables(data, offset)
+	if err != nil {
+		return nil, err
+	}
+	return writeFont(version, tables), nil
+}
+
+// readTables は offset の位置のテーブルディレクトリからテーブルを読み込む。
+func readTables(data []byte, offset int) (uint32, []table, error) {
+	if len(data) < offset+12 {
+		return 0, nil, errInvalidFont
+	}
+	var (
+		version   = binary.BigEndian.Uint32(data[offset:])
+		numTables = int(binary.BigEndian.Uint16(data[offset+4:]))
+		tables    []table
+	)
+	for j := 0; j < numTables; j++ {
+		p := offset + 12 + 16*j
+		if len(data) < p+16 {
+			return 0, nil, errInvalidFont
+		}
+		var (
+			tag = string(data[p : p+4])
+			off = int(binary.BigEndian.Uint32(data[p+8:]))
+			l   = int(binary.BigEndian.Uint32(data[p+12:]))
+		)
+		if off < 0 || l < 0 || len(data) < off+l {
+			return 0, nil, errInvalidFont
+		}
+		tables = append(tables, table{tag: tag, data: data[off : off+l]})
+	}
+	return version, tables, nil
+}
+
+// writeFont はテーブルからフォントファイルを組み立てる。
+// head テーブルのチェックサムは組み立てた後に計算し直す。
+func writeFont(version uint32, tables []table) []byte {
+	sort.Slice(tables, func(a, b int) bool {
+		return tables[a].tag < tables[b].tag
+	})
+
+	var (
+		numTables     = len(tables)
+		entrySelector = 0
+	)
+	for 1<<(entrySelector+1) <= numTables {
+		entrySelector++
+	}
+	searchRange := (1 << entrySelector) * 16
+
+	headerSize := 12 + 16*numTables
+	buf := make([]byte, headerSize)
+	binary.BigEndian.PutUint32(buf, version)
+	binary.BigEndian.PutUint16(buf[4:], uint16(numTables))
+	binary.BigEndian.PutUint16(buf[6:], uint16(searchRange))
+	binary.BigEndian.PutUint16(buf[8:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(buf[10:], uint16(numTables*16-searchRange))
+
+	headOffset := -1
+	for j, t := range tables {
+		offset := len(buf)
+		data := t.data
+		if t.tag == "head" && 12 <= len(data) {
+			// チェックサムの計算前に checkSumAdjustment を 0 にする
+			data = append([]byte{}, data...)
+			binary.BigEndian.PutUint32(data[8:], 0)
+			headOffset = offset
+		}
+		buf = append(buf, data...)
+		// テーブルは4バイト境界に揃える
+		for len(buf)%4 != 0 {
+			buf = append(buf, 0)
+		}
+
+		p := 12 + 16*j
+		copy(buf[p:], t.tag)
+		binary.BigEndian.PutUint32(buf[p+4:], checksum(data))
+		binary.BigEndian.PutUint32(buf[p+8:], uint32(offset))
+		binary.BigEndian.PutUint32(buf[p+12:], uint32(len(data)))
+	}
+
+	if 0 <= headOffset {
+		binary.BigEndian.PutUint32(buf[headOffset+8:], 0xB1B0AFBA-checksum(buf))
+	}
+	return buf
+}
+
+// checksum はテーブルのチェックサムを返す。
+func checksum(data []byte) uint32 {
+	var sum uint32
+	for j := 0; j < len(data); j += 4 {
+		var b [4]byte
+		copy(b[:], data[j:])
+		sum += binary.BigEndian.Uint32(b[:])
+	}
+	return sum
+}
@@ -0,0 +1,417 @@
+package fontfile
+
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+)
+
+// newCollection は fonts をまとめたフォントコレクションを返す。
+func newCollection(t *testing.T, fonts ...[]byte) []byte {
+	headerSize := 12 + 4*len(fonts)
+	buf := make([]byte, headerSize)
+	copy(buf, "ttcf")
+	binary.BigEndian.PutUint32(buf[4:], 0x00010000)
+	binary.BigEndian.PutUint32(buf[8:], uint32(len(fonts)))
+	for j, f := range fonts {
+		version, tables, err := readTables(f, 0)
+		assert.NoError(t, err)
+
+		// テーブルを後ろに並べて、テーブルディレクトリのオフセットを書き換える
+		dir := make([]byte, 12+16*len(tables))
+		binary.BigEndian.PutUint32(dir, version)
+		binary.BigEndian.PutUint16(dir[4:], uint16(len(tables)))
+		binary.BigEndian.PutUint32(buf[12+4*j:], uint32(len(buf)))
+		dirOffset := len(buf)
+		buf = append(buf, dir...)
+		for k, tb := range tables {
+			p := dirOffset + 12 + 16*k
+			copy(buf[p:], tb.tag)
+			binary.BigEndian.PutUint32(buf[p+8:], uint32(len(buf)))
+			binary.BigEndian.PutUint32(buf[p+12:], uint32(len(tb.data)))
+			buf = append(buf, tb.data...)
+			for len(buf)%4 != 0 {
+				buf = append(buf, 0)
+			}
+		}
+	}
+	return buf
+}
+
+func familyName(t *testing.T, data []byte) string {
+	f, err := sfnt.Parse(data)
+	assert.NoError(t, err)
+	name, err := f.Name(nil, sfnt.NameIDFamily)
+	assert.NoError(t, err)
+	return name
+}
+
+func TestExtract(t *testing.T) {
+	ttc := newCollection(t, goregular.TTF, gomono.TTF)
+	tests := []struct {
+		desc    string
+		data    []byte
+		index   int
+		want    string
+		wantErr bool
+	}{
+		{desc: "正常系: コレクションでない場合はそのまま返す", data: gomono.TTF, want: "Go Mono"},
+		{desc: "正常系: 0番目のフォント", data: ttc, index: 0, want: "Go"},
+		{desc: "正常系: 1番目のフォント", data: ttc, index: 1, want: "Go Mono"},
+		{desc: "異常系: 範囲外のインデックス", data: ttc, index: 2, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			got, err := Extract(tt.data, tt.index)
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tt.want, familyName(t, got))
+		})
+	}
+}
+
+func TestSubset(t *testing.T) {
+	cff, err := os.ReadFile("testdata/CFFTest.otf")
+	assert.NoError(t, err)
+
+	type glyph struct {
+		r    rune
+		want bool
+	}
+	tests := []struct {
+		desc    string
+		data    []byte
+		text    string
+		want    []glyph
+		wantErr bool
+	}{
+		{
+			desc: "正常系: TrueType のフォント",
+			data: gomono.TTF,
+			text: "ab",
+			want: []glyph{{r: 'a', want: true}, {r: 'b', want: true}, {r: 'c', want: false}},
+		},
+		{
+			desc: "正常系: CFF のフォント",
+			data: cff,
+			text: "1",
+			want: []glyph{{r: '1', want: true}, {r: 'Q', want: false}, {r: '中', want: false}},
+		},
+		{
+			desc:    "異常系: フォントでないデータ",
+			data:    []byte("hello"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			got, err := Subset(tt.data, tt.text)
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Less(len(got), len(tt.data))
+
+			// 元のフォントと同じグリフの番号で、使われていないグリフは空になる
+			orig, err := sfnt.Parse(tt.data)
+			assert.NoError(err)
+			f, err := sfnt.Parse(got)
+			assert.NoError(err)
+			assert.Equal(orig.NumGlyphs(), f.NumGlyphs())
+			var b sfnt.Buffer
+			for _, g := range tt.want {
+				gid, err := f.GlyphIndex(&b, g.r)
+				assert.NoError(err)
+				segs, err := f.LoadGlyph(&b, gid, 1<<6, nil)
+				assert.NoError(err)
+				assert.Equal(g.want, 0 < len(segs), string(g.r))
+			}
+		})
+	}
+}
+
+func TestIsTrueType(t *testing.T) {
+	tests := []struct {
+		desc string
+		data []byte
+		want bool
+	}{
+		{desc: "正常系: TrueType のフォント", data: gomono.TTF, want: true},
+		{desc: "異常系: フォントでないデータ", data: []byte("hello"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsTrueType(tt.data))
+		})
+	}
+}
+
+func TestNewFace(t *testing.T) {
+	ttc := newCollection(t, goregular.TTF, gomono.TTF)
+	tests := []struct {
+		desc    string
+		data    []byte
+		index   int
+		wantErr bool
+	}{
+		{desc: "正常系: フォントファイル", data: gomono.TTF},
+		{desc: "正常系: フォントコレクションの1番目のフォント", data: ttc, index: 1},
+		{desc: "異常系: 範囲外のインデックス", data: ttc, index: 2, wantErr: true},
+		{desc: "異常系: フォントファイルではない", data: []byte("textimg"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			got, err := NewFace(tt.data, tt.index, 20)
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.NotNil(got)
+		})
+	}
+}
+
+func TestIsCFF(t *testing.T) {
+	cff, err := os.ReadFile("testdata/CFFTest.otf")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		desc string
+		data []byte
+		want bool
+	}{
+		{desc: "正常系: CFF のフォント", data: cff, want: true},
+		{desc: "正常系: TrueType のフォント", data: gomono.TTF, want: false},
+		{desc: "異常系: フォントでないデータ", data: []byte("hello"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsCFF(tt.data))
+		})
+	}
+}
+
+func TestCIDs(t *testing.T) {
+	cff, err := os.ReadFile("testdata/CFFTest.otf")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		desc    string
+		data    []byte
+		want    []int
+		wantErr bool
+	}{
+		{desc: "正常系: CID で字形を指定しない CFF のフォント", data: cff, want: nil},
+		{desc: "正常系: TrueType のフォント", data: gomono.TTF, want: nil},
+		{desc: "異常系: フォントでないデータ", data: []byte("hello"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			got, err := CIDs(tt.data)
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tt.want, got)
+		})
+	}
+}
+
+func TestCFFFont_cids(t *testing.T) {
+	// charset は 3 バイト目から始める
+	tests := []struct {
+		desc    string
+		charset []byte
+		want    []int
+		wantErr bool
+	}{
+		{desc: "正常系: format 0", charset: []byte{0, 0, 10, 0, 20, 0, 30}, want: []int{0, 10, 20, 30}},
+		{desc: "正常系: format 1", charset: []byte{1, 0, 10, 1, 0, 30, 0}, want: []int{0, 10, 11, 30}},
+		{desc: "正常系: format 2", charset: []byte{2, 0, 10, 0, 2}, want: []int{0, 10, 11, 12}},
+		{desc: "異常系: charset が足りない", charset: []byte{0, 0, 10}, wantErr: true},
+		{desc: "異常系: 未対応の format", charset: []byte{3, 0, 10}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			f := &cffFont{
+				data:        append([]byte{0, 0, 0}, tt.charset...),
+				charStrings: cffIndex{items: make([][2]int, 4)},
+				charset:     3,
+				cidKeyed:    true,
+			}
+			got, err := f.cids()
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tt.want, got)
+		})
+	}
+}
+
+// newTestCFF は CID で字形を指定する CFF テーブルを返す。
+// Top DICT の後ろの構造は order の順に並べる。Top DICT と Font DICT のオフセッ
+// トは3バイトの整数にする。
+func newTestCFF(order ...string) (data []byte, blocks map[string][]byte) {
+	enc := func(v int) []byte { return []byte{28, byte(v >> 8), byte(v)} }
+	build := func(pos map[string]int) []byte {
+		private := append(enc(pos["subrs"]-pos["private"]), cffOpSubrs)
+		font := append(append(enc(len(private)), enc(pos["private"])...), cffOpPrivate)
+		blocks = map[string][]byte{
+			"charset":     {0, 0, 1, 0, 2},
+			"fdselect":    {0, 0, 0, 0},
+			"charstrings": writeCFFIndex([][]byte{{cffOpEndChar}, {139, cffOpEndChar}, {139, 139, cffOpEndChar}}),
+			"fdarray":     writeCFFIndex([][]byte{font}),
+			"private":     private,
+			"subrs":       writeCFFIndex([][]byte{{11}}),
+		}
+
+		top := []byte{139, 139, 139, 12, 30}
+		top = append(append(top, enc(pos["charset"])...), cffOpCharset)
+		top = append(append(top, enc(pos["fdselect"])...), 12, 37)
+		top = append(append(top, enc(pos["charstrings"])...), cffOpCharStrings)
+		top = append(append(top, enc(pos["fdarray"])...), 12, 36)
+		head := []byte{1, 0, 4, 4}
+		head = append(head, writeCFFIndex([][]byte{[]byte("A")})...)
+		head = append(head, writeCFFIndex([][]byte{top})...)
+		// String INDEX と Global Subr INDEX は空にする
+		return append(head, 0, 0, 0, 0)
+	}
+
+	// オフセットの値によらず大きさは変わらないので、先に位置を求める
+	var (
+		pos = make(map[string]int)
+		p   = len(build(pos))
+	)
+	for _, name := range order {
+		pos[name] = p
+		p += len(blocks[name])
+	}
+	data = build(pos)
+	for _, name := range order {
+		data = append(data, blocks[name]...)
+	}
+	return data, blocks
+}
+
+func TestCFFFont_replaceCharStrings(t *testing.T) {
+	var (
+		small = [][]byte{{cffOpEndChar}, {cffOpEndChar}, {cffOpEndChar}}
+		large = make([][]byte, 3)
+	)
+	for j := range large {
+		large[j] = append(make([]byte, 300), cffOpEndChar)
+	}
+	tests := []struct {
+		desc    string
+		order   []string
+		glyphs  [][]byte
+		wantErr error
+	}{
+		{
+			desc:   "正常系: CharStrings が小さくなる",
+			order:  []string{"charset", "fdselect", "charstrings", "fdarray", "private", "subrs"},
+			glyphs: small,
+		},
+		{
+			desc:   "正常系: CharStrings が大きくなる",
+			order:  []string{"charset", "fdselect", "charstrings", "fdarray", "private", "subrs"},
+			glyphs: large,
+		},
+		{
+			desc:   "正常系: CharStrings の後ろに charset と FDSelect がある",
+			order:  []string{"fdarray", "private", "subrs", "charstrings", "charset", "fdselect"},
+			glyphs: large,
+		},
+		{
+			desc:    "異常系: Private DICT と Subrs の間に CharStrings がある",
+			order:   []string{"charset", "fdselect", "private", "charstrings", "fdarray", "subrs"},
+			glyphs:  small,
+			wantErr: errUnsupportedCFF,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			data, blocks := newTestCFF(tt.order...)
+			f, err := parseCFF(data)
+			assert.NoError(err)
+			got, err := f.replaceCharStrings(writeCFFIndex(tt.glyphs))
+			if tt.wantErr != nil {
+				assert.ErrorIs(err, tt.wantErr)
+				return
+			}
+			assert.NoError(err)
+
+			g, err := parseCFF(got)
+			assert.NoError(err)
+			for gid, item := range g.charStrings.items {
+				assert.Equal(tt.glyphs[gid], got[item[0]:item[1]])
+			}
+			cids, err := g.cids()
+			assert.NoError(err)
+			assert.Equal([]int{0, 1, 2}, cids)
+			fdSelect := g.top[cffOpFDSelect][0]
+			assert.Equal(blocks["fdselect"], got[fdSelect:fdSelect+len(blocks["fdselect"])])
+
+			// Font DICT の Private DICT と、その Subrs の位置も変わらない
+			fdArray, err := readCFFIndex(got, g.top[cffOpFDArray][0])
+			assert.NoError(err)
+			font, err := parseCFFDict(got[fdArray.items[0][0]:fdArray.items[0][1]])
+			assert.NoError(err)
+			size, off := font[cffOpPrivate][0], font[cffOpPrivate][1]
+			assert.Equal(blocks["private"], got[off:off+size])
+			private, err := parseCFFDict(got[off : off+size])
+			assert.NoError(err)
+			subrs := off + private[cffOpSubrs][0]
+			assert.Equal(blocks["subrs"], got[subrs:subrs+len(blocks["subrs"])])
+		})
+	}
+}
+
+func TestWriteCFFIndex(t *testing.T) {
+	tests := []struct {
+		desc  string
+		items [][]byte
+		want  []byte
+	}{
+		{desc: "正常系: 空の INDEX", items: nil, want: []byte{0, 0}},
+		{desc: "正常系: 1バイトのオフセット", items: [][]byte{{1}, {2, 3}}, want: []byte{0, 2, 1, 1, 2, 4, 1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			got := writeCFFIndex(tt.items)
+			assert.Equal(tt.want, got)
+			// 書き出した INDEX を読み込める
+			idx, err := readCFFIndex(got, 0)
+			assert.NoError(err)
+			assert.Len(idx.items, len(tt.items))
+			assert.Equal(len(got), idx.end)
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package fontfile
+
+import (
+	"encoding/binary"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// 複合グリフのフラグ
+const (
+	flagArg1And2AreWords   = 0x0001
+	flagWeHaveAScale       = 0x0008
+	flagMoreComponents     = 0x0020
+	flagWeHaveAnXAndYScale = 0x0040
+	flagWeHaveATwoByTwo    = 0x0080
+)
+
+// Subset は text で使われている文字のグリフだけを残したフォントファイルを返す。
+//
+// グリフの番号を変えないように、使われていないグリフは中身を空にするだけにする。
+// そのため cmap や hmtx などのテーブルはそのまま使える。
+// アウトラインは TrueType (glyf テーブル) と CFF (CFF テーブル) に対応する。
+func Subset(data []byte, text string) ([]byte, error) {
+	version, tables, err := readTables(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	if cff := findTable(tables, "CFF "); cff != nil {
+		return subsetCFF(data, version, tables, cff, text)
+	}
+	var (
+		glyf = findTable(tables, "glyf")
+		loca = findTable(tables, "loca")
+		head = findTable(tables, "head")
+		maxp = findTable(tables, "maxp")
+	)
+	if glyf == nil || loca == nil || head == nil || maxp == nil || len(head.data) < 54 || len(maxp.data) < 6 {
+		return nil, errNoOutlines
+	}
+
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		numGlyphs = int(binary.BigEndian.Uint16(maxp.data[4:]))
+		longLoca  = binary.BigEndian.Uint16(head.data[50:]) == 1
+		offsets   = make([]int, numGlyphs+1)
+	)
+	for j := range offsets {
+		if longLoca {
+			if len(loca.data) < 4*j+4 {
+				return nil, errInvalidFont
+			}
+			offsets[j] = int(binary.BigEndian.Uint32(loca.data[4*j:]))
+			continue
+		}
+		if len(loca.data) < 2*j+2 {
+			return nil, errInvalidFont
+		}
+		offsets[j] = int(binary.BigEndian.Uint16(loca.data[2*j:])) * 2
+	}
+	glyphData := func(gid int) []byte {
+		from, to := offsets[gid], offsets[gid+1]
+		if to <= from || len(glyf.data) < to {
+			return nil
+		}
+		return glyf.data[from:to]
+	}
+
+	// 使われている文字のグリフと、複合グリフが参照するグリフを残す
+	keep := usedGlyphs(f, text, numGlyphs)
+	var todo []int
+	for gid := range keep {
+		todo = append(todo, gid)
+	}
+	for 0 < len(todo) {
+		gid := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		for _, c := range components(glyphData(gid)) {
+			if c < numGlyphs && !keep[c] {
+				keep[c] = true
+				todo = append(todo, c)
+			}
+		}
+	}
+
+	// loca は常に4バイトの形式で書き出す
+	var (
+		newGlyf []byte
+		newLoca = make([]byte, 4*(numGlyphs+1))
+	)
+	for gid := 0; gid < numGlyphs; gid++ {
+		binary.BigEndian.PutUint32(newLoca[4*gid:], uint32(len(newGlyf)))
+		if keep[gid] {
+			newGlyf = append(newGlyf, glyphData(gid)...)
+			for len(newGlyf)%4 != 0 {
+				newGlyf = append(newGlyf, 0)
+			}
+		}
+	}
+	binary.BigEndian.PutUint32(newLoca[4*numGlyphs:], uint32(len(newGlyf)))
+
+	newHead := append([]byte{}, head.data...)
+	binary.BigEndian.PutUint16(newHead[50:], 1)
+
+	var ret []table
+	for _, t := range tables {
+		switch t.tag {
+		case "glyf":
+			t.data = newGlyf
+		case "loca":
+			t.data = newLoca
+		case "head":
+			t.data = newHead
+		case "DSIG":
+			// 署名は中身を変えると無効になるので削除する
+			continue
+		}
+		ret = append(ret, t)
+	}
+	return writeFont(version, ret), nil
+}
+
+// subsetCFF は CFF のアウトラインを持つフォントのサブセットを返す。
+//
+// 使われていないグリフは endchar だけのグリフにして CharStrings の INDEX を作り
+// 直す。グリフの番号は変えないので charset や FDSelect はそのまま使える。
+// サブルーチン (Subrs) は削らない。
+func subsetCFF(data []byte, version uint32, tables []table, cff *table, text string) ([]byte, error) {
+	c, err := parseCFF(cff.data)
+	if err != nil {
+		return nil, err
+	}
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		n      = len(c.charStrings.items)
+		keep   = usedGlyphs(f, text, n)
+		glyphs = make([][]byte, n)
+	)
+	for gid, item := range c.charStrings.items {
+		if keep[gid] {
+			glyphs[gid] = cff.data[item[0]:item[1]]
+			continue
+		}
+		glyphs[gid] = []byte{cffOpEndChar}
+	}
+	newCFF, err := c.replaceCharStrings(writeCFFIndex(glyphs))
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []table
+	for _, t := range tables {
+		switch t.tag {
+		case "CFF ":
+			t.data = newCFF
+		case "DSIG":
+			// 署名は中身を変えると無効になるので削除する
+			continue
+		}
+		ret = append(ret, t)
+	}
+	return writeFont(version, ret), nil
+}
+
+// usedGlyphs は text で使われている文字のグリフの番号を返す。
+// 0 番目のグリフ (.notdef) は常に含める。
+func usedGlyphs(f *sfnt.Font, text string, numGlyphs int) map[int]bool {
+	var (
+		b    sfnt.Buffer
+		keep = map[int]bool{0: true}
+	)
+	for _, r := range text {
+		gid, err := f.GlyphIndex(&b, r)
+		if err != nil || numGlyphs <= int(gid) {
+			continue
+		}
+		keep[int(gid)] = true
+	}
+	return keep
+}
+
+// IsTrueType は data が TrueType のアウトライン (glyf テーブル) を持つフォント
+// かを判定する。
+func IsTrueType(data []byte) bool {
+	_, tables, err := readTables(data, 0)
+	if err != nil {
+		return false
+	}
+	return findTable(tables, "glyf") != nil
+}
+
+func findTable(tables []table, tag string) *table {
+	for j := range tables {
+		if tables[j].tag == tag {
+			return &tables[j]
+		}
+	}
+	return nil
+}
+
+// components は複合グリフが参照するグリフの番号を返す。
+// 単純グリフの場合は nil を返す。
+func components(g []byte) (ret []int) {
+	if len(g) < 10 || int16(binary.BigEndian.Uint16(g)) >= 0 {
+		return nil
+	}
+	p := 10
+	for p+4 <= len(g) {
+		flags := binary.BigEndian.Uint16(g[p:])
+		ret = append(ret, int(binary.BigEndian.Uint16(g[p+2:])))
+		p += 4
+		if flags&flagArg1And2AreWords != 0 {
+			p += 4
+		} else {
+			p += 2
+		}
+		switch {
+		case flags&flagWeHaveAScale != 0:
+			p += 2
+		case flags&flagWeHaveAnXAndYScale != 0:
+			p += 4
+		case flags&flagWeHaveATwoByTwo != 0:
+			p += 8
+		}
+		if flags&flagMoreComponents == 0 {
+			break
+		}
+	}
+	return
+}
@@ -16,10 +16,12 @@ require (
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
-require github.com/oliamb/cutter v0.2.2
+require (
+	github.com/clipperhouse/uax29/v2 v2.2.0
+	github.com/oliamb/cutter v0.2.2
+)
 
 require (
-	github.com/clipperhouse/uax29/v2 v2.2.0 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/spf13/pflag v1.0.9 // indirect
@@ -0,0 +1,236 @@
+// Package html はANSIエスケープシーケンスで色付けされたテキストを、色付きの
+// HTMLに変換する。
+// 画像とは違いフォントを描画しないので、出力したテキストは選択やコピーができる。
+package html
+
+import (
+	"bufio"
+	"fmt"
+	stdhtml "html"
+	"io"
+	"strings"
+
+	"github.com/jiro4989/textimg/v3/color"
+	"github.com/jiro4989/textimg/v3/token"
+)
+
+// クラス名の接頭辞
+const classPrefix = "textimg"
+
+type (
+	Options struct {
+		ForegroundColor color.RGBA // 文字色
+		BackgroundColor color.RGBA // 背景色
+		Transparent     bool       // 背景色を指定しない
+		Fragment        bool       // <html> を含まない <pre> 要素だけを出力する
+		UseClasses      bool       // 16色の色指定をインラインのスタイルではなくクラスにする
+	}
+
+	// state はエスケープシーケンスで指定された文字の状態。
+	state struct {
+		foregroundColor     color.RGBA
+		backgroundColor     color.RGBA
+		foregroundIsDefault bool
+		backgroundIsDefault bool
+		bold                bool
+		dim                 bool
+		italic              bool
+		underline           bool
+		blink               bool
+		reverse             bool
+		hide                bool
+		strike              bool
+	}
+)
+
+var (
+	// 16色のエスケープシーケンスの番号。添字がクラス名の番号になる
+	paletteCodes = []int{30, 31, 32, 33, 34, 35, 36, 37, 90, 91, 92, 93, 94, 95, 96, 97}
+)
+
+// Encode は tokens を HTML として w に出力する。
+func Encode(w io.Writer, tokens token.Tokens, opt *Options) error {
+	bw := bufio.NewWriter(w)
+
+	if !opt.Fragment {
+		fmt.Fprintln(bw, "<!DOCTYPE html>")
+		fmt.Fprintln(bw, "<html>")
+		fmt.Fprintln(bw, "<head>")
+		fmt.Fprintln(bw, `<meta charset="utf-8">`)
+		fmt.Fprintln(bw, "<title>textimg</title>")
+		if opt.UseClasses {
+			fmt.Fprintf(bw, "<style>\n%s</style>\n", Stylesheet())
+		}
+		fmt.Fprintln(bw, "</head>")
+		fmt.Fprintln(bw, "<body>")
+	} else if opt.UseClasses {
+		fmt.Fprintf(bw, "<style>\n%s</style>\n", Stylesheet())
+	}
+
+	styles := []string{"color:" + hexColor(opt.ForegroundColor)}
+	if !opt.Transparent {
+		styles = append(styles, "background-color:"+hexColor(opt.BackgroundColor))
+	}
+	fmt.Fprintf(bw, `<pre class="%s" style="%s">`, classPrefix, strings.Join(styles, ";"))
+
+	s := newState()
+	for _, t := range tokens {
+		switch t.Kind {
+		case token.KindColor:
+			s.update(t)
+		case token.KindText:
+			writeText(bw, t.Text, s, opt)
+		}
+	}
+
+	fmt.Fprintln(bw, "</pre>")
+	if !opt.Fragment {
+		fmt.Fprintln(bw, "</body>")
+		fmt.Fprintln(bw, "</html>")
+	}
+	return bw.Flush()
+}
+
+// Stylesheet は16色のクラスのスタイルシートを返す。
+func Stylesheet() string {
+	var sb strings.Builder
+	for i, code := range paletteCodes {
+		fmt.Fprintf(&sb, ".%s-fg-%d{color:%s}\n", classPrefix, i, hexColor(color.ANSIMap[code]))
+		fmt.Fprintf(&sb, ".%s-bg-%d{background-color:%s}\n", classPrefix, i, hexColor(color.ANSIMap[code+10]))
+	}
+	return sb.String()
+}
+
+func writeText(w io.Writer, text string, s state, opt *Options) {
+	text = stdhtml.EscapeString(text)
+	classes, styles := s.attrs(opt)
+	if len(classes) < 1 && len(styles) < 1 {
+		fmt.Fprint(w, text)
+		return
+	}
+
+	var attrs []string
+	if 0 < len(classes) {
+		attrs = append(attrs, fmt.Sprintf(`class="%s"`, strings.Join(classes, " ")))
+	}
+	if 0 < len(styles) {
+		attrs = append(attrs, fmt.Sprintf(`style="%s"`, strings.Join(styles, ";")))
+	}
+	fmt.Fprintf(w, "<span %s>%s</span>", strings.Join(attrs, " "), text)
+}
+
+func newState() state {
+	return state{
+		foregroundIsDefault: true,
+		backgroundIsDefault: true,
+	}
+}
+
+func (s *state) update(t token.Token) {
+	switch t.ColorType {
+	case token.ColorTypeReset:
+		*s = newState()
+	case token.ColorTypeBold:
+		s.bold = true
+	case token.ColorTypeDim:
+		s.dim = true
+	case token.ColorTypeItalic:
+		s.italic = true
+	case token.ColorTypeUnderline:
+		s.underline = true
+	case token.ColorTypeBlink, token.ColorTypeSpeedyBlink:
+		s.blink = true
+	case token.ColorTypeReverse:
+		s.reverse = true
+	case token.ColorTypeHide:
+		s.hide = true
+	case token.ColorTypeDelete:
+		s.strike = true
+	case token.ColorTypeForeground:
+		s.foregroundColor = t.Color
+		s.foregroundIsDefault = false
+	case token.ColorTypeBackground:
+		s.backgroundColor = t.Color
+		s.backgroundIsDefault = false
+	case token.ColorTypeResetForeground:
+		s.foregroundIsDefault = true
+	case token.ColorTypeResetBackground:
+		s.backgroundIsDefault = true
+	}
+}
+
+// attrs は文字の状態を表すクラスとスタイルを返す。
+func (s state) attrs(opt *Options) (classes, styles []string) {
+	var (
+		fg, bg       = s.foregroundColor, s.backgroundColor
+		fgDef, bgDef = s.foregroundIsDefault, s.backgroundIsDefault
+	)
+	if s.reverse {
+		// 反転するとデフォルトの色も入れ替わるので、色を明示する
+		fg, bg = bg, fg
+		if bgDef {
+			fg = opt.BackgroundColor
+		}
+		if fgDef {
+			bg = opt.ForegroundColor
+		}
+		fgDef, bgDef = false, false
+	}
+
+	if !fgDef {
+		if n, ok := paletteIndex(fg, 0); ok && opt.UseClasses {
+			classes = append(classes, fmt.Sprintf("%s-fg-%d", classPrefix, n))
+		} else {
+			styles = append(styles, "color:"+hexColor(fg))
+		}
+	}
+	if !bgDef {
+		if n, ok := paletteIndex(bg, 10); ok && opt.UseClasses {
+			classes = append(classes, fmt.Sprintf("%s-bg-%d", classPrefix, n))
+		} else {
+			styles = append(styles, "background-color:"+hexColor(bg))
+		}
+	}
+
+	if s.bold {
+		styles = append(styles, "font-weight:bold")
+	}
+	if s.dim {
+		styles = append(styles, "opacity:0.5")
+	}
+	if s.italic {
+		styles = append(styles, "font-style:italic")
+	}
+	var decorations []string
+	if s.underline {
+		decorations = append(decorations, "underline")
+	}
+	if s.strike {
+		decorations = append(decorations, "line-through")
+	}
+	if s.blink {
+		decorations = append(decorations, "blink")
+	}
+	if 0 < len(decorations) {
+		styles = append(styles, "text-decoration:"+strings.Join(decorations, " "))
+	}
+	if s.hide {
+		styles = append(styles, "visibility:hidden")
+	}
+	return
+}
+
+// paletteIndex は col が16色のうち何番目の色かを返す。
+// offset は文字色の場合は 0、背景色の場合は 10 を指定する。
+func paletteIndex(col color.RGBA, offset int) (int, bool) {
+	for i, code := range paletteCodes {
+		if color.ANSIMap[code+offset] == col {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func hexColor(col color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", col.R, col.G, col.B)
+}
@@ -0,0 +1,96 @@
+package html
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jiro4989/textimg/v3/color"
+	"github.com/jiro4989/textimg/v3/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncode(t *testing.T) {
+	defaultOptions := func() *Options {
+		return &Options{
+			ForegroundColor: color.RGBAWhite,
+			BackgroundColor: color.RGBABlack,
+			Fragment:        true,
+		}
+	}
+	tests := []struct {
+		desc string
+		s    string
+		opt  *Options
+		want string
+	}{
+		{
+			desc: "正常系: 色指定なし",
+			s:    "<hello> & world",
+			opt:  defaultOptions(),
+			want: `<pre class="textimg" style="color:#ffffff;background-color:#000000">&lt;hello&gt; &amp; world</pre>` + "\n",
+		},
+		{
+			desc: "正常系: 文字色と背景色",
+			s:    "\x1b[31;42mred\x1b[0m plain",
+			opt:  defaultOptions(),
+			want: `<pre class="textimg" style="color:#ffffff;background-color:#000000"><span style="color:#ff0000;background-color:#00ff00">red</span> plain</pre>` + "\n",
+		},
+		{
+			desc: "正常系: 文字の装飾",
+			s:    "\x1b[1;3;4;9mdeco",
+			opt:  defaultOptions(),
+			want: `<pre class="textimg" style="color:#ffffff;background-color:#000000"><span style="font-weight:bold;font-style:italic;text-decoration:underline line-through">deco</span></pre>` + "\n",
+		},
+		{
+			desc: "正常系: 反転するとデフォルトの色も入れ替わる",
+			s:    "\x1b[7mrev",
+			opt:  defaultOptions(),
+			want: `<pre class="textimg" style="color:#ffffff;background-color:#000000"><span style="color:#000000;background-color:#ffffff">rev</span></pre>` + "\n",
+		},
+		{
+			desc: "正常系: 16色はクラスにする",
+			s:    "\x1b[31;44mred\x1b[38;5;100mrgb",
+			opt: func() *Options {
+				o := defaultOptions()
+				o.UseClasses = true
+				return o
+			}(),
+			want: "<style>\n" + Stylesheet() + "</style>\n" +
+				`<pre class="textimg" style="color:#ffffff;background-color:#000000"><span class="textimg-fg-1 textimg-bg-4">red</span><span class="textimg-bg-4" style="color:#878700">rgb</span></pre>` + "\n",
+		},
+		{
+			desc: "正常系: 背景を透過する",
+			s:    "a",
+			opt: func() *Options {
+				o := defaultOptions()
+				o.Transparent = true
+				return o
+			}(),
+			want: `<pre class="textimg" style="color:#ffffff">a</pre>` + "\n",
+		},
+		{
+			desc: "正常系: 単体のページ",
+			s:    "a",
+			opt: func() *Options {
+				o := defaultOptions()
+				o.Fragment = false
+				return o
+			}(),
+			want: "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>textimg</title>\n</head>\n<body>\n" +
+				`<pre class="textimg" style="color:#ffffff;background-color:#000000">a</pre>` + "\n</body>\n</html>\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			tokens, err := parser.Parse(tt.s)
+			assert.NoError(err)
+
+			var buf bytes.Buffer
+			err = Encode(&buf, tokens, tt.opt)
+			assert.NoError(err)
+			assert.Equal(tt.want, buf.String())
+		})
+	}
+}
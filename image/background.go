@@ -0,0 +1,188 @@
+package image
+
+import (
+	"fmt"
+	"image"
+	c "image/color"
+	"image/draw"
+	"math"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+const (
+	GradientLinear = "linear"
+	GradientRadial = "radial"
+
+	BackgroundImageModeScale  = "scale"
+	BackgroundImageModeTile   = "tile"
+	BackgroundImageModeCenter = "center"
+)
+
+var (
+	BackgroundImageModes = []string{
+		BackgroundImageModeScale,
+		BackgroundImageModeTile,
+		BackgroundImageModeCenter,
+	}
+)
+
+// Gradient はグラデーションの背景。
+type Gradient struct {
+	Kind string
+	// 線形グラデーションの向き (度)。CSS と同じく 0 で下から上、90 で左から右、
+	// 180 で上から下になる
+	Angle  float64
+	Colors []c.RGBA
+}
+
+// ValidateBackgroundImageMode は背景画像の配置方法が正しいかを検証する。
+func ValidateBackgroundImageMode(s string) error {
+	for _, v := range BackgroundImageModes {
+		if s == v {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not supported background image mode.", s)
+}
+
+// useBackgroundLayer はデフォルト背景色以外の背景レイヤーを使うかを返す。
+func (i *Image) useBackgroundLayer() bool {
+	return i.backgroundGradient != nil || i.backgroundImage != nil
+}
+
+// baseBackgroundColor は文字を描画するキャンバスの下地の色を返す。
+// 背景レイヤーを使う場合は後から合成するので透過しておく。
+func (i *Image) baseBackgroundColor() c.RGBA {
+	if i.useBackgroundLayer() {
+		return c.RGBA{}
+	}
+	return i.defaultBackgroundColor
+}
+
+// composeBackground は描画済みの画像とアニメーションの各フレームを背景レイヤー
+// の上に合成する。
+func (i *Image) composeBackground() {
+	if !i.useBackgroundLayer() {
+		return
+	}
+
+	i.image = i.compose(i.image)
+	for j, img := range i.animationImages {
+		i.animationImages[j] = i.compose(img)
+	}
+}
+
+func (i *Image) compose(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	dst := i.newBackgroundLayer(b.Dx(), b.Dy())
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Over)
+	return dst
+}
+
+// newBackgroundLayer はデフォルト背景色、グラデーション、背景画像の順に重ねた
+// 背景レイヤーを返す。
+func (i *Image) newBackgroundLayer(w, h int) *image.RGBA {
+	dst := newImage(w, h)
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(i.defaultBackgroundColor), image.Point{}, draw.Src)
+	if i.backgroundGradient != nil {
+		drawGradient(dst, i.backgroundGradient)
+	}
+	if i.backgroundImage != nil {
+		drawBackgroundImage(dst, i.backgroundImage, i.backgroundImageMode)
+	}
+	return dst
+}
+
+func drawGradient(dst *image.RGBA, g *Gradient) {
+	var (
+		b  = dst.Bounds()
+		w  = float64(b.Dx())
+		h  = float64(b.Dy())
+		cx = w / 2
+		cy = h / 2
+	)
+
+	// 線形グラデーションの向きと長さ
+	rad := g.Angle * math.Pi / 180
+	dx, dy := math.Sin(rad), -math.Cos(rad)
+	length := math.Abs(w*dx) + math.Abs(h*dy)
+	// 円形グラデーションの半径は中心から角までの距離
+	radius := math.Hypot(cx, cy)
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			px := float64(x-b.Min.X) + 0.5 - cx
+			py := float64(y-b.Min.Y) + 0.5 - cy
+			var t float64
+			switch g.Kind {
+			case GradientRadial:
+				t = math.Hypot(px, py) / radius
+			default:
+				t = (px*dx+py*dy)/length + 0.5
+			}
+			col := gradientColorAt(g.Colors, t)
+			dst.SetRGBA(x, y, blend(dst.RGBAAt(x, y), col))
+		}
+	}
+}
+
+// gradientColorAt は等間隔に並べた色の t (0~1) の位置の色を返す。
+func gradientColorAt(cols []c.RGBA, t float64) c.NRGBA {
+	if len(cols) == 1 {
+		return c.NRGBA(cols[0])
+	}
+	t = math.Max(0, math.Min(1, t))
+	pos := t * float64(len(cols)-1)
+	n := int(pos)
+	if len(cols)-1 <= n {
+		return c.NRGBA(cols[len(cols)-1])
+	}
+	f := pos - float64(n)
+	a, b := cols[n], cols[n+1]
+	lerp := func(x, y uint8) uint8 {
+		return uint8(math.Round(float64(x)*(1-f) + float64(y)*f))
+	}
+	return c.NRGBA{
+		R: lerp(a.R, b.R),
+		G: lerp(a.G, b.G),
+		B: lerp(a.B, b.B),
+		A: lerp(a.A, b.A),
+	}
+}
+
+// blend は dst の上に src を重ねた色を返す。
+func blend(dst c.RGBA, src c.Color) c.RGBA {
+	sr, sg, sb, sa := src.RGBA()
+	a := 0xffff - sa
+	return c.RGBA{
+		R: uint8((uint32(dst.R)*0x101*a/0xffff + sr) >> 8),
+		G: uint8((uint32(dst.G)*0x101*a/0xffff + sg) >> 8),
+		B: uint8((uint32(dst.B)*0x101*a/0xffff + sb) >> 8),
+		A: uint8((uint32(dst.A)*0x101*a/0xffff + sa) >> 8),
+	}
+}
+
+func drawBackgroundImage(dst *image.RGBA, src image.Image, mode string) {
+	var (
+		db = dst.Bounds()
+		sb = src.Bounds()
+	)
+	if sb.Empty() {
+		return
+	}
+	switch mode {
+	case BackgroundImageModeTile:
+		for y := db.Min.Y; y < db.Max.Y; y += sb.Dy() {
+			for x := db.Min.X; x < db.Max.X; x += sb.Dx() {
+				r := image.Rect(x, y, x+sb.Dx(), y+sb.Dy())
+				draw.Draw(dst, r, src, sb.Min, draw.Over)
+			}
+		}
+	case BackgroundImageModeCenter:
+		p := image.Pt((db.Dx()-sb.Dx())/2, (db.Dy()-sb.Dy())/2)
+		draw.Draw(dst, sb.Sub(sb.Min).Add(p), src, sb.Min, draw.Over)
+	default:
+		xdraw.CatmullRom.Scale(dst, db, src, sb, draw.Over, nil)
+	}
+}
@@ -1,45 +1,186 @@
 package image
 
 import (
+	"bytes"
 	"fmt"
 	"image"
-	"image/color/palette"
-	"image/draw"
+	c "image/color"
 	"image/gif"
 	"image/jpeg"
 	"image/png"
 	"io"
+
+	"github.com/jiro4989/textimg/v3/apng"
+	"github.com/jiro4989/textimg/v3/webp"
 )
 
-func (i *Image) Encode(w io.Writer, ext string) error {
-	img := i.image
-	switch ext {
-	case ".png":
-		return png.Encode(w, img)
-	case ".jpg", ".jpeg":
-		return jpeg.Encode(w, img, nil)
-	case ".gif":
-		if i.useAnimation {
-			var delays []int
-			for x := 0; x < len(i.animationImages); x++ {
-				delays = append(delays, i.delay)
-			}
-			return gif.EncodeAll(w, &gif.GIF{
-				Image: toPalettes(i.animationImages),
-				Delay: delays,
-			})
+// GIF のパレットの最大の色数
+const maxPaletteSize = 256
+
+var (
+	// 最大サイズに収まるまで段階的に画質を落とす。
+	// 0段階目は制限がない場合と同じ設定にする
+	jpegQualities = []int{jpeg.DefaultQuality, 60, 45, 30, 15, 5}
+	// PNG と GIF は RGB の各成分の階調数を減らす。0 の場合は減らさない
+	paletteLevels = []int{0, 6, 5, 4, 3, 2}
+)
+
+// Encode は format の形式で画像を出力する。
+// format は形式名か拡張子で指定する。
+// 最大サイズが指定されている場合は、収まるまで画質や色数を落とす。
+func (i *Image) Encode(w io.Writer, format string) error {
+	f, err := LookupFormat(format)
+	if err != nil {
+		return err
+	}
+	if i.maxBytes < 1 {
+		return f.Encoder.Encode(w, i, 0)
+	}
+
+	// ベクタ形式などは画質を落とせない
+	levels := max(f.Levels, 1)
+	for level := 0; level < levels; level++ {
+		var buf bytes.Buffer
+		if err := f.Encoder.Encode(&buf, i, level); err != nil {
+			return err
+		}
+		if buf.Len() <= i.maxBytes {
+			_, err := buf.WriteTo(w)
+			return err
+		}
+	}
+	return fmt.Errorf("could not encode the image within %d bytes. reduce the text or the image size", i.maxBytes)
+}
+
+// encodePNG は level 段階目の画質で PNG を出力する。
+// アニメーションの場合は APNG にする。
+func encodePNG(w io.Writer, i *Image, level int) error {
+	if i.useAnimation {
+		return i.encodeAPNG(w, level)
+	}
+	if level == 0 {
+		return png.Encode(w, i.image)
+	}
+	enc := &png.Encoder{CompressionLevel: png.BestCompression}
+	return enc.Encode(w, toUniformPaletted(i.image, paletteLevels[level]))
+}
+
+// encodeJPEG は level 段階目の画質で JPEG を出力する。
+func encodeJPEG(w io.Writer, i *Image, level int) error {
+	if i.transparent {
+		return fmt.Errorf("jpeg does not support transparent background. %s", FormatHint(func(f Format) bool { return f.Transparency }))
+	}
+	return jpeg.Encode(w, i.image, &jpeg.Options{Quality: jpegQualities[level]})
+}
+
+// encodeGIF は level 段階目の画質で GIF を出力する。
+func encodeGIF(w io.Writer, i *Image, level int) error {
+	if i.useAnimation {
+		return i.encodeGIFAnimation(w, level)
+	}
+	if 0 < level {
+		return gif.Encode(w, toUniformPaletted(i.image, paletteLevels[level]), nil)
+	}
+	return gif.Encode(w, i.toPaletted(i.image), nil)
+}
+
+// encodeWebP は level 段階目の画質で WebP を出力する。
+func encodeWebP(w io.Writer, i *Image, level int) error {
+	if i.useAnimation {
+		imgs, delays := i.animatedFrames(level)
+		return webp.EncodeAll(w, &webp.Animation{
+			Image:     imgs,
+			Delay:     delays,
+			LoopCount: i.loop,
+		})
+	}
+	if 0 < level {
+		return webp.Encode(w, toUniformPaletted(i.image, paletteLevels[level]))
+	}
+	return webp.Encode(w, i.image)
+}
+
+// encodeAPNG はアニメーションの各フレームを APNG で出力する。
+// アニメーションでない場合は1フレームの APNG にする。
+func (i *Image) encodeAPNG(w io.Writer, level int) error {
+	var (
+		imgs   []image.Image
+		delays []int
+	)
+	if i.useAnimation {
+		imgs, delays = i.animatedFrames(level)
+	} else {
+		img := image.Image(i.image)
+		if 0 < level {
+			img = toUniformPaletted(img, paletteLevels[level])
 		}
-		return gif.Encode(w, img, nil)
+		imgs, delays = []image.Image{img}, []int{i.delay * 10}
 	}
-	return fmt.Errorf("%s is not supported extension.", ext)
+	return apng.EncodeAll(w, &apng.APNG{
+		Image:     imgs,
+		Delay:     delays,
+		LoopCount: i.loop,
+	})
 }
 
-func toPalettes(imgs []image.Image) (ret []*image.Paletted) {
-	for _, v := range imgs {
-		bounds := v.Bounds()
-		p := image.NewPaletted(bounds, palette.Plan9)
-		draw.Draw(p, p.Rect, v, bounds.Min, draw.Over)
-		ret = append(ret, p)
+// animatedFrames は APNG と WebP 用に、アニメーションの各フレームとミリ秒単位の
+// 表示時間を返す。
+// 画質を落とす場合は、全てのフレームを同じパレットのパレット画像にする。
+func (i *Image) animatedFrames(level int) (imgs []image.Image, delays []int) {
+	frames, ds := i.animationFrames()
+	for j, img := range frames {
+		if 0 < level {
+			img = toUniformPaletted(img, paletteLevels[level])
+		}
+		imgs = append(imgs, img)
+		// GIF と同じく 1/100 秒単位の指定をミリ秒にする
+		delays = append(delays, ds[j]*10)
 	}
 	return
 }
+
+// toUniformPaletted は RGB の各成分を n 階調に減らしたパレット画像に変換する。
+// パレットの末尾には透過色を含める。
+func toUniformPaletted(img image.Image, n int) *image.Paletted {
+	var (
+		bounds = img.Bounds()
+		pal    = uniformPalette(n)
+		ti     = uint8(len(pal) - 1)
+		p      = image.NewPaletted(bounds, pal)
+		// 0~255 の値を最も近い階調に丸める
+		level = func(v uint8) int {
+			return (int(v)*(n-1) + 127) / 255
+		}
+	)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			col := c.NRGBAModel.Convert(img.At(x, y)).(c.NRGBA)
+			if col.A < 128 {
+				p.SetColorIndex(x, y, ti)
+				continue
+			}
+			idx := (level(col.R)*n+level(col.G))*n + level(col.B)
+			p.SetColorIndex(x, y, uint8(idx))
+		}
+	}
+	return p
+}
+
+// uniformPalette は RGB の各成分を n 階調に等分したパレットを返す。
+// n は 6 以下であること。
+func uniformPalette(n int) c.Palette {
+	var pal c.Palette
+	for r := 0; r < n; r++ {
+		for g := 0; g < n; g++ {
+			for b := 0; b < n; b++ {
+				pal = append(pal, c.RGBA{
+					R: uint8(r * 255 / (n - 1)),
+					G: uint8(g * 255 / (n - 1)),
+					B: uint8(b * 255 / (n - 1)),
+					A: 255,
+				})
+			}
+		}
+	}
+	return append(pal, c.RGBA{})
+}
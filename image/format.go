@@ -0,0 +1,216 @@
+package image
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/jiro4989/textimg/v3/token"
+)
+
+type (
+	// Encoder は描画した画像を特定の形式で出力する。
+	//
+	// level は最大サイズに収めるために画質を落とす段階で、0 の場合は落とさない。
+	// Format.Levels 未満の値で呼び出す。
+	Encoder interface {
+		Encode(w io.Writer, img *Image, level int) error
+	}
+
+	// EncoderFunc は関数を Encoder として使うための型。
+	EncoderFunc func(w io.Writer, img *Image, level int) error
+
+	// Format は出力形式。
+	Format struct {
+		Name         string   // --format で指定する形式名
+		Extensions   []string // ドット付きの拡張子。先頭をこの形式のデフォルトの拡張子にする
+		MIMEType     string
+		Animation    bool // アニメーションに対応している
+		Transparency bool // 背景の透過に対応している
+		// ベクタ形式。FontData のフォントで文字を出力し、ウィンドウ枠や背景の
+		// グラデーション、背景画像は描画できない
+		Vector bool
+		// 画像を描画せずに、トークンから出力する。Image.Draw の代わりに
+		// Image.SetTokens で出力するトークンを設定する
+		Text    bool
+		Levels  int // 画質を落とせる段階の数。1 以下の場合は落とせない
+		Encoder Encoder
+	}
+)
+
+var (
+	formatsMu sync.RWMutex
+	formats   []Format
+)
+
+func init() {
+	RegisterFormat(Format{
+		Name:         "png",
+		Extensions:   []string{".png"},
+		MIMEType:     "image/png",
+		Animation:    true,
+		Transparency: true,
+		Levels:       len(paletteLevels),
+		Encoder:      EncoderFunc(encodePNG),
+	})
+	RegisterFormat(Format{
+		Name:         "apng",
+		Extensions:   []string{".apng"},
+		MIMEType:     "image/apng",
+		Animation:    true,
+		Transparency: true,
+		Levels:       len(paletteLevels),
+		Encoder: EncoderFunc(func(w io.Writer, img *Image, level int) error {
+			return img.encodeAPNG(w, level)
+		}),
+	})
+	RegisterFormat(Format{
+		Name:       "jpeg",
+		Extensions: []string{".jpg", ".jpeg"},
+		MIMEType:   "image/jpeg",
+		Levels:     len(jpegQualities),
+		Encoder:    EncoderFunc(encodeJPEG),
+	})
+	RegisterFormat(Format{
+		Name:         "gif",
+		Extensions:   []string{".gif"},
+		MIMEType:     "image/gif",
+		Animation:    true,
+		Transparency: true,
+		Levels:       len(paletteLevels),
+		Encoder:      EncoderFunc(encodeGIF),
+	})
+	RegisterFormat(Format{
+		Name:         "webp",
+		Extensions:   []string{".webp"},
+		MIMEType:     "image/webp",
+		Animation:    true,
+		Transparency: true,
+		Levels:       len(paletteLevels),
+		Encoder:      EncoderFunc(encodeWebP),
+	})
+	RegisterFormat(Format{
+		Name:         "svg",
+		Extensions:   []string{".svg"},
+		MIMEType:     "image/svg+xml",
+		Transparency: true,
+		Vector:       true,
+		Encoder: EncoderFunc(func(w io.Writer, img *Image, level int) error {
+			return img.encodeSVG(w)
+		}),
+	})
+	RegisterFormat(Format{
+		Name:         "pdf",
+		Extensions:   []string{".pdf"},
+		MIMEType:     "application/pdf",
+		Transparency: true,
+		Vector:       true,
+		Encoder: EncoderFunc(func(w io.Writer, img *Image, level int) error {
+			return img.encodePDF(w)
+		}),
+	})
+	RegisterFormat(Format{
+		Name:         "html",
+		Extensions:   []string{".html", ".htm"},
+		MIMEType:     "text/html",
+		Transparency: true,
+		Text:         true,
+		Encoder: EncoderFunc(func(w io.Writer, img *Image, level int) error {
+			return img.encodeHTML(w)
+		}),
+	})
+}
+
+func (f EncoderFunc) Encode(w io.Writer, img *Image, level int) error {
+	return f(w, img, level)
+}
+
+// Animated はアニメーションとして出力するかを返す。
+func (i *Image) Animated() bool {
+	return i.useAnimation
+}
+
+// Frames はアニメーションの各フレームと、1/100 秒単位の表示時間を返す。
+// 連続する同じフレームは1つにまとめる。
+func (i *Image) Frames() ([]image.Image, []int) {
+	return i.animationFrames()
+}
+
+// Loop はアニメーションのループ回数を返す。0 の場合は無限にループする。
+func (i *Image) Loop() int {
+	return i.loop
+}
+
+// Tokens は SetTokens か Draw で設定したトークンを返す。
+func (i *Image) Tokens() token.Tokens {
+	return i.tokens
+}
+
+// RegisterFormat は出力形式を登録する。
+// 同じ名前の形式が登録済みの場合は置き換える。
+func RegisterFormat(f Format) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+
+	for j, v := range formats {
+		if v.Name == f.Name {
+			formats[j] = f
+			return
+		}
+	}
+	formats = append(formats, f)
+}
+
+// Formats は登録済みの出力形式を登録順に返す。
+func Formats() []Format {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	return slices.Clone(formats)
+}
+
+// FormatNames は登録済みの出力形式の名前を登録順に返す。
+func FormatNames() []string {
+	var names []string
+	for _, f := range Formats() {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+// FormatExtensions は match を満たす登録済みの出力形式のデフォルトの拡張子を
+// 登録順に返す。
+func FormatExtensions(match func(Format) bool) []string {
+	var exts []string
+	for _, f := range Formats() {
+		if match(f) && 0 < len(f.Extensions) {
+			exts = append(exts, f.Extensions[0])
+		}
+	}
+	return exts
+}
+
+// FormatHint は match を満たす出力形式を案内するエラーメッセージ用の文を返す。
+func FormatHint(match func(Format) bool) string {
+	return fmt.Sprintf("use one of [%s]", strings.Join(FormatExtensions(match), "|"))
+}
+
+// LookupFormat は形式名か拡張子に一致する出力形式を返す。
+// 大文字と小文字は区別せず、拡張子はドットを省略できる。
+func LookupFormat(s string) (Format, error) {
+	name := strings.TrimPrefix(strings.ToLower(s), ".")
+	for _, f := range Formats() {
+		if f.Name == name {
+			return f, nil
+		}
+		for _, ext := range f.Extensions {
+			if ext == "."+name {
+				return f, nil
+			}
+		}
+	}
+	return Format{}, fmt.Errorf("%s is not supported format. supported formats are [%s]", s, strings.Join(FormatNames(), "|"))
+}
@@ -0,0 +1,341 @@
+package image
+
+import (
+	"fmt"
+	"image"
+	c "image/color"
+	"image/draw"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	FrameStyleNone    = "none"
+	FrameStyleMac     = "mac"
+	FrameStyleWindows = "windows"
+)
+
+var (
+	FrameStyles = []string{
+		FrameStyleNone,
+		FrameStyleMac,
+		FrameStyleWindows,
+	}
+
+	// macOS のウィンドウの閉じる、最小化、最大化ボタンの色
+	macButtonColors = []c.RGBA{
+		{R: 255, G: 95, B: 86, A: 255},
+		{R: 255, G: 189, B: 46, A: 255},
+		{R: 39, G: 201, B: 63, A: 255},
+	}
+)
+
+// frameStyle はウィンドウ枠のスタイルごとの見た目の設定。
+type frameStyle struct {
+	cornerRadius int
+	shadowSize   int
+	drawButtons  func(dst *image.RGBA, bar image.Rectangle, col c.RGBA)
+	// ボタン領域の幅。タイトルバーの高さに対する倍率
+	buttonsWidth float64
+	centerTitle  bool
+}
+
+var frameStyleMap = map[string]frameStyle{
+	FrameStyleMac: {
+		cornerRadius: 10,
+		shadowSize:   16,
+		drawButtons:  drawMacButtons,
+		buttonsWidth: 2.2,
+		centerTitle:  true,
+	},
+	FrameStyleWindows: {
+		cornerRadius: 0,
+		shadowSize:   10,
+		drawButtons:  drawWindowsButtons,
+		buttonsWidth: 4.5,
+		centerTitle:  false,
+	},
+}
+
+// ValidateFrameStyle はウィンドウ枠のスタイル名が正しいかを検証する。
+func ValidateFrameStyle(s string) error {
+	for _, v := range FrameStyles {
+		if s == v {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not supported frame style.", s)
+}
+
+func useFrame(style string) bool {
+	_, ok := frameStyleMap[style]
+	return ok
+}
+
+// titleBarHeight はタイトルバーの高さを返す。
+func titleBarHeight(charHeight int) int {
+	return charHeight * 3 / 2
+}
+
+// frameWindowWidth はウィンドウの幅を返す。
+// 中身が狭い場合でも、ボタンとタイトルが重ならない幅を確保する。
+func frameWindowWidth(style frameStyle, f font.Face, title string, barH, contentW int) int {
+	buttonsW := int(float64(barH) * style.buttonsWidth)
+	titleW := font.MeasureString(f, title).Ceil()
+	var w int
+	if style.centerTitle {
+		w = buttonsW*2 + titleW
+	} else {
+		w = barH + titleW + buttonsW
+	}
+	if w < contentW {
+		return contentW
+	}
+	return w
+}
+
+// frameInsets はウィンドウ枠によって追加される上下左右の幅を返す。
+func (p *ImageParam) frameInsets() Padding {
+	style, ok := frameStyleMap[p.FrameStyle]
+	if !ok {
+		return Padding{}
+	}
+	var (
+		_, ch, _ = charSize(p.FontFace, p.LineSpacing, p.LetterSpacing)
+		barH     = titleBarHeight(ch)
+		cw, _    = p.canvasSize()
+		ww       = frameWindowWidth(style, p.FontFace, p.Title, barH, cw)
+		m        = p.FrameMargin
+	)
+	return Padding{
+		Top:    m + barH,
+		Right:  m + ww - cw,
+		Bottom: m,
+		Left:   m,
+	}
+}
+
+// applyFrame は描画済みの画像とアニメーションの各フレームをウィンドウ枠で囲う。
+func (i *Image) applyFrame() {
+	if !useFrame(i.frameStyle) {
+		return
+	}
+
+	i.image = i.frame(i.image)
+	for j, img := range i.animationImages {
+		i.animationImages[j] = i.frame(img)
+	}
+}
+
+// scaledFrameStyle は描画倍率に合わせて角丸と影の大きさを拡大したスタイルを返す。
+func (i *Image) scaledFrameStyle() frameStyle {
+	style := frameStyleMap[i.frameStyle]
+	style.cornerRadius *= i.scaleFactor
+	style.shadowSize *= i.scaleFactor
+	return style
+}
+
+// frame は src をタイトルバー付きのウィンドウ枠で囲った画像を返す。
+func (i *Image) frame(src image.Image) *image.RGBA {
+	var (
+		style   = i.scaledFrameStyle()
+		margin  = i.frameMargin
+		barH    = titleBarHeight(i.charHeight)
+		sb      = src.Bounds()
+		winW    = frameWindowWidth(style, i.fontFace, i.title, barH, sb.Dx())
+		winRect = image.Rect(margin, margin, margin+winW, margin+barH+sb.Dy())
+		barRect = image.Rect(winRect.Min.X, winRect.Min.Y, winRect.Max.X, winRect.Min.Y+barH)
+		dst     = newImage(winRect.Max.X+margin, winRect.Max.Y+margin)
+	)
+
+	// 外側の余白
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(i.frameMarginColor), image.Point{}, draw.Src)
+
+	// 影は余白の中にだけ描画される
+	drawShadow(dst, winRect.Add(image.Pt(0, style.shadowSize/4)), style.cornerRadius, style.shadowSize)
+
+	// 角丸のウィンドウ本体
+	mask := roundedRectMask(dst.Bounds(), winRect, style.cornerRadius)
+	barColor, textColor := i.titleBarColors()
+	draw.DrawMask(dst, barRect, image.NewUniform(barColor), image.Point{}, mask, barRect.Min, draw.Over)
+	body := image.Rect(winRect.Min.X, barRect.Max.Y, winRect.Max.X, winRect.Max.Y)
+	// 中身がウィンドウより狭い場合は右側を背景色で埋める
+	draw.DrawMask(dst, body, image.NewUniform(i.defaultBackgroundColor), image.Point{}, mask, body.Min, draw.Over)
+	draw.DrawMask(dst, body, src, sb.Min, mask, body.Min, draw.Over)
+
+	style.drawButtons(dst, barRect, textColor)
+	i.drawTitle(dst, barRect, textColor, style.centerTitle)
+
+	return dst
+}
+
+// titleBarColors は背景色の明るさに合わせてタイトルバーの色と文字色を返す。
+func (i *Image) titleBarColors() (bar, text c.RGBA) {
+	bg := i.defaultBackgroundColor
+	// 相対輝度の近似
+	l := 0.299*float64(bg.R) + 0.587*float64(bg.G) + 0.114*float64(bg.B)
+	if l < 128 {
+		return c.RGBA{R: 60, G: 60, B: 60, A: 255}, c.RGBA{R: 200, G: 200, B: 200, A: 255}
+	}
+	return c.RGBA{R: 225, G: 225, B: 225, A: 255}, c.RGBA{R: 80, G: 80, B: 80, A: 255}
+}
+
+func (i *Image) drawTitle(dst *image.RGBA, bar image.Rectangle, col c.RGBA, center bool) {
+	if i.title == "" {
+		return
+	}
+
+	d := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(col),
+		Face: i.fontFace,
+	}
+	w := d.MeasureString(i.title).Ceil()
+	x := bar.Min.X + bar.Dy()/2
+	if center {
+		// NOTE: frameWindowWidth で両側にボタン分の幅を確保しているので重ならない
+		x = bar.Min.X + (bar.Dx()-w)/2
+	}
+	m := i.fontFace.Metrics()
+	y := bar.Min.Y + (bar.Dy()+m.Ascent.Ceil()-m.Descent.Ceil())/2
+	d.Dot = fixed.P(x, y)
+	d.DrawString(i.title)
+}
+
+func drawMacButtons(dst *image.RGBA, bar image.Rectangle, _ c.RGBA) {
+	var (
+		r   = float64(bar.Dy()) / 5
+		gap = r * 3.2
+		cy  = float64(bar.Min.Y) + float64(bar.Dy())/2
+		cx  = float64(bar.Min.X) + float64(bar.Dy())/2
+	)
+	for j, col := range macButtonColors {
+		drawCircle(dst, cx+gap*float64(j), cy, r, col)
+	}
+}
+
+func drawWindowsButtons(dst *image.RGBA, bar image.Rectangle, col c.RGBA) {
+	var (
+		size = bar.Dy() / 3
+		w    = bar.Dy() * 3 / 2
+		cy   = bar.Min.Y + bar.Dy()/2
+	)
+	// 右から閉じる、最大化、最小化の順に描画する
+	// r の Max は範囲に含まないので、右端と下端は Max - 1 に描画する
+	for j := 0; j < 3; j++ {
+		cx := bar.Max.X - w*j - w/2
+		r := image.Rect(cx-size/2, cy-size/2, cx+size/2, cy+size/2)
+		switch j {
+		case 0:
+			for k := 0; k < r.Dx(); k++ {
+				dst.Set(r.Min.X+k, r.Min.Y+k, col)
+				dst.Set(r.Max.X-1-k, r.Min.Y+k, col)
+			}
+		case 1:
+			for k := r.Min.X; k < r.Max.X; k++ {
+				dst.Set(k, r.Min.Y, col)
+				dst.Set(k, r.Max.Y-1, col)
+			}
+			for k := r.Min.Y; k < r.Max.Y; k++ {
+				dst.Set(r.Min.X, k, col)
+				dst.Set(r.Max.X-1, k, col)
+			}
+		case 2:
+			for k := r.Min.X; k < r.Max.X; k++ {
+				dst.Set(k, cy, col)
+			}
+		}
+	}
+}
+
+// drawCircle はアンチエイリアスを掛けた円を描画する。
+func drawCircle(dst *image.RGBA, cx, cy, r float64, col c.RGBA) {
+	rect := image.Rect(int(cx-r)-1, int(cy-r)-1, int(cx+r)+2, int(cy+r)+2)
+	mask := image.NewAlpha(rect)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			d := math.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy)
+			mask.SetAlpha(x, y, c.Alpha{A: coverage(r - d)})
+		}
+	}
+	draw.DrawMask(dst, rect, image.NewUniform(col), image.Point{}, mask, rect.Min, draw.Over)
+}
+
+// roundedRectMask は rect の範囲を角丸の矩形で切り抜くマスクを返す。
+func roundedRectMask(bounds, rect image.Rectangle, radius int) *image.Alpha {
+	mask := image.NewAlpha(bounds)
+	r := float64(radius)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			mask.SetAlpha(x, y, c.Alpha{A: coverage(roundedRectDistance(rect, r, x, y))})
+		}
+	}
+	return mask
+}
+
+// roundedRectDistance は座標 (x, y) のピクセル中心から角丸の矩形の境界までの
+// 距離を返す。内側が正の値になる。
+func roundedRectDistance(rect image.Rectangle, r float64, x, y int) float64 {
+	var (
+		px   = float64(x) + 0.5
+		py   = float64(y) + 0.5
+		minX = float64(rect.Min.X) + r
+		maxX = float64(rect.Max.X) - r
+		minY = float64(rect.Min.Y) + r
+		maxY = float64(rect.Max.Y) - r
+		dx   = math.Max(math.Max(minX-px, px-maxX), 0)
+		dy   = math.Max(math.Max(minY-py, py-maxY), 0)
+	)
+	if dx == 0 && dy == 0 {
+		// 角以外は矩形の辺までの距離
+		edge := math.Min(
+			math.Min(px-float64(rect.Min.X), float64(rect.Max.X)-px),
+			math.Min(py-float64(rect.Min.Y), float64(rect.Max.Y)-py),
+		)
+		return edge
+	}
+	return r - math.Hypot(dx, dy)
+}
+
+// drawShadow はぼかした影を描画する。
+func drawShadow(dst *image.RGBA, rect image.Rectangle, radius, size int) {
+	if size <= 0 {
+		return
+	}
+	var (
+		mask   = image.NewAlpha(dst.Bounds())
+		spread = float64(size)
+		// 影の最も濃い部分の不透明度
+		maxAlpha = 110.0
+	)
+	r := float64(radius)
+	outer := rect.Inset(-size)
+	for y := outer.Min.Y; y < outer.Max.Y; y++ {
+		for x := outer.Min.X; x < outer.Max.X; x++ {
+			d := roundedRectDistance(rect, r, x, y)
+			if 0 <= d {
+				mask.SetAlpha(x, y, c.Alpha{A: uint8(maxAlpha)})
+				continue
+			}
+			t := 1 + d/spread
+			if t <= 0 {
+				continue
+			}
+			mask.SetAlpha(x, y, c.Alpha{A: uint8(maxAlpha * t * t)})
+		}
+	}
+	draw.DrawMask(dst, dst.Bounds(), image.NewUniform(c.Black), image.Point{}, mask, image.Point{}, draw.Over)
+}
+
+// coverage は境界からの距離をアンチエイリアス用の不透明度に変換する。
+func coverage(d float64) uint8 {
+	switch {
+	case d <= -0.5:
+		return 0
+	case 0.5 <= d:
+		return 255
+	}
+	return uint8((d + 0.5) * 255)
+}
@@ -0,0 +1,256 @@
+package image
+
+import (
+	"errors"
+	"image"
+	c "image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"math"
+
+	"github.com/jiro4989/textimg/v3/quantize"
+)
+
+// animationFrames はアニメーションの各フレームと、1/100 秒単位の表示時間を返す。
+// 連続する同じフレームは1つにまとめて表示時間を足す。
+func (i *Image) animationFrames() (frames []image.Image, delays []int) {
+	for j, img := range i.animationImages {
+		delay := i.frameDelay(j)
+		if j == len(i.animationImages)-1 && 0 < i.lastFrameDelay {
+			delay = i.lastFrameDelay
+		}
+		if 0 < len(frames) && sameImage(frames[len(frames)-1], img) {
+			delays[len(delays)-1] += delay
+			continue
+		}
+		frames = append(frames, img)
+		delays = append(delays, delay)
+	}
+	return
+}
+
+// encodeGIFAnimation はアニメーションを GIF で出力する。
+//
+// 2フレーム目以降は前のフレームから変化した範囲だけを書き出し、範囲内の変化して
+// いない画素は透過色にする。
+// 透過したピクセルを含む場合は、透過した部分に前のフレームが残らないように
+// 毎回背景に戻して全体を書き出す。
+func (i *Image) encodeGIFAnimation(w io.Writer, level int) error {
+	var (
+		frames, delays = i.animationFrames()
+		transparent    bool
+		global         c.Palette
+	)
+	if len(frames) < 1 {
+		return errors.New("gif: must provide at least one image")
+	}
+	for _, f := range frames {
+		transparent = transparent || hasTransparentPixel(f)
+	}
+	// 差分を書き出す場合は、変化していない画素のために透過色を空けておく
+	diff := !transparent && 1 < len(frames)
+	switch {
+	case 0 < level:
+		global = uniformPalette(paletteLevels[level])
+	case i.globalPalette:
+		global = quantize.Palette(frames, maxPaletteSize-1)
+		if diff {
+			global = withTransparent(global)
+		}
+	}
+
+	b := frames[0].Bounds()
+	g := &gif.GIF{
+		Delay:     delays,
+		LoopCount: gifLoopCount(i.loop),
+		Config: image.Config{
+			Width:  b.Dx(),
+			Height: b.Dy(),
+		},
+	}
+	// 全てのフレームが同じパレットの場合は、グローバルカラーテーブルに
+	// してフレームごとのカラーテーブルを省く
+	if global != nil {
+		g.Config.ColorModel = global
+	}
+
+	var prev image.Image
+	for _, f := range frames {
+		rect := f.Bounds()
+		if diff && prev != nil {
+			rect = diffRect(prev, f)
+		}
+		sub := image.NewRGBA(rect)
+		draw.Draw(sub, rect, f, rect.Min, draw.Src)
+
+		var p *image.Paletted
+		switch {
+		case 0 < level:
+			p = toUniformPaletted(sub, paletteLevels[level])
+		case global != nil:
+			p = quantize.Paletted(sub, global, i.dither)
+		case diff && prev != nil:
+			pal := withTransparent(quantize.Palette([]image.Image{sub}, maxPaletteSize-1))
+			p = quantize.Paletted(sub, pal, i.dither)
+		default:
+			p = i.toPaletted(sub)
+		}
+
+		disposal := byte(gif.DisposalNone)
+		if transparent {
+			disposal = gif.DisposalBackground
+		}
+		if diff && prev != nil {
+			p = smallerFrame(p, clearUnchanged(p, prev, f))
+		}
+
+		g.Image = append(g.Image, p)
+		g.Disposal = append(g.Disposal, disposal)
+		prev = f
+	}
+	return gif.EncodeAll(w, g)
+}
+
+// toPaletted は画像で使われている色からパレットを作り、GIF 用のパレット画像に
+// 変換する。
+// 透過したピクセルが存在する場合は透過色をパレットに含める。
+func (i *Image) toPaletted(img image.Image) *image.Paletted {
+	pal := quantize.Palette([]image.Image{img}, maxPaletteSize)
+	return quantize.Paletted(img, pal, i.dither)
+}
+
+// gifLoopCount はループ回数を GIF の形式にする。
+// GIF は 0 が無限、-1 が1回だけ再生、n が n+1 回再生を表す。
+func gifLoopCount(loop int) int {
+	switch loop {
+	case 0:
+		return 0
+	case 1:
+		return -1
+	}
+	return loop - 1
+}
+
+// diffRect は a と b で色が違う画素を含む最小の範囲を返す。
+// 同じ画像の場合は左上の1画素を返す。
+func diffRect(a, b image.Image) image.Rectangle {
+	var rect image.Rectangle
+	bounds := b.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if !sameColor(a.At(x, y), b.At(x, y)) {
+				rect = rect.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	if rect.Empty() {
+		return image.Rect(bounds.Min.X, bounds.Min.Y, bounds.Min.X+1, bounds.Min.Y+1)
+	}
+	return rect
+}
+
+// clearUnchanged は p の範囲のうち、前のフレーム prev から変化していない画素を
+// 透過色にした画像を返す。
+func clearUnchanged(p *image.Paletted, prev, cur image.Image) *image.Paletted {
+	ti := -1
+	for j, col := range p.Palette {
+		if _, _, _, a := col.RGBA(); a == 0 {
+			ti = j
+			break
+		}
+	}
+	if ti < 0 {
+		return p
+	}
+	ret := image.NewPaletted(p.Rect, p.Palette)
+	copy(ret.Pix, p.Pix)
+	b := p.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if sameColor(prev.At(x, y), cur.At(x, y)) {
+				ret.SetColorIndex(x, y, uint8(ti))
+			}
+		}
+	}
+	return ret
+}
+
+// smallerFrame は圧縮したときに小さくなる方のフレームを返す。
+// 透過色の画素が文字の中に混ざると、かえって圧縮しにくくなることがある。
+func smallerFrame(a, b *image.Paletted) *image.Paletted {
+	if a == b {
+		return a
+	}
+	if encodedSize(b) < encodedSize(a) {
+		return b
+	}
+	return a
+}
+
+func encodedSize(p *image.Paletted) int {
+	var w countWriter
+	if err := gif.Encode(&w, p, nil); err != nil {
+		return math.MaxInt
+	}
+	return int(w)
+}
+
+// countWriter は書き込まれたバイト数を数える。
+type countWriter int
+
+func (w *countWriter) Write(b []byte) (int, error) {
+	*w += countWriter(len(b))
+	return len(b), nil
+}
+
+// withTransparent はパレットの末尾に透過色を追加する。
+func withTransparent(pal c.Palette) c.Palette {
+	for _, col := range pal {
+		if _, _, _, a := col.RGBA(); a == 0 {
+			return pal
+		}
+	}
+	return append(pal, c.RGBA{})
+}
+
+// sameImage は a と b が同じ画像かを判定する。
+func sameImage(a, b image.Image) bool {
+	if a.Bounds() != b.Bounds() {
+		return false
+	}
+	ra, ok1 := a.(*image.RGBA)
+	rb, ok2 := b.(*image.RGBA)
+	if ok1 && ok2 && ra.Stride == rb.Stride {
+		return string(ra.Pix) == string(rb.Pix)
+	}
+	bounds := a.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if !sameColor(a.At(x, y), b.At(x, y)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func sameColor(a, b c.Color) bool {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	return ar == br && ag == bg && ab == bb && aa == ba
+}
+
+// hasTransparentPixel は img に半分以上透けたピクセルが存在するかを判定する。
+func hasTransparentPixel(img image.Image) bool {
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			_, _, _, a := img.At(x, y).RGBA()
+			if a < 0x8000 {
+				return true
+			}
+		}
+	}
+	return false
+}
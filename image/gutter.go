@@ -0,0 +1,130 @@
+package image
+
+import (
+	"image"
+	c "image/color"
+	"image/draw"
+	"strconv"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// LineRange は From 行目から To 行目までの行の範囲。To の行も含む。
+type LineRange struct {
+	From int
+	To   int
+}
+
+// gutterColumns は行番号を表示する領域の幅 (セル数) を返す。
+// 行番号の右側には1セル分の間隔を空ける。
+func (p *ImageParam) gutterColumns() int {
+	if !p.LineNumbers {
+		return 0
+	}
+	last := p.StartLineNumber + p.BaseHeight - 1
+	switch {
+	case 0 < p.LastLineNumber:
+		last = p.LastLineNumber
+	case 0 < len(p.RowLines):
+		last = p.StartLineNumber + p.RowLines[len(p.RowLines)-1]
+	}
+	return len(strconv.Itoa(last)) + 1
+}
+
+// textOriginX は文字を描画し始めるX座標を返す。
+// 行番号を表示する場合は行番号の領域の右側から描画するので、エスケープシーケ
+// ンスの文字の位置はずれない。
+func (i *Image) textOriginX() int {
+	return i.padding.Left + i.gutterColumns*i.charWidth
+}
+
+// contentRows は文字が存在する行数を返す。
+// 行数を指定してキャンバスを広げた場合、それ以降の行は空行になる。
+func (i *Image) contentRows() int {
+	if i.rowLines != nil && len(i.rowLines) < i.rowCount {
+		return len(i.rowLines)
+	}
+	return i.rowCount
+}
+
+// lineNumber は row 行目 (0始まり) に表示する行番号を返す。
+// 折り返した行の場合は折り返す前の行の行番号を返す。
+func (i *Image) lineNumber(row int) int {
+	if row < len(i.rowLines) {
+		return i.startLineNumber + i.rowLines[row]
+	}
+	return i.startLineNumber + row
+}
+
+// isWrappedRow は row 行目が折り返しによって生じた行かを判定する。
+func (i *Image) isWrappedRow(row int) bool {
+	return 0 < row && i.lineNumber(row-1) == i.lineNumber(row)
+}
+
+// isHighlighted は n 行目が強調表示する行かを判定する。
+func (i *Image) isHighlighted(n int) bool {
+	for _, r := range i.highlightLines {
+		if r.From <= n && n <= r.To {
+			return true
+		}
+	}
+	return false
+}
+
+// drawHighlightLines は強調表示する行全体に色を重ねる。
+func (i *Image) drawHighlightLines(dst *image.RGBA) {
+	if len(i.highlightLines) < 1 {
+		return
+	}
+
+	var (
+		b     = dst.Bounds()
+		left  = i.padding.Left
+		right = b.Max.X - i.padding.Right
+		hc    = i.highlightColor
+		// 強調表示の色は乗算済みでないアルファ値として重ねる
+		src = image.NewUniform(c.NRGBA{R: hc.R, G: hc.G, B: hc.B, A: hc.A})
+	)
+	for row := 0; row < i.contentRows(); row++ {
+		if !i.isHighlighted(i.lineNumber(row)) {
+			continue
+		}
+		y := i.padding.Top + row*i.charHeight
+		r := image.Rect(left, y, right, y+i.charHeight)
+		draw.Draw(dst, r, src, image.Point{}, draw.Over)
+	}
+}
+
+// drawLineNumbers は行番号を右寄せで描画する。
+// 文字色を半透明にして薄く表示する。
+func (i *Image) drawLineNumbers(dst *image.RGBA) {
+	if i.gutterColumns < 1 {
+		return
+	}
+
+	fg := i.defaultForegroundColor
+	d := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(c.NRGBA{R: fg.R, G: fg.G, B: fg.B, A: fg.A / 2}),
+		Face: i.fontFace,
+	}
+	for row := 0; row < i.contentRows(); row++ {
+		// 折り返した行には行番号を表示しない
+		if i.isWrappedRow(row) {
+			continue
+		}
+		var (
+			s = strconv.Itoa(i.lineNumber(row))
+			// 間隔の1セル分を除いた領域に右寄せする
+			col = i.gutterColumns - 1 - len(s)
+			x   = i.padding.Left + col*i.charWidth
+			y   = i.padding.Top + row*i.charHeight + i.baseline
+		)
+		for _, r := range s {
+			d.Dot = fixed.P(x, y)
+			d.DrawString(string(r))
+			x += i.charWidth
+		}
+	}
+}
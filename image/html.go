@@ -0,0 +1,19 @@
+package image
+
+import (
+	"io"
+
+	"github.com/jiro4989/textimg/v3/color"
+	"github.com/jiro4989/textimg/v3/html"
+)
+
+// encodeHTML は SetTokens で設定したトークンを HTML で出力する。
+func (i *Image) encodeHTML(w io.Writer) error {
+	return html.Encode(w, i.tokens, &html.Options{
+		ForegroundColor: color.RGBA(i.defaultForegroundColor),
+		BackgroundColor: color.RGBA(i.defaultBackgroundColor),
+		Transparent:     i.transparent,
+		Fragment:        i.htmlFragment,
+		UseClasses:      i.htmlClasses,
+	})
+}
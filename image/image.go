@@ -1,12 +1,14 @@
 package image
 
 import (
+	"fmt"
 	"image"
 	c "image/color"
 	"image/draw"
-	"os"
+	"io/fs"
 
 	"github.com/jiro4989/textimg/v3/color"
+	"github.com/jiro4989/textimg/v3/internal/width"
 	"github.com/jiro4989/textimg/v3/token"
 	"github.com/mattn/go-runewidth"
 	"github.com/oliamb/cutter"
@@ -17,12 +19,17 @@ import (
 
 type (
 	Image struct {
-		image                     *image.RGBA
+		image                     *image.RGBA // Draw で確保する
+		canvasWidth               int
+		canvasHeight              int
+		tokens                    token.Tokens // 描画する、または HTML に出力するトークン
 		animationImages           []image.Image
+		animationDelays           []int // フレームごとの表示時間。nil の場合は全て delay
 		x                         int
 		y                         int
 		foregroundColor           c.RGBA // 文字色
 		backgroundColor           c.RGBA // 背景色
+		backgroundIsDefault       bool   // 背景色がデフォルトのままかどうか
 		defaultForegroundColor    c.RGBA // 文字色
 		defaultBackgroundColor    c.RGBA // 背景色
 		fontSize                  int    // フォントサイズ
@@ -30,15 +37,62 @@ type (
 		emojiFontFace             font.Face
 		charWidth                 int
 		charHeight                int
-		emojiDir                  string
+		baseline                  int // セルの上端からベースラインまでの高さ
+		padding                   Padding
+		width                     *runewidth.Condition // 文字の表示幅の判定条件
+		emojiFS                   fs.FS
 		useEmoji                  bool
-		lineCount                 int
 		useAnimation              bool
 		animationLineCount        int
 		animationImageFlameHeight int
+		typewriter                bool
+		typeUnit                  string
+		typeStep                  int
+		newlinePause              int
+		cursor                    string
+		marqueeWidth              int
+		marqueeStep               int
+		marqueeStepPixels         int
+		marqueeForever            bool
+		transition                string
+		transitionSteps           int
+		slideWidth                int
+		playback                  string
 		resizeWidth               int
 		resizeHeight              int
+		fit                       string
+		anchor                    string
+		resample                  string
+		scaleFactor               int
+		maxBytes                  int
+		fontData                  []byte
+		embedFont                 bool
+		pageRows                  int
+		pageMargin                int
 		delay                     int
+		loop                      int
+		lastFrameDelay            int
+		dither                    string
+		globalPalette             bool
+		frameStyle                string
+		title                     string
+		frameMargin               int
+		frameMarginColor          c.RGBA
+		transparent               bool
+		keepANSIBackground        bool
+		backgroundGradient        *Gradient
+		backgroundImage           image.Image
+		backgroundImageMode       string
+		rowCount                  int
+		columnCount               int
+		spans                     []span // 文字の配置
+		gutterColumns             int
+		startLineNumber           int
+		rowLines                  []int
+		highlightLines            []LineRange
+		highlightColor            c.RGBA
+		htmlFragment              bool
+		htmlClasses               bool
 	}
 	ImageParam struct {
 		BaseWidth          int
@@ -48,40 +102,100 @@ type (
 		FontSize           int    // フォントサイズ
 		FontFace           font.Face
 		EmojiFontFace      font.Face
-		EmojiDir           string
+		EmojiFS            fs.FS // 絵文字の画像ファイル (emoji_uXXXX.png) を置いたファイルシステム
+		EastAsianWidth     bool  // East Asian Width が曖昧な文字を幅2として扱う
 		UseEmoji           bool
 		UseAnimation       bool
 		AnimationLineCount int
+		FrameDelays        []int  // フレームごとのディレイ時間。0 の場合は Delay と同じ
+		Typewriter         bool   // 文字が1つずつ現れるアニメーションにする
+		TypeUnit           string // タイプライターアニメーションで一度に表示する単位
+		TypeStep           int    // タイプライターアニメーションの1フレームで表示する単位の数
+		NewlinePause       int    // タイプライターアニメーションの行末で止まる時間
+		Cursor             string // タイプライターアニメーションのカーソルのスタイル
+		MarqueeWidth       int    // マーキーアニメーションの表示領域の列数。0 の場合はマーキーにしない
+		MarqueeStep        int    // マーキーアニメーションの1フレームでスクロールする列数
+		MarqueeStepPixels  int    // マーキーアニメーションの1フレームでスクロールする幅 (px)。MarqueeStep より優先する
+		MarqueeForever     bool   // マーキーアニメーションを途切れずに繰り返す
+		Transition         string // アニメーションのフレームの切り替え方
+		TransitionSteps    int    // フレームの間に挿入する切り替え途中のフレームの数
+		SlideWidth         int    // スライドアニメーションで1フレームごとに進む行数。0 の場合はスライドしない
+		Playback           string // アニメーションの再生方法
 		ResizeWidth        int
 		ResizeHeight       int
+		Fit                string // リサイズ時の縦横比の扱い
+		Anchor             string // リサイズ時に切り抜く、または余白を空ける基準位置
+		Resample           string // リサイズ時の補間方法
+		Scale              int    // 描画倍率。フォントサイズなどは呼び出し側で倍率をかけておくこと
+		MaxBytes           int    // 出力するファイルの最大サイズ (byte)。0 の場合は制限なし
+		FontData           []byte // SVG で使うフォントファイルの中身
+		EmbedFont          bool   // SVG にフォントを埋め込む
+		PageRows           int    // PDF の1ページの行数。0 の場合は1ページにまとめる
+		PageMargin         int    // PDF のページの余白 (pt)
 		Delay              int
+		Loop               int    // アニメーションのループ回数。0 の場合は無限にループする
+		LastFrameDelay     int    // アニメーションの最後のフレームのディレイ時間。0 の場合は Delay と同じ
+		Dither             string // GIF の減色時のディザリングの方法
+		GlobalPalette      bool   // アニメーションGIFの全てのフレームで同じパレットを使う
+		Padding            Padding
+		LineSpacing        int    // 行間 (px)
+		LetterSpacing      int    // 文字間 (px)
+		FrameStyle         string // ウィンドウ枠のスタイル
+		Title              string // ウィンドウ枠のタイトル
+		FrameMargin        int    // ウィンドウ枠の外側の余白 (px)
+		FrameMarginColor   c.RGBA // ウィンドウ枠の外側の余白の色
+		Transparent        bool   // 背景を透過する
+		KeepANSIBackground bool   // 透過時もエスケープシーケンスで指定された背景色は描画する
+		// 背景色の上に重ねるグラデーション
+		BackgroundGradient *Gradient
+		// 背景色とグラデーションの上に重ねる背景画像
+		BackgroundImage     image.Image
+		BackgroundImageMode string
+		LineNumbers         bool // 行番号を表示する
+		StartLineNumber     int  // 1行目に表示する行番号
+		// 行番号の桁数を決める最後の行番号。0 の場合は RowLines と BaseHeight から
+		// 決める。分割した画像の行番号の桁数を揃えるために使う
+		LastLineNumber int
+		// 各行が入力の何行目 (0始まり) だったか。nil の場合は各行がそのまま入力の行になる
+		RowLines       []int
+		HighlightLines []LineRange // 強調表示する行の範囲
+		HighlightColor c.RGBA      // 強調表示する行に重ねる色
+		HTMLFragment   bool        // HTML に <pre> 要素だけを出力する
+		HTMLClasses    bool        // HTML の16色の色指定をクラスにする
 	}
 )
 
-func init() {
-	// Unicode Neutral で定義されている絵文字(例: 👁)を幅2として扱う
-	runewidth.DefaultCondition.StrictEmojiNeutral = false
-}
-
 func NewImage(p *ImageParam) *Image {
 	var (
-		charWidth   = p.FontSize / 2
-		charHeight  = int(float64(p.FontSize) * 1.1)
-		imageWidth  = p.BaseWidth * charWidth
-		imageHeight = p.BaseHeight * charHeight
+		charWidth, charHeight, baseline = charSize(p.FontFace, p.LineSpacing, p.LetterSpacing)
+		canvasWidth, canvasHeight       = p.canvasSize()
 	)
 
 	var animationImageFlameHeight int
 	if p.UseAnimation {
-		animationImageFlameHeight = imageHeight / (p.BaseHeight / p.AnimationLineCount)
+		animationImageFlameHeight = charHeight * p.AnimationLineCount
+	}
+
+	var animationDelays []int
+	for _, d := range p.FrameDelays {
+		if d == 0 {
+			d = p.Delay
+		}
+		animationDelays = append(animationDelays, d)
 	}
 
-	image := newImage(imageWidth, imageHeight)
+	scaleFactor := p.Scale
+	if scaleFactor < 1 {
+		scaleFactor = 1
+	}
 
 	return &Image{
-		image:                     image,
+		canvasWidth:               canvasWidth,
+		canvasHeight:              canvasHeight,
+		animationDelays:           animationDelays,
 		foregroundColor:           p.ForegroundColor,
 		backgroundColor:           p.BackgroundColor,
+		backgroundIsDefault:       true,
 		defaultForegroundColor:    p.ForegroundColor,
 		defaultBackgroundColor:    p.BackgroundColor,
 		fontSize:                  p.FontSize,
@@ -89,84 +203,124 @@ func NewImage(p *ImageParam) *Image {
 		emojiFontFace:             p.EmojiFontFace,
 		charWidth:                 charWidth,
 		charHeight:                charHeight,
-		emojiDir:                  p.EmojiDir,
+		baseline:                  baseline,
+		padding:                   p.Padding,
+		width:                     width.New(p.EastAsianWidth),
+		emojiFS:                   p.EmojiFS,
 		useEmoji:                  p.UseEmoji,
 		useAnimation:              p.UseAnimation,
 		animationLineCount:        p.AnimationLineCount,
 		animationImageFlameHeight: animationImageFlameHeight,
+		typewriter:                p.Typewriter,
+		typeUnit:                  p.TypeUnit,
+		typeStep:                  max(p.TypeStep, 1),
+		newlinePause:              p.NewlinePause,
+		cursor:                    p.Cursor,
+		marqueeWidth:              p.MarqueeWidth,
+		marqueeStep:               p.MarqueeStep,
+		marqueeStepPixels:         p.MarqueeStepPixels,
+		marqueeForever:            p.MarqueeForever,
+		transition:                p.Transition,
+		transitionSteps:           p.TransitionSteps,
+		slideWidth:                p.SlideWidth,
+		playback:                  p.Playback,
 		resizeWidth:               p.ResizeWidth,
 		resizeHeight:              p.ResizeHeight,
+		fit:                       p.Fit,
+		anchor:                    p.Anchor,
+		resample:                  p.Resample,
+		scaleFactor:               scaleFactor,
+		maxBytes:                  p.MaxBytes,
+		fontData:                  p.FontData,
+		embedFont:                 p.EmbedFont,
+		pageRows:                  p.PageRows,
+		pageMargin:                p.PageMargin,
 		delay:                     p.Delay,
+		loop:                      p.Loop,
+		lastFrameDelay:            p.LastFrameDelay,
+		dither:                    p.Dither,
+		globalPalette:             p.GlobalPalette,
+		frameStyle:                p.FrameStyle,
+		title:                     p.Title,
+		frameMargin:               p.FrameMargin,
+		frameMarginColor:          p.FrameMarginColor,
+		transparent:               p.Transparent,
+		keepANSIBackground:        p.KeepANSIBackground,
+		backgroundGradient:        p.BackgroundGradient,
+		backgroundImage:           p.BackgroundImage,
+		backgroundImageMode:       p.BackgroundImageMode,
+		rowCount:                  p.BaseHeight,
+		columnCount:               p.BaseWidth,
+		gutterColumns:             p.gutterColumns(),
+		startLineNumber:           p.StartLineNumber,
+		rowLines:                  p.RowLines,
+		highlightLines:            p.HighlightLines,
+		highlightColor:            p.HighlightColor,
+		htmlFragment:              p.HTMLFragment,
+		htmlClasses:               p.HTMLClasses,
 	}
 }
 
+// RGBA は描画した画像を返す。
+// アニメーションの場合も、全ての行を1枚に描画した画像を返す。
+func (i *Image) RGBA() *image.RGBA {
+	return i.image
+}
+
 func newImage(w, h int) *image.RGBA {
 	return image.NewRGBA(image.Rect(0, 0, w, h))
 }
 
+// SetTokens は画像を描画せずに出力するトークンを設定する。
+// Format.Text の形式で出力する場合に Draw の代わりに使う。
+func (i *Image) SetTokens(tokens token.Tokens) {
+	i.tokens = tokens
+}
+
 func (i *Image) Draw(tokens token.Tokens) error {
-	i.drawBackgroundAll()
+	i.SetTokens(tokens)
+	i.image = newImage(i.canvasWidth, i.canvasHeight)
+	i.spans = i.layout(tokens)
 
 	// 背景のみ描画
-	for _, t := range tokens {
-		switch t.Kind {
-		case token.KindColor:
-			i.updateColor(t.ColorType, t.Color)
-		case token.KindText:
-			i.drawBackground(t.Text)
-			for _, r := range t.Text {
-				if isLinefeed(r) {
-					i.moveDown()
-					continue
-				}
-
-				i.moveRight(r)
-			}
-		}
+	i.drawBackgroundAll(i.image)
+	for _, sp := range i.spans {
+		i.drawBackground(sp)
 	}
-	i.resetColor()
-	i.resetPosition()
 
-	// 文字のみ描画
-	for _, t := range tokens {
-		switch t.Kind {
-		case token.KindColor:
-			i.updateColor(t.ColorType, t.Color)
-		case token.KindText:
-			for _, r := range t.Text {
-				if isLinefeed(r) {
-					i.moveDown()
-					continue
-				}
+	i.drawHighlightLines(i.image)
+	i.drawLineNumbers(i.image)
 
-				if err := i.draw(r); err != nil {
-					return err
-				}
-				i.moveRight(r)
+	// 文字のみ描画
+	for _, sp := range i.spans {
+		i.foregroundColor = sp.foregroundColor
+		i.x, i.y = i.cellX(sp.col), i.cellY(sp.row)
+		for _, r := range sp.text {
+			if err := i.draw(r); err != nil {
+				return err
 			}
+			i.x += i.width.RuneWidth(r) * i.charWidth
 		}
 	}
+	i.resetColor()
 
 	if err := i.setAnimationFlames(); err != nil {
 		return err
 	}
+	i.applyTransition()
+	i.composeBackground()
+	i.applyFrame()
 	i.scale()
+	i.applyPlayback()
 
 	return nil
 }
 
 // 背景色をデフォルト色で塗りつぶす。
-func (i *Image) drawBackgroundAll() {
-	var (
-		bounds = i.image.Bounds().Max
-		width  = bounds.X
-		height = bounds.Y
-	)
-	for x := 0; x < width; x++ {
-		for y := 0; y < height; y++ {
-			i.image.Set(x, y, c.RGBA(i.defaultBackgroundColor))
-		}
-	}
+// 透過色の場合もアルファ値を保ったまま上書きする。
+// グラデーションや背景画像は文字の描画後に composeBackground で合成する。
+func (i *Image) drawBackgroundAll(dst *image.RGBA) {
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(i.baseBackgroundColor()), image.Point{}, draw.Src)
 }
 
 func (i *Image) updateColor(t token.ColorType, col color.RGBA) {
@@ -177,34 +331,33 @@ func (i *Image) updateColor(t token.ColorType, col color.RGBA) {
 		i.foregroundColor = i.defaultForegroundColor
 	case token.ColorTypeResetBackground:
 		i.backgroundColor = i.defaultBackgroundColor
+		i.backgroundIsDefault = true
 	case token.ColorTypeReverse:
 		i.foregroundColor, i.backgroundColor = i.backgroundColor, i.foregroundColor
+		i.backgroundIsDefault = false
 	case token.ColorTypeForeground:
 		i.foregroundColor = c.RGBA(col)
 	case token.ColorTypeBackground:
+		if i.transparent && !i.keepANSIBackground {
+			return
+		}
 		i.backgroundColor = c.RGBA(col)
+		i.backgroundIsDefault = false
 	}
 }
 
 func (i *Image) resetColor() {
 	i.foregroundColor = i.defaultForegroundColor
 	i.backgroundColor = i.defaultBackgroundColor
-}
-
-func (i *Image) resetPosition() {
-	i.x = 0
-	i.y = 0
-	i.lineCount = 0
+	i.backgroundIsDefault = true
 }
 
 func (i *Image) newDrawer(f font.Face) *font.Drawer {
-	// 特殊な位置調整。なんでこんな計算式にしたのか覚えていない
 	var (
 		x = i.x
-		y = i.y + i.charHeight - (i.charHeight / 5)
+		y = i.y + i.baseline
 	)
-	// FIXME: なんか警告出てる
-	point := fixed.Point26_6{fixed.Int26_6(x * 64), fixed.Int26_6(y * 64)}
+	point := fixed.P(x, y)
 	d := &font.Drawer{
 		Dst:  i.image,
 		Src:  image.NewUniform(c.RGBA(i.foregroundColor)),
@@ -215,7 +368,7 @@ func (i *Image) newDrawer(f font.Face) *font.Drawer {
 }
 
 func (i *Image) draw(r rune) error {
-	if ok, emojiPath := isEmoji(r, i.emojiDir); ok {
+	if ok, emojiPath := isEmoji(r, i.emojiFS); ok {
 		if i.useEmoji {
 			i.drawRune(r, i.emojiFontFace)
 			return nil
@@ -227,12 +380,24 @@ func (i *Image) draw(r rune) error {
 }
 
 func (i *Image) setAnimationFlames() error {
+	if i.typewriter {
+		i.setTypewriterFrames()
+		return nil
+	}
+	if 0 < i.marqueeWidth {
+		i.setMarqueeFrames()
+		return nil
+	}
 	if i.useAnimation {
 		b := i.image.Bounds().Max
 		w, h := b.X, i.animationImageFlameHeight
-		max := b.Y / i.animationImageFlameHeight
+		if h < 1 {
+			return fmt.Errorf("animation line count must be positive. line count = %d", i.animationLineCount)
+		}
+		textHeight := b.Y - i.padding.Top - i.padding.Bottom
+		max := (textHeight + h - 1) / h
 		for rc := 0; rc < max; rc++ {
-			x, y := 0, rc*h
+			x, y := 0, i.padding.Top+rc*h
 			pt := image.Pt(x, y)
 			cimg, err := cutter.Crop(i.image, cutter.Config{
 				Width:   w,
@@ -244,11 +409,11 @@ func (i *Image) setAnimationFlames() error {
 			if err != nil {
 				return err
 			}
-			dist := image.NewRGBA(image.Rectangle{
-				image.Pt(0, 0),
-				image.Pt(w, h),
-			})
-			draw.Draw(dist, dist.Bounds(), cimg, pt, draw.Over)
+			// 上下の余白を含めた1フレーム分の画像を作る
+			dist := newImage(w, h+i.padding.Top+i.padding.Bottom)
+			draw.Draw(dist, dist.Bounds(), image.NewUniform(i.baseBackgroundColor()), image.Point{}, draw.Src)
+			r := image.Rect(0, i.padding.Top, w, i.padding.Top+h)
+			draw.Draw(dist, r, cimg, cimg.Bounds().Min, draw.Over)
 			i.animationImages = append(i.animationImages, dist)
 		}
 	}
@@ -263,7 +428,7 @@ func (i *Image) drawRune(r rune, f font.Face) {
 }
 
 func (i *Image) drawEmoji(r rune, path string) error {
-	fp, err := os.Open(path)
+	fp, err := i.emojiFS.Open(path)
 	if err != nil {
 		return err
 	}
@@ -287,56 +452,16 @@ func (i *Image) drawEmoji(r rune, path string) error {
 	return nil
 }
 
-func (i *Image) drawBackground(s string) {
-	var (
-		tw     = runewidth.StringWidth(s)
-		width  = tw * i.charWidth
-		height = i.charHeight
-		posX   = i.x
-		posY   = i.y
-	)
-	for x := posX; x < posX+width; x++ {
-		for y := posY; y < posY+height; y++ {
-			i.image.Set(x, y, c.RGBA(i.backgroundColor))
-		}
-	}
-}
-
-func (i *Image) moveRight(r rune) {
-	i.x += runewidth.RuneWidth(r) * i.charWidth
-}
-
-func (i *Image) moveDown() {
-	i.x = 0
-	i.y += i.charHeight
-	i.lineCount++
-}
-
-func (i *Image) newScaledImage() *image.RGBA {
-	if i.resizeWidth == 0 && i.resizeHeight == 0 {
-		return i.image
-	}
-
-	// 呼び出し側で大きさを調整していること
-	dst := scale(i.image, i.resizeWidth, i.resizeHeight)
-	return dst
-}
-
-func scale(img image.Image, w, h int) *image.RGBA {
-	rect := img.Bounds()
-	dst := newImage(w, h)
-	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, rect, draw.Over, nil)
-	return dst
-}
-
-func (i *Image) scale() {
-	if i.resizeWidth == 0 && i.resizeHeight == 0 {
+func (i *Image) drawBackground(sp span) {
+	// デフォルトの背景色の部分は背景レイヤーが透けて見えるように描画しない
+	if sp.backgroundIsDefault {
 		return
 	}
 
-	i.image = i.newScaledImage()
-	for j, img := range i.animationImages {
-		dst := scale(img, i.resizeWidth, i.resizeHeight)
-		i.animationImages[j] = dst
-	}
+	var (
+		x    = i.cellX(sp.col)
+		y    = i.cellY(sp.row)
+		rect = image.Rect(x, y, x+sp.width*i.charWidth, y+i.charHeight)
+	)
+	draw.Draw(i.image, rect, image.NewUniform(sp.backgroundColor), image.Point{}, draw.Src)
 }
@@ -0,0 +1,772 @@
+package image
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	c "image/color"
+	"image/draw"
+	"image/gif"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jiro4989/textimg/v3/color"
+	"github.com/jiro4989/textimg/v3/internal/width"
+	"github.com/jiro4989/textimg/v3/token"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/gofont/gomono"
+)
+
+// newFilledImage は col で塗りつぶした w x h の画像を返す。
+func newFilledImage(w, h int, col c.RGBA) *image.RGBA {
+	img := newImage(w, h)
+	draw.Draw(img, img.Bounds(), image.NewUniform(col), image.Point{}, draw.Src)
+	return img
+}
+
+// drawTestImage は basicfont の 7x13 のフォントで、白い文字と黒い背景で text
+// を描画した画像を返す。行数と列数は text に合わせる。
+func drawTestImage(t *testing.T, text string, p ImageParam) *Image {
+	p.ForegroundColor, p.BackgroundColor = white, black
+	p.FontFace = basicfont.Face7x13
+	p.FontSize = 13
+	lines := strings.Split(text, "\n")
+	p.BaseHeight = len(lines)
+	for _, l := range lines {
+		p.BaseWidth = max(p.BaseWidth, len(l))
+	}
+	img := NewImage(&p)
+	assert.NoError(t, img.Draw(token.Tokens{token.NewText(text)}))
+	return img
+}
+
+// cellColor は img の frame 番目のフレームの row 行 col 列のセルの中心の色を返
+// す。
+func cellColor(img *Image, frame, row, col int) c.RGBA {
+	dst := toRGBA(img.animationImages[frame])
+	return dst.RGBAAt(img.cellX(col)+img.charWidth/2, img.cellY(row)+img.charHeight/2)
+}
+
+// hasInk は img の frame 番目のフレームの row 行 col 列のセルに、背景色以外の
+// 画素があるかを返す。
+func hasInk(img *Image, frame, row, col int) bool {
+	var (
+		dst  = toRGBA(img.animationImages[frame])
+		x, y = img.cellX(col), img.cellY(row)
+	)
+	for py := y; py < y+img.charHeight; py++ {
+		for px := x; px < x+img.charWidth; px++ {
+			if dst.RGBAAt(px, py) != black {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var (
+	black = c.RGBA{A: 255}
+	white = c.RGBA{R: 255, G: 255, B: 255, A: 255}
+	red   = c.RGBA{R: 255, A: 255}
+)
+
+func TestImage_encodeGIFAnimation(t *testing.T) {
+	// 左上の 2x2 だけが赤い画像
+	dot := newFilledImage(8, 8, black)
+	draw.Draw(dot, image.Rect(0, 0, 2, 2), image.NewUniform(red), image.Point{}, draw.Src)
+
+	tests := []struct {
+		desc          string
+		frames        []image.Image
+		loop          int
+		wantDelays    []int
+		wantRects     []image.Rectangle
+		wantLoopCount int
+		wantErr       bool
+	}{
+		{
+			desc:       "正常系: 2フレーム目は変化した範囲だけを書き出す",
+			frames:     []image.Image{newFilledImage(8, 8, black), dot},
+			wantDelays: []int{10, 10},
+			wantRects:  []image.Rectangle{image.Rect(0, 0, 8, 8), image.Rect(0, 0, 2, 2)},
+		},
+		{
+			desc:       "正常系: 変化しないフレームは1つにまとめて表示時間を足す",
+			frames:     []image.Image{dot, dot, newFilledImage(8, 8, black)},
+			wantDelays: []int{20, 10},
+			wantRects:  []image.Rectangle{image.Rect(0, 0, 8, 8), image.Rect(0, 0, 2, 2)},
+		},
+		{
+			desc:          "正常系: ループ回数",
+			frames:        []image.Image{newFilledImage(8, 8, black), dot},
+			loop:          3,
+			wantDelays:    []int{10, 10},
+			wantRects:     []image.Rectangle{image.Rect(0, 0, 8, 8), image.Rect(0, 0, 2, 2)},
+			wantLoopCount: 2,
+		},
+		{
+			desc:    "異常系: フレームがない",
+			frames:  nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			img := &Image{
+				useAnimation:    true,
+				animationImages: tt.frames,
+				delay:           10,
+				loop:            tt.loop,
+			}
+			var buf bytes.Buffer
+			err := img.encodeGIFAnimation(&buf, 0)
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+
+			g, err := gif.DecodeAll(&buf)
+			assert.NoError(err)
+			assert.Equal(tt.wantDelays, g.Delay)
+			assert.Equal(tt.wantLoopCount, g.LoopCount)
+			var rects []image.Rectangle
+			for _, p := range g.Image {
+				rects = append(rects, p.Bounds())
+			}
+			assert.Equal(tt.wantRects, rects)
+		})
+	}
+}
+
+func TestGifLoopCount(t *testing.T) {
+	tests := []struct {
+		desc string
+		loop int
+		want int
+	}{
+		{desc: "正常系: 0 は無限", loop: 0, want: 0},
+		{desc: "正常系: 1回だけ再生", loop: 1, want: -1},
+		{desc: "正常系: 3回再生", loop: 3, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert.Equal(t, tt.want, gifLoopCount(tt.loop))
+		})
+	}
+}
+
+func TestDiffRect(t *testing.T) {
+	dot := newFilledImage(8, 8, black)
+	dot.Set(3, 4, white)
+
+	tests := []struct {
+		desc string
+		a, b image.Image
+		want image.Rectangle
+	}{
+		{
+			desc: "正常系: 変化した画素を含む範囲",
+			a:    newFilledImage(8, 8, black),
+			b:    dot,
+			want: image.Rect(3, 4, 4, 5),
+		},
+		{
+			desc: "正常系: 変化しない場合は左上の1画素",
+			a:    dot,
+			b:    dot,
+			want: image.Rect(0, 0, 1, 1),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert.Equal(t, tt.want, diffRect(tt.a, tt.b))
+		})
+	}
+}
+
+func TestClearUnchanged(t *testing.T) {
+	assert := assert.New(t)
+
+	// 何も変化していない範囲は全て透過色になる
+	img := newFilledImage(4, 4, black)
+	pal := withTransparent(c.Palette{black})
+	p := image.NewPaletted(img.Bounds(), pal)
+	got := clearUnchanged(p, img, img)
+	for _, idx := range got.Pix {
+		assert.Equal(uint8(len(pal)-1), idx)
+	}
+}
+
+func TestImage_isHighlighted(t *testing.T) {
+	img := &Image{highlightLines: []LineRange{{From: 3, To: 3}, {From: 1000, To: 1000000000}}}
+	tests := []struct {
+		desc string
+		n    int
+		want bool
+	}{
+		{desc: "正常系: 1行の範囲", n: 3, want: true},
+		{desc: "正常系: 範囲の先頭", n: 1000, want: true},
+		{desc: "正常系: 範囲の末尾", n: 1000000000, want: true},
+		{desc: "正常系: 範囲外", n: 4, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert.Equal(t, tt.want, img.isHighlighted(tt.n))
+		})
+	}
+}
+
+func TestImage_drawHighlightLines(t *testing.T) {
+	tests := []struct {
+		desc string
+		row  int
+		want c.RGBA
+	}{
+		{desc: "正常系: 強調表示する行は背景色に半透明の色を重ねる", row: 1, want: c.RGBA{R: 64, G: 64, A: 255}},
+		{desc: "正常系: 強調表示しない行は背景色のまま", row: 0, want: black},
+	}
+	img := drawTestImage(t, "a \nb ", ImageParam{
+		StartLineNumber: 1,
+		HighlightLines:  []LineRange{{From: 2, To: 2}},
+		HighlightColor:  c.RGBA{R: 255, G: 255, A: 64},
+	})
+	dst := toRGBA(img.image)
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got := dst.RGBAAt(img.cellX(1)+img.charWidth/2, img.cellY(tt.row)+img.charHeight/2)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFormatExtensions(t *testing.T) {
+	tests := []struct {
+		desc  string
+		match func(Format) bool
+		want  []string
+	}{
+		{
+			desc:  "正常系: アニメーションに対応した形式",
+			match: func(f Format) bool { return f.Animation },
+			want:  []string{".png", ".apng", ".gif", ".webp"},
+		},
+		{
+			desc:  "正常系: 背景の透過に対応した形式",
+			match: func(f Format) bool { return f.Transparency },
+			want:  []string{".png", ".apng", ".gif", ".webp", ".svg", ".pdf", ".html"},
+		},
+		{
+			desc:  "正常系: 一致する形式がない",
+			match: func(f Format) bool { return false },
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert.Equal(t, tt.want, FormatExtensions(tt.match))
+		})
+	}
+}
+
+func TestDrawWindowsButtons(t *testing.T) {
+	assert := assert.New(t)
+
+	bar := image.Rect(0, 0, 90, 18)
+	dst := newFilledImage(bar.Dx(), bar.Dy(), black)
+	drawWindowsButtons(dst, bar, white)
+
+	// 各ボタンの描画は幅 size の範囲に収まる
+	var (
+		size = bar.Dy() / 3
+		w    = bar.Dy() * 3 / 2
+		cy   = bar.Dy() / 2
+	)
+	for j := 0; j < 3; j++ {
+		cx := bar.Max.X - w*j - w/2
+		r := image.Rect(cx-size/2, cy-size/2, cx+size/2, cy+size/2)
+		for y := r.Min.Y - 1; y <= r.Max.Y; y++ {
+			for x := r.Min.X - 1; x <= r.Max.X; x++ {
+				if image.Pt(x, y).In(r) {
+					continue
+				}
+				assert.Equal(black, dst.RGBAAt(x, y), "button %d (%d, %d)", j, x, y)
+			}
+		}
+	}
+	// 最大化のボタンは四隅を含む枠になる
+	cx := bar.Max.X - w - w/2
+	r := image.Rect(cx-size/2, cy-size/2, cx+size/2, cy+size/2)
+	for _, p := range []image.Point{r.Min, {r.Max.X - 1, r.Min.Y}, {r.Min.X, r.Max.Y - 1}, r.Max.Sub(image.Pt(1, 1))} {
+		assert.Equal(white, dst.RGBAAt(p.X, p.Y))
+	}
+}
+
+func TestImage_typeUnits(t *testing.T) {
+	// "ab cd" の "ab" と " cd" で色が違う
+	spans := []span{
+		{row: 0, col: 0, width: 2, text: "ab"},
+		{row: 0, col: 2, width: 3, text: " cd", foregroundColor: red},
+		{row: 1, col: 0, width: 2, text: "あ"},
+	}
+	tests := []struct {
+		desc     string
+		typeUnit string
+		want     []typeUnit
+	}{
+		{
+			desc:     "正常系: 1文字ずつ",
+			typeUnit: TypeUnitChar,
+			want: []typeUnit{
+				{row: 0, col: 0, width: 1}, {row: 0, col: 1, width: 1}, {row: 0, col: 2, width: 1},
+				{row: 0, col: 3, width: 1}, {row: 0, col: 4, width: 1}, {row: 1, col: 0, width: 2},
+			},
+		},
+		{
+			desc:     "正常系: 単語と後ろの空白ずつ",
+			typeUnit: TypeUnitWord,
+			want:     []typeUnit{{row: 0, col: 0, width: 3}, {row: 0, col: 3, width: 2}, {row: 1, col: 0, width: 2}},
+		},
+		{
+			desc:     "正常系: 色が変わる位置で区切る",
+			typeUnit: TypeUnitToken,
+			want:     []typeUnit{{row: 0, col: 0, width: 2}, {row: 0, col: 2, width: 3}, {row: 1, col: 0, width: 2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			img := &Image{spans: spans, typeUnit: tt.typeUnit, width: width.New(false)}
+			assert.Equal(t, tt.want, img.typeUnits())
+		})
+	}
+}
+
+func TestImage_setTypewriterFrames(t *testing.T) {
+	tests := []struct {
+		desc       string
+		text       string
+		step       int
+		wantDelays []int
+		// 各フレームでカーソルを表示するセル (行, 列)。-1 の場合は表示しない
+		wantCursors [][2]int
+	}{
+		{
+			desc:       "正常系: 1文字ずつ表示して行末で点滅する",
+			text:       "ab\nc",
+			step:       1,
+			wantDelays: []int{10, 10, 10, 50, 50, 20, 10, 50, 50, 20},
+			wantCursors: [][2]int{
+				{0, 0}, {0, 1}, {0, 2}, {-1, -1}, {0, 2}, {-1, -1},
+				{1, 1}, {-1, -1}, {1, 1}, {-1, -1},
+			},
+		},
+		{
+			desc:        "正常系: 2文字ずつ表示する",
+			text:        "abc",
+			step:        2,
+			wantDelays:  []int{10, 10, 10, 50, 50, 20},
+			wantCursors: [][2]int{{0, 0}, {0, 2}, {0, 3}, {-1, -1}, {0, 3}, {-1, -1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			img := drawTestImage(t, tt.text, ImageParam{
+				UseAnimation: true,
+				Typewriter:   true,
+				TypeUnit:     TypeUnitChar,
+				TypeStep:     tt.step,
+				NewlinePause: 120,
+				Cursor:       CursorBlock,
+				Delay:        10,
+			})
+			assert.Equal(tt.wantDelays, img.animationDelays)
+			assert.Len(img.animationImages, len(tt.wantDelays))
+			for j, cur := range tt.wantCursors {
+				if cur[0] < 0 {
+					// カーソルが消えている間は、最後に表示した文字の次のセルが背景になる
+					prev := tt.wantCursors[j-1]
+					assert.Equal(black, cellColor(img, j, prev[0], prev[1]), "frame %d", j)
+					continue
+				}
+				assert.Equal(white, cellColor(img, j, cur[0], cur[1]), "frame %d", j)
+			}
+		})
+	}
+}
+
+func TestImage_setMarqueeFrames(t *testing.T) {
+	tests := []struct {
+		desc    string
+		forever bool
+		// 各フレームで表示領域の左端と右端のセルに文字が見えるか
+		want [][2]bool
+	}{
+		{
+			desc:    "正常系: 右端から現れて左端に消える",
+			forever: false,
+			want:    [][2]bool{{false, false}, {false, true}, {true, true}, {true, true}, {true, false}},
+		},
+		{
+			desc:    "正常系: 途切れずに繰り返す",
+			forever: true,
+			want:    [][2]bool{{true, true}, {true, true}, {true, true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			img := drawTestImage(t, "MMM", ImageParam{
+				UseAnimation:   true,
+				MarqueeWidth:   2,
+				MarqueeStep:    1,
+				MarqueeForever: tt.forever,
+				Delay:          10,
+			})
+			assert.Len(img.animationImages, len(tt.want))
+			for j, w := range tt.want {
+				assert.Equal(image.Rect(0, 0, 2*img.charWidth, img.charHeight), img.animationImages[j].Bounds(), "frame %d", j)
+				assert.Equal(w[0], hasInk(img, j, 0, 0), "frame %d left", j)
+				assert.Equal(w[1], hasInk(img, j, 0, 1), "frame %d right", j)
+			}
+		})
+	}
+}
+
+func TestImage_applyTransition(t *testing.T) {
+	tests := []struct {
+		desc        string
+		delay       int
+		frameDelays []int
+		wantDelays  []int
+	}{
+		{
+			desc:       "正常系: 前のフレームの表示時間を前のフレームと途中のフレームで等分する",
+			delay:      20,
+			wantDelays: []int{5, 5, 5, 5, 20},
+		},
+		{
+			desc:        "正常系: フレームごとの表示時間",
+			delay:       20,
+			frameDelays: []int{40, 0},
+			wantDelays:  []int{10, 10, 10, 10, 20},
+		},
+		{
+			desc:       "正常系: 等分できない余りは前のフレームに足す",
+			delay:      22,
+			wantDelays: []int{7, 5, 5, 5, 22},
+		},
+		{
+			desc:       "正常系: 最小の表示時間より短くしない",
+			delay:      4,
+			wantDelays: []int{minTransitionDelay, minTransitionDelay, minTransitionDelay, minTransitionDelay, 4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			img := drawTestImage(t, "M\nW", ImageParam{
+				UseAnimation:       true,
+				AnimationLineCount: 1,
+				FrameDelays:        tt.frameDelays,
+				Transition:         TransitionFade,
+				TransitionSteps:    3,
+				Delay:              tt.delay,
+			})
+			assert.Equal(tt.wantDelays, img.animationDelays)
+			assert.Len(img.animationImages, len(tt.wantDelays))
+		})
+	}
+}
+
+func TestImage_transitionFrame(t *testing.T) {
+	var (
+		src  = newFilledImage(4, 2, white)
+		dst  = newFilledImage(4, 2, black)
+		gray = c.RGBA{R: 128, G: 128, B: 128, A: 255}
+	)
+	tests := []struct {
+		desc       string
+		transition string
+		// 行ごとの左から右の画素
+		want [][]c.RGBA
+	}{
+		{
+			desc:       "正常系: フェードは色を混ぜる",
+			transition: TransitionFade,
+			want:       [][]c.RGBA{{gray, gray, gray, gray}, {gray, gray, gray, gray}},
+		},
+		{
+			desc:       "正常系: ワイプは左から次のフレームに置き換える",
+			transition: TransitionWipe,
+			want:       [][]c.RGBA{{black, black, white, white}, {black, black, white, white}},
+		},
+		{
+			desc:       "正常系: スクロールは前のフレームを上にずらす",
+			transition: TransitionScroll,
+			want:       [][]c.RGBA{{white, white, white, white}, {black, black, black, black}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			img := &Image{transition: tt.transition, animationImageFlameHeight: 2}
+			got := img.transitionFrame(src, dst, 0.5)
+			for y, row := range tt.want {
+				for x, want := range row {
+					assert.Equal(t, want, got.RGBAAt(x, y), "(%d, %d)", x, y)
+				}
+			}
+		})
+	}
+}
+
+func TestImage_applyPlayback(t *testing.T) {
+	tests := []struct {
+		desc           string
+		playback       string
+		lastFrameDelay int
+		// 再生する順番の、順再生のフレームの番号
+		wantOrder          []int
+		wantDelays         []int
+		wantLastFrameDelay int
+	}{
+		{
+			desc:       "正常系: 順再生",
+			playback:   PlaybackForward,
+			wantOrder:  []int{0, 1, 2},
+			wantDelays: []int{10, 20, 30},
+		},
+		{
+			desc:       "正常系: 逆再生",
+			playback:   PlaybackReverse,
+			wantOrder:  []int{2, 1, 0},
+			wantDelays: []int{30, 20, 10},
+		},
+		{
+			desc:       "正常系: 往復再生は両端のフレームを1回だけ表示する",
+			playback:   PlaybackPingpong,
+			wantOrder:  []int{0, 1, 2, 1},
+			wantDelays: []int{10, 20, 30, 20},
+		},
+		{
+			desc:           "正常系: 往復再生の最後のフレームのディレイは折り返すフレームに使う",
+			playback:       PlaybackPingpong,
+			lastFrameDelay: 50,
+			wantOrder:      []int{0, 1, 2, 1},
+			wantDelays:     []int{10, 20, 50, 20},
+		},
+		{
+			desc:               "正常系: 逆再生の最後のフレームのディレイは末尾に使う",
+			playback:           PlaybackReverse,
+			lastFrameDelay:     50,
+			wantOrder:          []int{2, 1, 0},
+			wantDelays:         []int{30, 20, 10},
+			wantLastFrameDelay: 50,
+		},
+	}
+	param := func(playback string, lastFrameDelay int) ImageParam {
+		return ImageParam{
+			UseAnimation:       true,
+			AnimationLineCount: 1,
+			FrameDelays:        []int{10, 20, 30},
+			Playback:           playback,
+			LastFrameDelay:     lastFrameDelay,
+			Delay:              10,
+		}
+	}
+	forward := drawTestImage(t, "A\nB\nC", param(PlaybackForward, 0)).animationImages
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			img := drawTestImage(t, "A\nB\nC", param(tt.playback, tt.lastFrameDelay))
+			assert.Equal(tt.wantDelays, img.animationDelays)
+			assert.Equal(tt.wantLastFrameDelay, img.lastFrameDelay)
+			assert.Len(img.animationImages, len(tt.wantOrder))
+			for j, k := range tt.wantOrder {
+				assert.Equal(toRGBA(forward[k]).Pix, toRGBA(img.animationImages[j]).Pix, "frame %d", j)
+			}
+		})
+	}
+}
+
+func TestImage_encodeSVG(t *testing.T) {
+	cff, err := os.ReadFile("../fontfile/testdata/CFFTest.otf")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		desc       string
+		text       string
+		p          ImageParam
+		want       []string
+		wantNot    []string
+		wantTexts  int
+		wantTSpans int
+	}{
+		{
+			desc:       "正常系: 行ごとに text、色ごとに tspan を出力する",
+			text:       "ab\ncd",
+			p:          ImageParam{FontData: gomono.TTF},
+			want:       []string{`<rect width="100%" height="100%"`, `font-family:"Go Mono",monospace`},
+			wantNot:    []string{"@font-face"},
+			wantTexts:  2,
+			wantTSpans: 2,
+		},
+		{
+			desc:       "正常系: 透過する場合は背景を出力しない",
+			text:       "ab",
+			p:          ImageParam{FontData: gomono.TTF, Transparent: true},
+			wantNot:    []string{`<rect width="100%" height="100%"`},
+			wantTexts:  1,
+			wantTSpans: 1,
+		},
+		{
+			desc:       "正常系: TrueType のフォントを埋め込む",
+			text:       "ab",
+			p:          ImageParam{FontData: gomono.TTF, EmbedFont: true},
+			want:       []string{"@font-face", "data:font/ttf;base64,"},
+			wantTexts:  1,
+			wantTSpans: 1,
+		},
+		{
+			desc:       "正常系: CFF のフォントを埋め込む",
+			text:       "ab",
+			p:          ImageParam{FontData: cff, EmbedFont: true},
+			want:       []string{"@font-face", "data:font/otf;base64,"},
+			wantTexts:  1,
+			wantTSpans: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			img := drawTestImage(t, tt.text, tt.p)
+			var buf bytes.Buffer
+			assert.NoError(img.encodeSVG(&buf))
+			got := buf.String()
+			for _, w := range tt.want {
+				assert.Contains(got, w)
+			}
+			for _, w := range tt.wantNot {
+				assert.NotContains(got, w)
+			}
+			assert.Equal(tt.wantTexts, strings.Count(got, "<text "))
+			assert.Equal(tt.wantTSpans, strings.Count(got, "<tspan "))
+		})
+	}
+}
+
+func TestImage_encodeSVG_Background(t *testing.T) {
+	assert := assert.New(t)
+
+	// エスケープシーケンスで指定した背景色は矩形で出力する
+	p := ImageParam{
+		ForegroundColor: white,
+		BackgroundColor: black,
+		FontFace:        basicfont.Face7x13,
+		FontSize:        13,
+		BaseWidth:       2,
+		BaseHeight:      1,
+		FontData:        gomono.TTF,
+	}
+	img := NewImage(&p)
+	assert.NoError(img.Draw(token.Tokens{
+		token.NewText("a"),
+		{Kind: token.KindColor, ColorType: token.ColorTypeBackground, Color: color.RGBARed},
+		token.NewText("b"),
+	}))
+	var buf bytes.Buffer
+	assert.NoError(img.encodeSVG(&buf))
+	assert.Contains(buf.String(), fmt.Sprintf(`<rect x="%d" y="0" width="%d" height="%d" fill="#ff0000"`, img.charWidth, img.charWidth, img.charHeight))
+}
+
+func TestImage_encodePDF(t *testing.T) {
+	cff, err := os.ReadFile("../fontfile/testdata/CFFTest.otf")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		desc      string
+		fontData  []byte
+		pageRows  int
+		want      []string
+		wantPages int
+		wantErr   bool
+	}{
+		{
+			desc:      "正常系: 全ての行を1ページに出力する",
+			fontData:  gomono.TTF,
+			want:      []string{"%PDF-1.4", "/CIDFontType2", "/FontFile2", "/Count 1"},
+			wantPages: 1,
+		},
+		{
+			desc:      "正常系: ページの行数で分ける",
+			fontData:  gomono.TTF,
+			pageRows:  2,
+			want:      []string{"/Count 2"},
+			wantPages: 2,
+		},
+		{
+			desc:      "正常系: 1行ずつのページ",
+			fontData:  gomono.TTF,
+			pageRows:  1,
+			want:      []string{"/Count 3"},
+			wantPages: 3,
+		},
+		{
+			desc:      "正常系: CFF のフォント",
+			fontData:  cff,
+			want:      []string{"%PDF-1.6", "/CIDFontType0", "/FontFile3", "/Subtype /OpenType"},
+			wantPages: 1,
+		},
+		{
+			desc:     "異常系: フォントがない",
+			fontData: nil,
+			wantErr:  true,
+		},
+		{
+			desc:     "異常系: フォントでないデータ",
+			fontData: []byte("textimg"),
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			img := drawTestImage(t, "a\nb\nc", ImageParam{FontData: tt.fontData, PageRows: tt.pageRows, PageMargin: 36})
+			var buf bytes.Buffer
+			err := img.encodePDF(&buf)
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			got := buf.String()
+			for _, w := range tt.want {
+				assert.Contains(got, w)
+			}
+			assert.Equal(tt.wantPages, strings.Count(got, "/Type /Page "))
+			assert.True(strings.HasSuffix(got, "%%EOF\n"))
+
+			// 相互参照表の各オフセットがオブジェクトの先頭を指す
+			var xref int
+			_, err = fmt.Sscanf(got[strings.LastIndex(got, "startxref"):], "startxref\n%d", &xref)
+			assert.NoError(err)
+			entries := strings.Split(got[xref:], "\n")[3:]
+			for n := 1; !strings.HasPrefix(entries[n-1], "trailer"); n++ {
+				var off int
+				_, err := fmt.Sscanf(entries[n-1], "%d", &off)
+				assert.NoError(err)
+				assert.True(strings.HasPrefix(got[off:], fmt.Sprintf("%d 0 obj\n", n)), "object %d", n)
+			}
+		})
+	}
+}
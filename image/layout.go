@@ -0,0 +1,91 @@
+package image
+
+import (
+	c "image/color"
+
+	"github.com/jiro4989/textimg/v3/token"
+)
+
+// span は同じ行で同じ色の連続した文字のまとまり。
+// 位置と幅はピクセルではなくセル単位で持つので、描画方法に依存しない。
+type span struct {
+	row                 int // 0始まりの行
+	col                 int // 0始まりの列
+	width               int // セル数
+	text                string
+	foregroundColor     c.RGBA
+	backgroundColor     c.RGBA
+	backgroundIsDefault bool
+}
+
+// layout はトークンを文字の配置に変換する。
+// 列数を超える文字は配置しない。
+func (i *Image) layout(tokens token.Tokens) []span {
+	var (
+		spans    []span
+		row, col int
+	)
+	i.resetColor()
+	for _, t := range tokens {
+		switch t.Kind {
+		case token.KindColor:
+			i.updateColor(t.ColorType, t.Color)
+		case token.KindText:
+			for _, r := range t.Text {
+				if isLinefeed(r) {
+					row++
+					col = 0
+					continue
+				}
+
+				w := i.width.RuneWidth(r)
+				if i.isOverflow(col, w) {
+					col += w
+					continue
+				}
+				if n := len(spans); 0 < n && spans[n-1].continues(row, col, i) {
+					spans[n-1].text += string(r)
+					spans[n-1].width += w
+				} else {
+					spans = append(spans, span{
+						row:                 row,
+						col:                 col,
+						width:               w,
+						text:                string(r),
+						foregroundColor:     i.foregroundColor,
+						backgroundColor:     i.backgroundColor,
+						backgroundIsDefault: i.backgroundIsDefault,
+					})
+				}
+				col += w
+			}
+		}
+	}
+	i.resetColor()
+	return spans
+}
+
+// continues は row 行 col 列の文字を、現在の色のまま s に続けられるかを判定する。
+func (s span) continues(row, col int, i *Image) bool {
+	return s.row == row &&
+		s.col+s.width == col &&
+		s.foregroundColor == i.foregroundColor &&
+		s.backgroundColor == i.backgroundColor &&
+		s.backgroundIsDefault == i.backgroundIsDefault
+}
+
+// isOverflow は col 列目に幅 w の文字を置くと列数を超えるかを判定する。
+// 幅0の文字は直前の文字が列数を超えていた場合のみ超えたとみなす。
+func (i *Image) isOverflow(col, w int) bool {
+	return i.columnCount < col+w
+}
+
+// cellX は col 列目の左端のX座標を返す。
+func (i *Image) cellX(col int) int {
+	return i.textOriginX() + col*i.charWidth
+}
+
+// cellY は row 行目の上端のY座標を返す。
+func (i *Image) cellY(row int) int {
+	return i.padding.Top + row*i.charHeight
+}
@@ -0,0 +1,75 @@
+package image
+
+import (
+	"image"
+	"image/draw"
+)
+
+// marqueeFrameWidth はマーキーアニメーションの1フレームの幅を返す。
+// 行番号の領域はスクロールせずに左端に固定する。
+func (p *ImageParam) marqueeFrameWidth() int {
+	cw, _, _ := charSize(p.FontFace, p.LineSpacing, p.LetterSpacing)
+	cols := p.MarqueeWidth + p.gutterColumns()
+	return cols*cw + p.Padding.Left + p.Padding.Right
+}
+
+// marqueeStepWidth は1フレームでスクロールする幅 (px) を返す。
+func (i *Image) marqueeStepWidth() int {
+	if 0 < i.marqueeStepPixels {
+		return i.marqueeStepPixels
+	}
+	return max(i.marqueeStep*i.charWidth, 1)
+}
+
+// setMarqueeFrames は描画済みの画像を、marqueeWidth 列の表示領域の中で横に
+// スクロールするアニメーションのフレームを作る。
+//
+// 通常は表示領域の右端から文字が現れて左端に消えるまでスクロールする。
+// marqueeForever の場合は文字の末尾の後ろに先頭をつなげて、途切れずに繰り返す。
+func (i *Image) setMarqueeFrames() {
+	var (
+		b     = i.image.Bounds()
+		textX = i.textOriginX()
+		textW = i.columnCount * i.charWidth
+		viewW = i.marqueeWidth * i.charWidth
+		frame = image.Rect(0, 0, textX+viewW+i.padding.Right, b.Dy())
+		step  = i.marqueeStepWidth()
+		// 背景と行番号はスクロールしない
+		base = newImage(frame.Dx(), frame.Dy())
+	)
+	i.drawBackgroundAll(base)
+	i.drawHighlightLines(base)
+	i.drawLineNumbers(base)
+	if textW < 1 {
+		i.animationImages = append(i.animationImages, base)
+		return
+	}
+
+	from, to := -viewW, textW
+	if i.marqueeForever {
+		from = 0
+	}
+	for offset := from; offset < to; offset += step {
+		dst := newImage(frame.Dx(), frame.Dy())
+		copy(dst.Pix, base.Pix)
+		// 表示領域の左端から、文字の offset の位置を順に写す
+		for x := 0; x < viewW; {
+			sx := offset + x
+			if i.marqueeForever {
+				sx = (sx%textW + textW) % textW
+			}
+			if sx < 0 {
+				x -= sx
+				continue
+			}
+			if textW <= sx {
+				break
+			}
+			w := min(textW-sx, viewW-x)
+			r := image.Rect(textX+x, 0, textX+x+w, frame.Dy())
+			draw.Draw(dst, r, i.image, image.Pt(textX+sx, b.Min.Y), draw.Src)
+			x += w
+		}
+		i.animationImages = append(i.animationImages, dst)
+	}
+}
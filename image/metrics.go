@@ -0,0 +1,67 @@
+package image
+
+import (
+	"fmt"
+
+	"golang.org/x/image/font"
+)
+
+type Padding struct {
+	Top    int
+	Right  int
+	Bottom int
+	Left   int
+}
+
+// charSize はフォントのメトリクスから1セルあたりの幅と高さ、ベースラインまで
+// の高さを返す。
+//
+// 幅は 'M' の送り幅、高さは Ascent + Descent を基準にする。
+// 全角文字は幅2セルとして扱う。
+func charSize(f font.Face, lineSpacing, letterSpacing int) (width, height, baseline int) {
+	adv, ok := f.GlyphAdvance('M')
+	if !ok {
+		// 'M' すら持たないフォントはほぼ無いけれど、念の為フォントサイズから
+		// 算出する
+		adv = f.Metrics().Height / 2
+	}
+	m := f.Metrics()
+	width = adv.Ceil() + letterSpacing
+	height = m.Ascent.Ceil() + m.Descent.Ceil() + lineSpacing
+	baseline = lineSpacing/2 + m.Ascent.Ceil()
+	return
+}
+
+// ValidateCellSize は行間と文字間を足した1セルの幅と高さが 1px 以上になるかを
+// 検証する。
+func ValidateCellSize(f font.Face, lineSpacing, letterSpacing int) error {
+	width, height, _ := charSize(f, lineSpacing, letterSpacing)
+	if width < 1 {
+		return fmt.Errorf("letter spacing is too small. cell width must be positive. letter spacing = %d", letterSpacing)
+	}
+	if height < 1 {
+		return fmt.Errorf("line spacing is too small. cell height must be positive. line spacing = %d", lineSpacing)
+	}
+	return nil
+}
+
+// ImageSize は ImageParam から描画する画像の幅と高さを返す。
+// ウィンドウ枠を含み、リサイズ前のサイズである。
+func (p *ImageParam) ImageSize() (int, int) {
+	w, h := p.canvasSize()
+	// マーキーアニメーションは表示領域の幅のフレームになる
+	if 0 < p.MarqueeWidth {
+		w = p.marqueeFrameWidth()
+	}
+	in := p.frameInsets()
+	return w + in.Left + in.Right, h + in.Top + in.Bottom
+}
+
+// canvasSize は文字を描画するキャンバスの幅と高さを返す。
+func (p *ImageParam) canvasSize() (int, int) {
+	cw, ch, _ := charSize(p.FontFace, p.LineSpacing, p.LetterSpacing)
+	cols := p.BaseWidth + p.gutterColumns() + p.cursorColumns()
+	w := cols*cw + p.Padding.Left + p.Padding.Right
+	h := p.BaseHeight*ch + p.Padding.Top + p.Padding.Bottom
+	return w, h
+}
@@ -0,0 +1,405 @@
+package image
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	c "image/color"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/jiro4989/textimg/v3/fontfile"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// PDF に埋め込むフォントのリソース名
+const pdfFontName = "/F1"
+
+// pdfDocument は PDF のオブジェクトを組み立てる。
+// オブジェクトは番号を予約してから書き込むので、相互に参照できる。
+type pdfDocument struct {
+	objects [][]byte
+	version string // PDF のバージョン。空の場合は 1.4
+}
+
+// reserve はオブジェクトの番号を予約する。
+func (d *pdfDocument) reserve() int {
+	d.objects = append(d.objects, nil)
+	return len(d.objects)
+}
+
+// set は予約した番号のオブジェクトを設定する。
+func (d *pdfDocument) set(n int, body string) {
+	d.objects[n-1] = []byte(body)
+}
+
+// add はオブジェクトを追加して番号を返す。
+func (d *pdfDocument) add(body string) int {
+	n := d.reserve()
+	d.set(n, body)
+	return n
+}
+
+// addStream は圧縮したストリームのオブジェクトを追加して番号を返す。
+// dict には Length と Filter 以外のエントリを指定する。
+func (d *pdfDocument) addStream(dict string, data []byte) int {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "<< %s /Length %d /Filter /FlateDecode >>\nstream\n", dict, buf.Len())
+	body.Write(buf.Bytes())
+	body.WriteString("\nendstream")
+
+	n := d.reserve()
+	d.objects[n-1] = body.Bytes()
+	return n
+}
+
+// writeTo は root をカタログとした PDF を出力する。
+func (d *pdfDocument) writeTo(w io.Writer, root int) error {
+	var (
+		buf     bytes.Buffer
+		offsets []int
+	)
+	// バイナリを含むことを示すためにコメントに ASCII 以外の文字を入れる
+	version := d.version
+	if version == "" {
+		version = "1.4"
+	}
+	fmt.Fprintf(&buf, "%%PDF-%s\n%%\xe2\xe3\xcf\xd3\n", version)
+	for j, obj := range d.objects {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n", j+1)
+		buf.Write(obj)
+		buf.WriteString("\nendobj\n")
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(d.objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(d.objects)+1, root, xref)
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// pdfPage は1ページ分の描画命令を組み立てる。
+// 座標は画像と同じく左上を原点としたピクセルで指定し、PDF の座標に変換する。
+// 1px を 1pt として扱う。
+type pdfPage struct {
+	bytes.Buffer
+	height int // ページの高さ
+	margin int // ページの余白
+	// 使用した透明度。リソースに ExtGState として定義する
+	alphas map[uint8]bool
+}
+
+// fill は塗りつぶしの色を設定する。
+// 半透明の場合は透明度も設定するので、q と Q で囲って使うこと。
+func (p *pdfPage) fill(col c.RGBA) {
+	if col.A < 255 {
+		p.alphas[col.A] = true
+		fmt.Fprintf(p, "/GS%d gs ", col.A)
+	}
+	fmt.Fprintf(p, "%s %s %s rg\n", pdfNum(float64(col.R)/255), pdfNum(float64(col.G)/255), pdfNum(float64(col.B)/255))
+}
+
+// rect は左上が (x, y) の矩形を塗りつぶす。
+func (p *pdfPage) rect(x, y, w, h int, col c.RGBA) {
+	p.WriteString("q ")
+	p.fill(col)
+	fmt.Fprintf(p, "%d %d %d %d re f Q\n", p.margin+x, p.height-p.margin-y-h, w, h)
+}
+
+// text はベースラインの左端が (x, y) の位置にグリフを描画する。
+func (p *pdfPage) text(x, y, size int, col c.RGBA, glyphs []sfnt.GlyphIndex) {
+	p.WriteString("q ")
+	p.fill(col)
+	fmt.Fprintf(p, "BT %s %d Tf 1 0 0 1 %d %d Tm <", pdfFontName, size, p.margin+x, p.height-p.margin-y)
+	for _, g := range glyphs {
+		fmt.Fprintf(p, "%04x", uint16(g))
+	}
+	p.WriteString("> Tj ET Q\n")
+}
+
+// encodePDF は文字の配置をベクタ形式の PDF として出力する。
+// フォントは使われている文字のグリフだけを埋め込む。
+// 1ページに pageRows 行ずつ描画し、0 の場合は全ての行を1ページに描画する。
+func (i *Image) encodePDF(w io.Writer) error {
+	if i.fontData == nil {
+		return errors.New("font data is required for pdf")
+	}
+	cff := fontfile.IsCFF(i.fontData)
+	if !cff && !fontfile.IsTrueType(i.fontData) {
+		return errors.New("pdf output supports only TrueType or OpenType fonts")
+	}
+	f, err := sfnt.Parse(i.fontData)
+	if err != nil {
+		return err
+	}
+
+	// 文字をグリフに変換する。行番号の数字も常に含める
+	var (
+		b      sfnt.Buffer
+		glyphs = make(map[rune]sfnt.GlyphIndex)
+		text   strings.Builder
+	)
+	for _, sp := range i.spans {
+		text.WriteString(sp.text)
+	}
+	text.WriteString("0123456789")
+	for _, r := range text.String() {
+		if _, ok := glyphs[r]; ok {
+			continue
+		}
+		g, err := f.GlyphIndex(&b, r)
+		if err != nil {
+			return err
+		}
+		glyphs[r] = g
+	}
+	// CID で字形を指定する CFF のフォントは、グリフの番号を CID に変換して使う
+	if cff {
+		cids, err := fontfile.CIDs(i.fontData)
+		if err != nil {
+			return err
+		}
+		for r, g := range glyphs {
+			if int(g) < len(cids) {
+				glyphs[r] = sfnt.GlyphIndex(cids[g])
+			}
+		}
+	}
+	toGlyphs := func(s string) (ret []sfnt.GlyphIndex) {
+		for _, r := range s {
+			ret = append(ret, glyphs[r])
+		}
+		return
+	}
+
+	var doc pdfDocument
+	fontObj, err := i.addPDFFont(&doc, f, cff, glyphs, text.String())
+	if err != nil {
+		return err
+	}
+	var (
+		pages = doc.reserve()
+		rows  = i.pageRows
+	)
+	if rows < 1 || i.rowCount < rows {
+		rows = i.rowCount
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	var (
+		numPages   = (i.rowCount + rows - 1) / rows
+		width      = i.cellX(i.columnCount) + i.padding.Right
+		height     = i.cellY(rows) + i.padding.Bottom
+		pageWidth  = width + 2*i.pageMargin
+		pageHeight = height + 2*i.pageMargin
+		contents   []*pdfPage
+	)
+	if numPages < 1 {
+		numPages = 1
+	}
+
+	for n := 0; n < numPages; n++ {
+		var (
+			p = &pdfPage{height: pageHeight, margin: i.pageMargin, alphas: make(map[uint8]bool)}
+			// このページに描画する行の範囲
+			from, to = n * rows, (n + 1) * rows
+		)
+		if !i.transparent {
+			p.rect(0, 0, width, height, i.defaultBackgroundColor)
+		}
+		for _, sp := range i.spans {
+			if sp.row < from || to <= sp.row || sp.backgroundIsDefault {
+				continue
+			}
+			p.rect(i.cellX(sp.col), i.cellY(sp.row-from), sp.width*i.charWidth, i.charHeight, sp.backgroundColor)
+		}
+		for row := from; row < to && row < i.contentRows(); row++ {
+			if i.isHighlighted(i.lineNumber(row)) {
+				p.rect(i.padding.Left, i.cellY(row-from), width-i.padding.Left-i.padding.Right, i.charHeight, i.highlightColor)
+			}
+		}
+		if 0 < i.gutterColumns {
+			fg := i.defaultForegroundColor
+			fg.A /= 2
+			for row := from; row < to && row < i.contentRows(); row++ {
+				if i.isWrappedRow(row) {
+					continue
+				}
+				// 間隔の1セル分を除いた領域に右寄せする
+				s := fmt.Sprint(i.lineNumber(row))
+				x := i.padding.Left + (i.gutterColumns-1-len(s))*i.charWidth
+				p.text(x, i.cellY(row-from)+i.baseline, i.fontSize, fg, toGlyphs(s))
+			}
+		}
+		for _, sp := range i.spans {
+			if sp.row < from || to <= sp.row {
+				continue
+			}
+			p.text(i.cellX(sp.col), i.cellY(sp.row-from)+i.baseline, i.fontSize, sp.foregroundColor, toGlyphs(sp.text))
+		}
+		contents = append(contents, p)
+	}
+
+	// 透明度はページをまたいで共通のリソースにする
+	alphas := make(map[uint8]bool)
+	for _, p := range contents {
+		for a := range p.alphas {
+			alphas[a] = true
+		}
+	}
+	var gs []string
+	for a := range alphas {
+		gs = append(gs, fmt.Sprintf("/GS%d << /Type /ExtGState /ca %s >>", a, pdfNum(float64(a)/255)))
+	}
+	sort.Strings(gs)
+	resources := doc.add(fmt.Sprintf("<< /Font << %s %d 0 R >> /ExtGState << %s >> >>", pdfFontName, fontObj, strings.Join(gs, " ")))
+
+	var kids []string
+	for _, p := range contents {
+		content := doc.addStream("", p.Bytes())
+		page := doc.add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %d %d] /Resources %d 0 R /Contents %d 0 R >>",
+			pages, pageWidth, pageHeight, resources, content))
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+	}
+	doc.set(pages, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+	catalog := doc.add(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pages))
+
+	return doc.writeTo(w, catalog)
+}
+
+// addPDFFont はフォントを Type0 フォントとして埋め込み、フォントの番号を返す。
+// 文字コードはグリフの番号 (CFF のフォントは CID) をそのまま使う (Identity-H)。
+// 文字幅はセルの幅にするので、フォントの文字幅に関わらずセルの位置に揃う。
+// CFF のフォントは OpenType のまま FontFile3 として埋め込むので PDF 1.6 になる。
+func (i *Image) addPDFFont(doc *pdfDocument, f *sfnt.Font, cff bool, glyphs map[rune]sfnt.GlyphIndex, text string) (int, error) {
+	var (
+		b    sfnt.Buffer
+		upem = f.UnitsPerEm()
+		ppem = fixed.I(int(upem))
+		// フォントの単位を PDF のグリフ空間 (1000分の1) に変換する
+		toGlyphSpace = func(v fixed.Int26_6) int {
+			return int(math.Round(float64(v) / 64 * 1000 / float64(upem)))
+		}
+		cellWidth = func(w int) int {
+			return int(math.Round(float64(w*i.charWidth) * 1000 / float64(i.fontSize)))
+		}
+	)
+
+	name, err := f.Name(&b, sfnt.NameIDPostScript)
+	if err != nil || name == "" {
+		name = "textimg"
+	}
+	// サブセットのフォント名には6文字の大文字の接頭辞を付ける
+	name = "TXTIMG+" + strings.ReplaceAll(name, " ", "")
+
+	bounds, err := f.Bounds(&b, ppem, font.HintingNone)
+	if err != nil {
+		return 0, err
+	}
+	metrics, err := f.Metrics(&b, ppem, font.HintingNone)
+	if err != nil {
+		return 0, err
+	}
+	data, err := fontfile.Subset(i.fontData, text)
+	if err != nil {
+		return 0, err
+	}
+	var (
+		fontFileKey = "/FontFile2"
+		subtype     = "/CIDFontType2"
+		cidToGID    = " /CIDToGIDMap /Identity"
+		fontFile    int
+	)
+	if cff {
+		fontFileKey, subtype, cidToGID = "/FontFile3", "/CIDFontType0", ""
+		fontFile = doc.addStream("/Subtype /OpenType", data)
+		doc.version = "1.6"
+	} else {
+		fontFile = doc.addStream(fmt.Sprintf("/Length1 %d", len(data)), data)
+	}
+	descriptor := doc.add(fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 4 /FontBBox [%d %d %d %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 %s %d 0 R >>",
+		name,
+		toGlyphSpace(bounds.Min.X), toGlyphSpace(-bounds.Max.Y), toGlyphSpace(bounds.Max.X), toGlyphSpace(-bounds.Min.Y),
+		toGlyphSpace(metrics.Ascent), -toGlyphSpace(metrics.Descent), toGlyphSpace(metrics.CapHeight),
+		fontFileKey, fontFile))
+
+	// グリフの番号順に文字幅と Unicode への対応を並べる
+	var runes []rune
+	for r := range glyphs {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(a, b int) bool {
+		return glyphs[runes[a]] < glyphs[runes[b]]
+	})
+	var (
+		widths []string
+		cmap   []string
+		seen   = make(map[sfnt.GlyphIndex]bool)
+	)
+	for _, r := range runes {
+		g := glyphs[r]
+		if seen[g] {
+			continue
+		}
+		seen[g] = true
+		widths = append(widths, fmt.Sprintf("%d [%d]", g, cellWidth(i.width.RuneWidth(r))))
+		if g == 0 {
+			continue
+		}
+		var u strings.Builder
+		for _, v := range utf16.Encode([]rune{r}) {
+			fmt.Fprintf(&u, "%04x", v)
+		}
+		cmap = append(cmap, fmt.Sprintf("<%04x> <%s>", uint16(g), u.String()))
+	}
+
+	cid := doc.add(fmt.Sprintf("<< /Type /Font /Subtype %s /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /DW %d /W [%s]%s >>",
+		subtype, name, descriptor, cellWidth(1), strings.Join(widths, " "), cidToGID))
+	toUnicode := doc.addStream("", []byte(toUnicodeCMap(cmap)))
+	return doc.add(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		name, cid, toUnicode)), nil
+}
+
+// toUnicodeCMap はグリフの番号から Unicode に変換する CMap を返す。
+// テキストとして検索やコピーをするために使う。
+func toUnicodeCMap(entries []string) string {
+	var sb strings.Builder
+	sb.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	sb.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	sb.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+	sb.WriteString("1 begincodespacerange\n<0000> <ffff>\nendcodespacerange\n")
+	// 1つのブロックには100個までしか書けない
+	for j := 0; j < len(entries); j += 100 {
+		end := j + 100
+		if len(entries) < end {
+			end = len(entries)
+		}
+		fmt.Fprintf(&sb, "%d beginbfchar\n%s\nendbfchar\n", end-j, strings.Join(entries[j:end], "\n"))
+	}
+	sb.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return sb.String()
+}
+
+// pdfNum は小数を PDF の数値として出力する。
+func pdfNum(v float64) string {
+	s := fmt.Sprintf("%.3f", v)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
@@ -0,0 +1,66 @@
+package image
+
+import (
+	"fmt"
+	"slices"
+)
+
+const (
+	PlaybackForward  = "forward"
+	PlaybackReverse  = "reverse"
+	PlaybackPingpong = "pingpong"
+)
+
+var (
+	Playbacks = []string{
+		PlaybackForward,
+		PlaybackReverse,
+		PlaybackPingpong,
+	}
+)
+
+// ValidatePlayback はアニメーションの再生方法が正しいかを検証する。
+func ValidatePlayback(s string) error {
+	for _, v := range Playbacks {
+		if s == v {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not supported playback.", s)
+}
+
+// applyPlayback はアニメーションのフレームを再生方法の順番に並べ替える。
+//
+// reverse は最後のフレームから逆順に再生する。
+// pingpong は最後まで再生した後に逆順で最初のフレームの手前まで戻る。折り返す
+// 両端のフレームは続けて2回表示しないように1回だけにする。
+// 最後のフレームのディレイ時間は、末尾ではなく折り返すフレームに適用する。
+func (i *Image) applyPlayback() {
+	n := len(i.animationImages)
+	if n < 2 {
+		return
+	}
+
+	delays := make([]int, n)
+	for j := range delays {
+		delays[j] = i.frameDelay(j)
+	}
+
+	switch i.playback {
+	case PlaybackReverse:
+		slices.Reverse(i.animationImages)
+		slices.Reverse(delays)
+	case PlaybackPingpong:
+		if 0 < i.lastFrameDelay {
+			delays[n-1] = i.lastFrameDelay
+			i.lastFrameDelay = 0
+		}
+		for j := n - 2; 0 < j; j-- {
+			i.animationImages = append(i.animationImages, i.animationImages[j])
+			delays = append(delays, delays[j])
+		}
+	default:
+		return
+	}
+	i.animationDelays = delays
+}
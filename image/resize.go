@@ -0,0 +1,216 @@
+package image
+
+import (
+	"fmt"
+	"image"
+	c "image/color"
+	"image/draw"
+	"math"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+const (
+	FitFill    = "fill"
+	FitContain = "contain"
+	FitCover   = "cover"
+	FitPad     = "pad"
+
+	AnchorCenter      = "center"
+	AnchorTop         = "top"
+	AnchorBottom      = "bottom"
+	AnchorLeft        = "left"
+	AnchorRight       = "right"
+	AnchorTopLeft     = "top-left"
+	AnchorTopRight    = "top-right"
+	AnchorBottomLeft  = "bottom-left"
+	AnchorBottomRight = "bottom-right"
+
+	ResampleNearest    = "nearest"
+	ResampleBilinear   = "bilinear"
+	ResampleCatmullRom = "catmullrom"
+	ResampleLanczos    = "lanczos"
+)
+
+var (
+	FitModes = []string{
+		FitFill,
+		FitContain,
+		FitCover,
+		FitPad,
+	}
+
+	Anchors = []string{
+		AnchorCenter,
+		AnchorTop,
+		AnchorBottom,
+		AnchorLeft,
+		AnchorRight,
+		AnchorTopLeft,
+		AnchorTopRight,
+		AnchorBottomLeft,
+		AnchorBottomRight,
+	}
+
+	Resamples = []string{
+		ResampleNearest,
+		ResampleBilinear,
+		ResampleCatmullRom,
+		ResampleLanczos,
+	}
+
+	resamplerMap = map[string]xdraw.Scaler{
+		ResampleNearest:    xdraw.NearestNeighbor,
+		ResampleBilinear:   xdraw.BiLinear,
+		ResampleCatmullRom: xdraw.CatmullRom,
+		ResampleLanczos:    lanczos,
+	}
+
+	// Lanczos3 の補間。x/image/draw には用意されていないので自前で定義する
+	lanczos = &xdraw.Kernel{
+		Support: 3,
+		At: func(t float64) float64 {
+			if t == 0 {
+				return 1
+			}
+			if 3 <= t {
+				return 0
+			}
+			pt := math.Pi * t
+			return 3 * math.Sin(pt) * math.Sin(pt/3) / (pt * pt)
+		},
+	}
+
+	// 基準位置ごとの、余った領域のうち左と上に割り当てる割合
+	anchorMap = map[string][2]float64{
+		AnchorCenter:      {0.5, 0.5},
+		AnchorTop:         {0.5, 0},
+		AnchorBottom:      {0.5, 1},
+		AnchorLeft:        {0, 0.5},
+		AnchorRight:       {1, 0.5},
+		AnchorTopLeft:     {0, 0},
+		AnchorTopRight:    {1, 0},
+		AnchorBottomLeft:  {0, 1},
+		AnchorBottomRight: {1, 1},
+	}
+)
+
+// ValidateFitMode はリサイズ時の縦横比の扱いが正しいかを検証する。
+func ValidateFitMode(s string) error {
+	for _, v := range FitModes {
+		if s == v {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not supported fit mode.", s)
+}
+
+// ValidateAnchor はリサイズ時の基準位置が正しいかを検証する。
+func ValidateAnchor(s string) error {
+	if _, ok := anchorMap[s]; ok {
+		return nil
+	}
+	return fmt.Errorf("%s is not supported anchor.", s)
+}
+
+// ValidateResample はリサイズ時の補間方法が正しいかを検証する。
+func ValidateResample(s string) error {
+	if _, ok := resamplerMap[s]; ok {
+		return nil
+	}
+	return fmt.Errorf("%s is not supported resample filter.", s)
+}
+
+// scale は画像とアニメーションの各フレームを同じ方法でリサイズする。
+func (i *Image) scale() {
+	if i.resizeWidth == 0 && i.resizeHeight == 0 {
+		return
+	}
+
+	// 呼び出し側で大きさを調整していること
+	i.image = i.resize(i.image)
+	for j, img := range i.animationImages {
+		i.animationImages[j] = i.resize(img)
+	}
+}
+
+// resize は img を resizeWidth x resizeHeight の画像に変換する。
+//
+// fill は縦横比を無視して引き伸ばす。contain は全体が収まるように縮小して余白
+// を埋める。cover は全体を覆うように拡大してはみ出した部分を切り抜く。pad は
+// 拡大はせずに、収まらない場合のみ縮小して余白を埋める。
+// 余白と切り抜く位置は anchor で決まる。
+func (i *Image) resize(img image.Image) *image.RGBA {
+	var (
+		w, h = i.resizeWidth, i.resizeHeight
+		sb   = img.Bounds()
+		dst  = newImage(w, h)
+	)
+	if sb.Empty() {
+		return dst
+	}
+
+	var (
+		rw = float64(w) / float64(sb.Dx())
+		rh = float64(h) / float64(sb.Dy())
+		sw = w
+		sh = h
+	)
+	switch i.fit {
+	case FitContain:
+		sw, sh = scaledSize(sb, math.Min(rw, rh))
+	case FitCover:
+		sw, sh = scaledSize(sb, math.Max(rw, rh))
+	case FitPad:
+		sw, sh = scaledSize(sb, math.Min(1, math.Min(rw, rh)))
+	}
+
+	a, ok := anchorMap[i.anchor]
+	if !ok {
+		a = anchorMap[AnchorCenter]
+	}
+	x := int(math.Round(float64(w-sw) * a[0]))
+	y := int(math.Round(float64(h-sh) * a[1]))
+	r := image.Rect(x, y, x+sw, y+sh)
+
+	if !r.Eq(dst.Bounds()) {
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(i.resizePaddingColor()), image.Point{}, draw.Src)
+	}
+	// はみ出した部分は dst の範囲外なので描画されない
+	i.resampler().Scale(dst, r, img, sb, draw.Src, nil)
+	return dst
+}
+
+// resampler はリサイズに使う補間方法を返す。未指定の場合は CatmullRom を使う。
+func (i *Image) resampler() xdraw.Scaler {
+	if s, ok := resamplerMap[i.resample]; ok {
+		return s
+	}
+	return xdraw.CatmullRom
+}
+
+// scaledSize は sb を ratio 倍した大きさを返す。
+// 0 px にならないように最低でも 1 px にする。
+func scaledSize(sb image.Rectangle, ratio float64) (int, int) {
+	w := int(math.Round(float64(sb.Dx()) * ratio))
+	h := int(math.Round(float64(sb.Dy()) * ratio))
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return w, h
+}
+
+// resizePaddingColor はリサイズ時の余白の色を返す。
+// 画像の最も外側の色と揃える。
+func (i *Image) resizePaddingColor() c.RGBA {
+	if i.frameStyle != "" && i.frameStyle != FrameStyleNone {
+		return i.frameMarginColor
+	}
+	if i.transparent {
+		return c.RGBA{}
+	}
+	return i.defaultBackgroundColor
+}
@@ -0,0 +1,227 @@
+package image
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	c "image/color"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jiro4989/textimg/v3/fontfile"
+	"golang.org/x/image/font/sfnt"
+)
+
+// 埋め込んだフォントの CSS 上の名前
+const svgEmbeddedFontFamily = "textimg"
+
+// svgAspectRatios は縦横比の扱いごとの preserveAspectRatio の値。
+var svgAspectRatios = map[string]string{
+	FitFill:    "none",
+	FitContain: "meet",
+	FitPad:     "meet",
+	FitCover:   "slice",
+}
+
+// svgAnchors は基準位置ごとの preserveAspectRatio の位置指定。
+var svgAnchors = map[string]string{
+	AnchorCenter:      "xMidYMid",
+	AnchorTop:         "xMidYMin",
+	AnchorBottom:      "xMidYMax",
+	AnchorLeft:        "xMinYMid",
+	AnchorRight:       "xMaxYMid",
+	AnchorTopLeft:     "xMinYMin",
+	AnchorTopRight:    "xMaxYMin",
+	AnchorBottomLeft:  "xMinYMax",
+	AnchorBottomRight: "xMaxYMax",
+}
+
+// encodeSVG は文字の配置をベクタ形式の SVG として出力する。
+// 文字は <text> 要素として出力するので、拡大しても劣化せず、テキストとして検索
+// できる。
+func (i *Image) encodeSVG(w io.Writer) error {
+	var (
+		bw     = bufio.NewWriter(w)
+		width  = i.cellX(i.columnCount) + i.padding.Right
+		height = i.cellY(i.rowCount) + i.padding.Bottom
+	)
+
+	fmt.Fprintln(bw, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" %s>`+"\n", width, height, i.svgSizeAttrs(width, height))
+
+	style, err := i.svgStyle()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(bw, "<style>%s</style>\n", style)
+
+	// デフォルトの背景色
+	if !i.transparent {
+		fmt.Fprintf(bw, `<rect width="100%%" height="100%%" %s/>`+"\n", svgFill(i.defaultBackgroundColor))
+	}
+
+	// エスケープシーケンスで指定された背景色
+	for _, sp := range i.spans {
+		if sp.backgroundIsDefault {
+			continue
+		}
+		fmt.Fprintf(bw, `<rect x="%d" y="%d" width="%d" height="%d" %s/>`+"\n",
+			i.cellX(sp.col), i.cellY(sp.row), sp.width*i.charWidth, i.charHeight, svgFill(sp.backgroundColor))
+	}
+
+	i.writeSVGHighlightLines(bw, width)
+	i.writeSVGLineNumbers(bw)
+
+	// 文字は行ごとに1つの <text> にまとめ、色ごとに <tspan> で区切る
+	row := -1
+	for _, sp := range i.spans {
+		if sp.row != row {
+			if 0 <= row {
+				fmt.Fprintln(bw, "</text>")
+			}
+			row = sp.row
+			fmt.Fprintf(bw, `<text y="%d" xml:space="preserve">`, i.cellY(row)+i.baseline)
+		}
+		fmt.Fprintf(bw, `<tspan x="%s" %s>`, i.svgCharPositions(sp), svgFill(sp.foregroundColor))
+		xml.EscapeText(bw, []byte(sp.text))
+		fmt.Fprint(bw, "</tspan>")
+	}
+	if 0 <= row {
+		fmt.Fprintln(bw, "</text>")
+	}
+
+	fmt.Fprintln(bw, "</svg>")
+	return bw.Flush()
+}
+
+// svgSizeAttrs は出力する大きさの属性を返す。
+// リサイズする場合は viewBox の内容を拡大縮小させる。
+func (i *Image) svgSizeAttrs(width, height int) string {
+	if i.resizeWidth == 0 && i.resizeHeight == 0 {
+		return fmt.Sprintf(`width="%d" height="%d"`, width, height)
+	}
+
+	ratio := svgAspectRatios[i.fit]
+	if ratio != "none" {
+		anchor, ok := svgAnchors[i.anchor]
+		if !ok {
+			anchor = svgAnchors[AnchorCenter]
+		}
+		ratio = anchor + " " + ratio
+	}
+	return fmt.Sprintf(`width="%d" height="%d" preserveAspectRatio="%s"`, i.resizeWidth, i.resizeHeight, ratio)
+}
+
+// svgStyle は文字のスタイルを返す。
+// フォントを埋め込む場合は使われている文字のグリフだけを埋め込む。
+func (i *Image) svgStyle() (string, error) {
+	var (
+		sb       strings.Builder
+		families []string
+	)
+	if i.embedFont && i.fontData != nil {
+		var text strings.Builder
+		for _, sp := range i.spans {
+			text.WriteString(sp.text)
+		}
+		// 行番号も描画するので数字は常に含める
+		text.WriteString("0123456789")
+
+		data, err := fontfile.Subset(i.fontData, text.String())
+		if err != nil {
+			return "", err
+		}
+		mime := "font/ttf"
+		if fontfile.IsCFF(data) {
+			mime = "font/otf"
+		}
+		fmt.Fprintf(&sb, `@font-face{font-family:"%s";src:url(data:%s;base64,%s)}`,
+			svgEmbeddedFontFamily, mime, base64.StdEncoding.EncodeToString(data))
+		families = append(families, strconv.Quote(svgEmbeddedFontFamily))
+	}
+	if name := fontFamilyName(i.fontData); name != "" {
+		families = append(families, strconv.Quote(name))
+	}
+	families = append(families, "monospace")
+
+	fmt.Fprintf(&sb, "text{font-family:%s;font-size:%dpx}", strings.Join(families, ","), i.fontSize)
+	return sb.String(), nil
+}
+
+// svgCharPositions は文字ごとのX座標を返す。
+// フォントの文字幅に関わらずセルの位置に揃える。
+func (i *Image) svgCharPositions(sp span) string {
+	var (
+		xs  []string
+		col = sp.col
+	)
+	for _, r := range sp.text {
+		xs = append(xs, strconv.Itoa(i.cellX(col)))
+		col += i.width.RuneWidth(r)
+	}
+	return strings.Join(xs, " ")
+}
+
+func (i *Image) writeSVGHighlightLines(w io.Writer, width int) {
+	if len(i.highlightLines) < 1 {
+		return
+	}
+	var (
+		left  = i.padding.Left
+		right = width - i.padding.Right
+	)
+	for row := 0; row < i.contentRows(); row++ {
+		if !i.isHighlighted(i.lineNumber(row)) {
+			continue
+		}
+		fmt.Fprintf(w, `<rect x="%d" y="%d" width="%d" height="%d" %s/>`+"\n",
+			left, i.cellY(row), right-left, i.charHeight, svgFill(i.highlightColor))
+	}
+}
+
+func (i *Image) writeSVGLineNumbers(w io.Writer) {
+	if i.gutterColumns < 1 {
+		return
+	}
+	var (
+		fg = i.defaultForegroundColor
+		// 間隔の1セル分を除いた領域に右寄せする
+		x = i.padding.Left + (i.gutterColumns-1)*i.charWidth
+	)
+	fg.A /= 2
+	for row := 0; row < i.contentRows(); row++ {
+		if i.isWrappedRow(row) {
+			continue
+		}
+		fmt.Fprintf(w, `<text x="%d" y="%d" text-anchor="end" %s>%d</text>`+"\n",
+			x, i.cellY(row)+i.baseline, svgFill(fg), i.lineNumber(row))
+	}
+}
+
+// svgFill は塗りつぶしの色の属性を返す。
+func svgFill(col c.RGBA) string {
+	s := fmt.Sprintf(`fill="#%02x%02x%02x"`, col.R, col.G, col.B)
+	if col.A < 255 {
+		s += fmt.Sprintf(` fill-opacity="%.3g"`, float64(col.A)/255)
+	}
+	return s
+}
+
+// fontFamilyName はフォントファイルのファミリー名を返す。
+// 取得できない場合は空文字を返す。
+func fontFamilyName(data []byte) string {
+	if data == nil {
+		return ""
+	}
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return ""
+	}
+	name, err := f.Name(nil, sfnt.NameIDFamily)
+	if err != nil {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,130 @@
+package image
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+)
+
+const (
+	TransitionNone   = "none"
+	TransitionScroll = "scroll"
+	TransitionFade   = "fade"
+	TransitionWipe   = "wipe"
+)
+
+// 途中のフレームの最小の表示時間 (1/100 秒)。
+// これより短いとブラウザによっては遅く再生される
+const minTransitionDelay = 2
+
+var (
+	Transitions = []string{
+		TransitionNone,
+		TransitionScroll,
+		TransitionFade,
+		TransitionWipe,
+	}
+)
+
+// ValidateTransition はフレームの切り替え方が正しいかを検証する。
+func ValidateTransition(s string) error {
+	for _, v := range Transitions {
+		if s == v {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not supported transition.", s)
+}
+
+// frameDelay は j 番目のフレームの表示時間を返す。
+func (i *Image) frameDelay(j int) int {
+	if j < len(i.animationDelays) {
+		return i.animationDelays[j]
+	}
+	return i.delay
+}
+
+// applyTransition はアニメーションの各フレームの間に、transitionSteps 枚の
+// 切り替え途中のフレームを挿入する。
+// 前のフレームの表示時間を transitionSteps+1 等分して、前のフレームと途中の
+// フレームを1つずつ表示するので、アニメーション全体の長さは変わらない。ただし
+// 等分した時間が最小の表示時間より短い場合は、最小の表示時間にする。
+func (i *Image) applyTransition() {
+	if i.transition == "" || i.transition == TransitionNone || i.transitionSteps < 1 {
+		return
+	}
+
+	var (
+		images []image.Image
+		delays []int
+		n      = len(i.animationImages)
+	)
+	for j, img := range i.animationImages {
+		delay := i.frameDelay(j)
+		images = append(images, img)
+		// 最後のフレームの後には切り替えないので、そのままの時間表示する
+		if j == n-1 {
+			delays = append(delays, delay)
+			break
+		}
+
+		var (
+			src  = toRGBA(img)
+			dst  = toRGBA(i.animationImages[j+1])
+			step = max(delay/(i.transitionSteps+1), minTransitionDelay)
+		)
+		// 途中のフレームを表示する時間の分だけ前のフレームを短く表示する
+		delays = append(delays, max(delay-step*i.transitionSteps, minTransitionDelay))
+		for k := 1; k <= i.transitionSteps; k++ {
+			t := float64(k) / float64(i.transitionSteps+1)
+			images = append(images, i.transitionFrame(src, dst, t))
+			delays = append(delays, step)
+		}
+	}
+	i.animationImages = images
+	i.animationDelays = delays
+}
+
+// transitionFrame は src から dst に t (0~1) の割合だけ切り替えたフレームを返す。
+func (i *Image) transitionFrame(src, dst *image.RGBA, t float64) *image.RGBA {
+	ret := newImage(src.Rect.Dx(), src.Rect.Dy())
+	copy(ret.Pix, src.Pix)
+
+	switch i.transition {
+	case TransitionScroll:
+		// 文字の領域だけをスクロールして、上下の余白は動かさない
+		var (
+			top = i.padding.Top
+			h   = i.animationImageFlameHeight
+			// 次のフレームまでにスクロールする高さ
+			dist = h
+		)
+		if 0 < i.slideWidth {
+			dist = min(i.slideWidth*i.charHeight, h)
+		}
+		s := int(math.Round(t * float64(dist)))
+		// 前のフレームを上にずらして、空いた下側に次のフレームの続きを表示する
+		draw.Draw(ret, image.Rect(0, top, ret.Rect.Dx(), top+h-s), src, image.Pt(0, top+s), draw.Src)
+		draw.Draw(ret, image.Rect(0, top+h-s, ret.Rect.Dx(), top+h), dst, image.Pt(0, top+h-dist), draw.Src)
+	case TransitionFade:
+		for j := range ret.Pix {
+			ret.Pix[j] = uint8(math.Round(float64(src.Pix[j])*(1-t) + float64(dst.Pix[j])*t))
+		}
+	case TransitionWipe:
+		w := int(math.Round(t * float64(ret.Rect.Dx())))
+		draw.Draw(ret, image.Rect(0, 0, w, ret.Rect.Dy()), dst, image.Point{}, draw.Src)
+	}
+	return ret
+}
+
+// toRGBA は img を左上が原点の RGBA 画像に変換する。
+func toRGBA(img image.Image) *image.RGBA {
+	if r, ok := img.(*image.RGBA); ok && r.Rect.Min == (image.Point{}) {
+		return r
+	}
+	b := img.Bounds()
+	ret := newImage(b.Dx(), b.Dy())
+	draw.Draw(ret, ret.Rect, img, b.Min, draw.Src)
+	return ret
+}
@@ -0,0 +1,193 @@
+package image
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"unicode"
+)
+
+const (
+	TypeUnitChar  = "char"
+	TypeUnitWord  = "word"
+	TypeUnitToken = "token"
+
+	CursorBlock     = "block"
+	CursorUnderline = "underline"
+	CursorBar       = "bar"
+	CursorNone      = "none"
+)
+
+// カーソルを点滅させる間隔 (1/100 秒)
+const cursorBlinkInterval = 50
+
+var (
+	TypeUnits = []string{
+		TypeUnitChar,
+		TypeUnitWord,
+		TypeUnitToken,
+	}
+	CursorStyles = []string{
+		CursorBlock,
+		CursorUnderline,
+		CursorBar,
+		CursorNone,
+	}
+)
+
+// typeUnit はタイプライターアニメーションで一度に表示する文字のまとまり。
+// 位置と幅は span と同じくセル単位で持つ。
+type typeUnit struct {
+	row   int
+	col   int
+	width int
+}
+
+// ValidateTypeUnit はタイプライターアニメーションで表示する単位が正しいかを
+// 検証する。
+func ValidateTypeUnit(s string) error {
+	for _, v := range TypeUnits {
+		if s == v {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not supported type unit.", s)
+}
+
+// ValidateCursorStyle はカーソルのスタイルが正しいかを検証する。
+func ValidateCursorStyle(s string) error {
+	for _, v := range CursorStyles {
+		if s == v {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not supported cursor style.", s)
+}
+
+// cursorColumns は最も長い行の行末にもカーソルを表示できるように、キャンバスの
+// 右側に空ける列数を返す。
+func (p *ImageParam) cursorColumns() int {
+	if !p.Typewriter || p.Cursor == CursorNone {
+		return 0
+	}
+	return 1
+}
+
+// typeUnits は文字の配置を、表示する順番に typeUnit に分割する。
+// word の場合は空白の後ろで区切るので、単語と後ろの空白が1つのまとまりになる。
+// token の場合は同じ色の連続した文字 (span) を1つのまとまりにするので、エス
+// ケープシーケンスで色が変わる位置で区切る。
+// 幅0の文字は直前の文字と同じまとまりにする。
+func (i *Image) typeUnits() []typeUnit {
+	var (
+		units     []typeUnit
+		prevSpace bool
+	)
+	for _, sp := range i.spans {
+		col := sp.col
+		for k, r := range sp.text {
+			w := i.width.RuneWidth(r)
+			n := len(units)
+			continued := 0 < n &&
+				units[n-1].row == sp.row &&
+				units[n-1].col+units[n-1].width == col
+			switch {
+			case continued && w == 0:
+				// 結合文字は前の文字と一緒に表示する
+			case continued && i.typeUnit == TypeUnitToken && 0 < k:
+				units[n-1].width += w
+			case continued && i.typeUnit == TypeUnitWord && !(prevSpace && !unicode.IsSpace(r)):
+				units[n-1].width += w
+			default:
+				units = append(units, typeUnit{row: sp.row, col: col, width: w})
+			}
+			if w != 0 {
+				prevSpace = unicode.IsSpace(r)
+			}
+			col += w
+		}
+	}
+	return units
+}
+
+// setTypewriterFrames は文字が typeStep 個ずつ現れるアニメーションのフレームを
+// 作る。
+//
+// 1枚のキャンバスに、描画済みの画像から表示する文字のセルを順に写していく。
+// 各フレームには次に文字が現れる位置にカーソルを重ねる。
+// 行末と最後の文字の後では newlinePause の間カーソルを点滅させる。
+func (i *Image) setTypewriterFrames() {
+	canvas := newImage(i.image.Bounds().Dx(), i.image.Bounds().Dy())
+	i.drawBackgroundAll(canvas)
+	i.drawHighlightLines(canvas)
+	i.drawLineNumbers(canvas)
+
+	units := i.typeUnits()
+	if len(units) < 1 {
+		i.appendTypewriterFrame(canvas, 0, 0, true, i.delay)
+		return
+	}
+
+	// 最初は何も表示していない状態から始める
+	i.appendTypewriterFrame(canvas, units[0].row, units[0].col, true, i.delay)
+	var n int
+	for j, u := range units {
+		var (
+			x    = i.cellX(u.col)
+			y    = i.cellY(u.row)
+			rect = image.Rect(x, y, x+u.width*i.charWidth, y+i.charHeight)
+		)
+		draw.Draw(canvas, rect, i.image, rect.Min, draw.Src)
+
+		n++
+		lineEnd := j == len(units)-1 || units[j+1].row != u.row
+		if n < i.typeStep && !lineEnd {
+			continue
+		}
+		n = 0
+
+		col := u.col + u.width
+		i.appendTypewriterFrame(canvas, u.row, col, true, i.delay)
+		if !lineEnd {
+			continue
+		}
+		// 消えた状態から交互に点滅させる
+		visible := false
+		for rest := i.newlinePause; 0 < rest; rest -= cursorBlinkInterval {
+			i.appendTypewriterFrame(canvas, u.row, col, visible, min(rest, cursorBlinkInterval))
+			visible = !visible
+		}
+	}
+}
+
+// appendTypewriterFrame は canvas の row 行 col 列にカーソルを重ねたフレームを、
+// delay の表示時間でアニメーションに追加する。
+func (i *Image) appendTypewriterFrame(canvas *image.RGBA, row, col int, cursor bool, delay int) {
+	dst := newImage(canvas.Bounds().Dx(), canvas.Bounds().Dy())
+	copy(dst.Pix, canvas.Pix)
+	if cursor {
+		i.drawCursor(dst, row, col)
+	}
+	i.animationImages = append(i.animationImages, dst)
+	i.animationDelays = append(i.animationDelays, delay)
+}
+
+// drawCursor は row 行 col 列のセルに文字色でカーソルを描画する。
+func (i *Image) drawCursor(dst *image.RGBA, row, col int) {
+	var (
+		x    = i.cellX(col)
+		y    = i.cellY(row)
+		rect = image.Rect(x, y, x+i.charWidth, y+i.charHeight)
+		// 下線と縦線の太さ
+		thickness = max(i.charHeight/8, 1)
+	)
+	switch i.cursor {
+	case CursorUnderline:
+		rect.Min.Y = rect.Max.Y - thickness
+	case CursorBar:
+		rect.Max.X = rect.Min.X + thickness
+	case CursorNone:
+		return
+	}
+	draw.Draw(dst, rect, image.NewUniform(i.defaultForegroundColor), image.Point{}, draw.Src)
+}
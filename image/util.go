@@ -2,7 +2,7 @@ package image
 
 import (
 	"fmt"
-	"os"
+	"io/fs"
 )
 
 var (
@@ -27,10 +27,13 @@ var (
 )
 
 // コードポイントに対応する画像ファイルかどうかを判定する。
-// 画像ファイルだった場合は当該画像ファイルのパスを返却する。
-func isEmoji(r rune, emojiDir string) (bool, string) {
-	path := fmt.Sprintf("%s/emoji_u%.4x.png", emojiDir, r)
-	_, err := os.Stat(path)
+// 画像ファイルだった場合は当該画像ファイルの fsys 内のパスを返却する。
+func isEmoji(r rune, fsys fs.FS) (bool, string) {
+	if fsys == nil {
+		return false, ""
+	}
+	path := fmt.Sprintf("emoji_u%.4x.png", r)
+	_, err := fs.Stat(fsys, path)
 	if err == nil && !isExceptionallyCodePoint(r) {
 		return true, path
 	}
@@ -0,0 +1,32 @@
+// Package width は文字の表示幅 (セル数) を判定する。
+//
+// go-runewidth の DefaultCondition は環境変数によって判定が変わり、書き換える
+// と同じプロセスの他のパッケージにも影響するので使わない。
+package width
+
+import "github.com/mattn/go-runewidth"
+
+var (
+	narrow = newCondition(false)
+	wide   = newCondition(true)
+)
+
+// New は文字の表示幅の判定条件を返す。
+// eastAsian の場合は East Asian Width が曖昧な文字を幅2として扱う。
+// eastAsian の場合は Unicode Neutral で定義されている絵文字(例: 👁)も幅2として
+// 扱う。
+//
+// 返す値は共有しているので書き換えないこと。
+func New(eastAsian bool) *runewidth.Condition {
+	if eastAsian {
+		return wide
+	}
+	return narrow
+}
+
+func newCondition(eastAsian bool) *runewidth.Condition {
+	return &runewidth.Condition{
+		EastAsianWidth:     eastAsian,
+		StrictEmojiNeutral: false,
+	}
+}
@@ -49,6 +49,7 @@ const (
 	ruleAction9
 	ruleAction10
 	ruleAction11
+	ruleAction12
 )
 
 var rul3s = [...]string{
@@ -84,6 +85,7 @@ var rul3s = [...]string{
 	"Action9",
 	"Action10",
 	"Action11",
+	"Action12",
 }
 
 type token32 struct {
@@ -200,7 +202,7 @@ type Parser struct {
 
 	Buffer string
 	buffer []rune
-	rules  [32]func() bool
+	rules  [33]func() bool
 	parse  func(rule ...int) error
 	reset  func()
 	Pretty bool
@@ -326,6 +328,8 @@ func (p *Parser) Execute() {
 			p.pushResetColor()
 		case ruleAction11:
 			p.pushReverseColor()
+		case ruleAction12:
+			p.pushTextAttribute(text)
 
 		}
 	}
@@ -923,7 +927,7 @@ func (p *Parser) Init(options ...func(*Parser) error) error {
 			position, tokenIndex = position59, tokenIndex59
 			return false
 		},
-		/* 10 text_attributes <- <(('0' Action10) / ('7' Action11) / ('1' / '4' / '5' / '8'))+> */
+		/* 10 text_attributes <- <(('0' Action10) / ('7' Action11) / (<[1-9]> Action12))+> */
 		func() bool {
 			position64, tokenIndex64 := position, tokenIndex
 			{
@@ -951,34 +955,16 @@ func (p *Parser) Init(options ...func(*Parser) error) error {
 				l70:
 					position, tokenIndex = position68, tokenIndex68
 					{
-						position71, tokenIndex71 := position, tokenIndex
-						if buffer[position] != rune('1') {
-							goto l72
-						}
-						position++
-						goto l71
-					l72:
-						position, tokenIndex = position71, tokenIndex71
-						if buffer[position] != rune('4') {
-							goto l73
-						}
-						position++
-						goto l71
-					l73:
-						position, tokenIndex = position71, tokenIndex71
-						if buffer[position] != rune('5') {
-							goto l74
-						}
-						position++
-						goto l71
-					l74:
-						position, tokenIndex = position71, tokenIndex71
-						if buffer[position] != rune('8') {
+						position71 := position
+						if c := buffer[position]; c < rune('1') || c > rune('9') {
 							goto l64
 						}
 						position++
+						add(rulePegText, position71)
+					}
+					if !_rules[ruleAction12]() {
+						goto l64
 					}
-				l71:
 				}
 			l68:
 			l66:
@@ -1007,34 +993,16 @@ func (p *Parser) Init(options ...func(*Parser) error) error {
 					l77:
 						position, tokenIndex = position75, tokenIndex75
 						{
-							position78, tokenIndex78 := position, tokenIndex
-							if buffer[position] != rune('1') {
-								goto l79
-							}
-							position++
-							goto l78
-						l79:
-							position, tokenIndex = position78, tokenIndex78
-							if buffer[position] != rune('4') {
-								goto l80
-							}
-							position++
-							goto l78
-						l80:
-							position, tokenIndex = position78, tokenIndex78
-							if buffer[position] != rune('5') {
-								goto l81
-							}
-							position++
-							goto l78
-						l81:
-							position, tokenIndex = position78, tokenIndex78
-							if buffer[position] != rune('8') {
+							position78 := position
+							if c := buffer[position]; c < rune('1') || c > rune('9') {
 								goto l67
 							}
 							position++
+							add(rulePegText, position78)
+						}
+						if !_rules[ruleAction12]() {
+							goto l67
 						}
-					l78:
 					}
 				l75:
 					goto l66
@@ -1301,6 +1269,13 @@ func (p *Parser) Init(options ...func(*Parser) error) error {
 			}
 			return true
 		},
+		/* 32 Action12 <- <{ p.pushTextAttribute(text) }> */
+		func() bool {
+			{
+				add(ruleAction12, position)
+			}
+			return true
+		},
 	}
 	p.rules = _rules
 	return nil
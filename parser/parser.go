@@ -72,3 +72,7 @@ func (p *ParserFunc) setExtendedColorB(text string) {
 	n, _ := strconv.ParseUint(text, 10, 8)
 	p.Tk[len(p.Tk)-1].Color.B = uint8(n)
 }
+
+func (p *ParserFunc) pushTextAttribute(text string) {
+	p.Tk = append(p.Tk, token.NewTextAttribute(text))
+}
@@ -288,6 +288,10 @@ func TestParse(t *testing.T) {
 					Kind:      token.KindColor,
 					ColorType: token.ColorTypeReset,
 				},
+				{
+					Kind:      token.KindColor,
+					ColorType: token.ColorTypeBold,
+				},
 				{
 					Kind:      token.KindColor,
 					ColorType: token.ColorTypeForeground,
@@ -300,6 +304,45 @@ func TestParse(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			desc: "正常系: 文字の装飾",
+			s:    "\x1b[2;3;4;5;6;8;9mA",
+			want: token.Tokens{
+				{
+					Kind:      token.KindColor,
+					ColorType: token.ColorTypeDim,
+				},
+				{
+					Kind:      token.KindColor,
+					ColorType: token.ColorTypeItalic,
+				},
+				{
+					Kind:      token.KindColor,
+					ColorType: token.ColorTypeUnderline,
+				},
+				{
+					Kind:      token.KindColor,
+					ColorType: token.ColorTypeBlink,
+				},
+				{
+					Kind:      token.KindColor,
+					ColorType: token.ColorTypeSpeedyBlink,
+				},
+				{
+					Kind:      token.KindColor,
+					ColorType: token.ColorTypeHide,
+				},
+				{
+					Kind:      token.KindColor,
+					ColorType: token.ColorTypeDelete,
+				},
+				{
+					Kind: token.KindText,
+					Text: "A",
+				},
+			},
+			wantErr: false,
+		},
 		{
 			desc: "異常系: 拡張系 256色で数値がuint8を超えた場合はMap256の最後の値が設定される",
 			s:    "\x1b[38;5;256m",
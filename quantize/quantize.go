@@ -0,0 +1,335 @@
+// Package quantize は画像の色数を減らしてパレット画像に変換する。
+//
+// パレットは実際に使われている色からメディアンカット法で作る。
+// 使われている色がパレットの大きさ以下の場合は、全ての色をそのままパレットに
+// するので元の画像と完全に一致する。
+package quantize
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sort"
+)
+
+// ディザリングの方法
+const (
+	DitherNone           = "none"
+	DitherFloydSteinberg = "floyd-steinberg"
+	DitherOrdered        = "ordered"
+)
+
+// 半分以上透けているピクセルは透過色にする
+const alphaThreshold = 0x80
+
+var (
+	Dithers = []string{
+		DitherNone,
+		DitherFloydSteinberg,
+		DitherOrdered,
+	}
+
+	// 8x8 のベイヤー行列
+	bayer = [8][8]int{
+		{0, 32, 8, 40, 2, 34, 10, 42},
+		{48, 16, 56, 24, 50, 18, 58, 26},
+		{12, 44, 4, 36, 14, 46, 6, 38},
+		{60, 28, 52, 20, 62, 30, 54, 22},
+		{3, 35, 11, 43, 1, 33, 9, 41},
+		{51, 19, 59, 27, 49, 17, 57, 25},
+		{15, 47, 7, 39, 13, 45, 5, 37},
+		{63, 31, 55, 23, 61, 29, 53, 21},
+	}
+)
+
+// ValidateDither はディザリングの方法が正しいかを検証する。
+func ValidateDither(s string) error {
+	for _, d := range Dithers {
+		if d == s {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not supported dither.", s)
+}
+
+// colorCount は色と、その色が使われているピクセルの数。
+type colorCount struct {
+	rgb [3]int
+	n   int
+}
+
+// box はメディアンカット法で分割する色の集まり。
+type box struct {
+	colors []colorCount
+	// 平均色からの距離の二乗和。大きいほど先に分割する
+	sse float64
+}
+
+// Palette は imgs で使われている色から最大 n 色のパレットを作る。
+// 透けているピクセルが存在する場合は、パレットの末尾に透過色を含める。
+func Palette(imgs []image.Image, n int) color.Palette {
+	var (
+		hist        = map[uint32]int{}
+		transparent bool
+	)
+	for _, img := range imgs {
+		b := img.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				col := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+				if col.A < alphaThreshold {
+					transparent = true
+					continue
+				}
+				hist[uint32(col.R)<<16|uint32(col.G)<<8|uint32(col.B)]++
+			}
+		}
+	}
+	if transparent {
+		n--
+	}
+
+	colors := make([]colorCount, 0, len(hist))
+	for k, v := range hist {
+		colors = append(colors, colorCount{rgb: [3]int{int(k >> 16), int(k >> 8 & 0xff), int(k & 0xff)}, n: v})
+	}
+	// map の順番に左右されないように並べておく
+	sort.Slice(colors, func(a, b int) bool { return key(colors[a].rgb) < key(colors[b].rgb) })
+
+	var pal color.Palette
+	if len(colors) <= n {
+		for _, c := range colors {
+			pal = append(pal, toRGBA(c.rgb))
+		}
+	} else {
+		for _, b := range medianCut(colors, n) {
+			pal = append(pal, toRGBA(b.mean()))
+		}
+	}
+	if transparent {
+		pal = append(pal, color.RGBA{})
+	}
+	// 全て透明な画像でも1色は必要
+	if len(pal) < 1 {
+		pal = append(pal, color.RGBA{A: 0xff})
+	}
+	return pal
+}
+
+// medianCut は colors を n 個の箱に分割する。
+func medianCut(colors []colorCount, n int) []box {
+	boxes := []box{newBox(colors)}
+	for len(boxes) < n {
+		target := -1
+		for j, b := range boxes {
+			if 1 < len(b.colors) && (target < 0 || boxes[target].sse < b.sse) {
+				target = j
+			}
+		}
+		if target < 0 {
+			break
+		}
+		a, b := boxes[target].split()
+		boxes[target] = a
+		boxes = append(boxes, b)
+	}
+	return boxes
+}
+
+func newBox(colors []colorCount) box {
+	b := box{colors: colors}
+	m := b.mean()
+	for _, c := range colors {
+		for ch := 0; ch < 3; ch++ {
+			d := float64(c.rgb[ch] - m[ch])
+			b.sse += d * d * float64(c.n)
+		}
+	}
+	return b
+}
+
+// mean はピクセルの数で重み付けした平均色を返す。
+func (b box) mean() [3]int {
+	var (
+		sum   [3]int
+		total int
+	)
+	for _, c := range b.colors {
+		for ch := 0; ch < 3; ch++ {
+			sum[ch] += c.rgb[ch] * c.n
+		}
+		total += c.n
+	}
+	var m [3]int
+	for ch := 0; ch < 3; ch++ {
+		m[ch] = (sum[ch] + total/2) / total
+	}
+	return m
+}
+
+// split は最も値の幅が広い成分で、ピクセルの数が半分になる位置で分割する。
+func (b box) split() (box, box) {
+	var lo, hi [3]int
+	for ch := 0; ch < 3; ch++ {
+		lo[ch], hi[ch] = 255, 0
+	}
+	total := 0
+	for _, c := range b.colors {
+		for ch := 0; ch < 3; ch++ {
+			lo[ch] = min(lo[ch], c.rgb[ch])
+			hi[ch] = max(hi[ch], c.rgb[ch])
+		}
+		total += c.n
+	}
+	ch := 0
+	for k := 1; k < 3; k++ {
+		if hi[ch]-lo[ch] < hi[k]-lo[k] {
+			ch = k
+		}
+	}
+
+	colors := append([]colorCount{}, b.colors...)
+	sort.SliceStable(colors, func(x, y int) bool { return colors[x].rgb[ch] < colors[y].rgb[ch] })
+	mid, sum := 1, colors[0].n
+	for mid < len(colors)-1 && sum+colors[mid].n <= total/2 {
+		sum += colors[mid].n
+		mid++
+	}
+	return newBox(colors[:mid]), newBox(colors[mid:])
+}
+
+// Paletted は img を pal のパレット画像に変換する。
+// pal に含まれる色のピクセルはそのままの色にし、それ以外の色のピクセルだけを
+// dither の方法でディザリングする。
+func Paletted(img image.Image, pal color.Palette, dither string) *image.Paletted {
+	var (
+		b       = img.Bounds()
+		p       = image.NewPaletted(b, pal)
+		ti      = -1
+		exact   = map[uint32]uint8{}
+		nearest = map[uint32]uint8{}
+		colors  = make([][3]int, len(pal))
+		w       = b.Dx()
+		// Floyd-Steinberg 法で次のピクセルと次の行に伝える誤差
+		errCur  = make([][3]int, w+2)
+		errNext = make([][3]int, w+2)
+	)
+	for j, c := range pal {
+		col := color.NRGBAModel.Convert(c).(color.NRGBA)
+		colors[j] = [3]int{int(col.R), int(col.G), int(col.B)}
+		if col.A < alphaThreshold {
+			if ti < 0 {
+				ti = j
+			}
+			continue
+		}
+		k := key(colors[j])
+		if _, ok := exact[k]; !ok {
+			exact[k] = uint8(j)
+		}
+	}
+	index := func(rgb [3]int) uint8 {
+		k := key(rgb)
+		if idx, ok := nearest[k]; ok {
+			return idx
+		}
+		idx := nearestIndex(colors, rgb, ti)
+		nearest[k] = idx
+		return idx
+	}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			var (
+				col = color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+				rgb = [3]int{int(col.R), int(col.G), int(col.B)}
+				ex  = x - b.Min.X + 1
+			)
+			if col.A < alphaThreshold && 0 <= ti {
+				p.SetColorIndex(x, y, uint8(ti))
+				continue
+			}
+			if idx, ok := exact[key(rgb)]; ok {
+				p.SetColorIndex(x, y, idx)
+				continue
+			}
+
+			switch dither {
+			case DitherFloydSteinberg:
+				for ch := 0; ch < 3; ch++ {
+					rgb[ch] = clamp(rgb[ch] + errCur[ex][ch]/16)
+				}
+			case DitherOrdered:
+				// 量子化の幅の目安は、パレットの色を RGB の立方体に並べた間隔とする
+				spread := 256 / (cbrt(len(pal)) + 1)
+				d := (bayer[y&7][x&7]*2 - 63) * spread / 128
+				for ch := 0; ch < 3; ch++ {
+					rgb[ch] = clamp(rgb[ch] + d)
+				}
+			}
+			idx := index(rgb)
+			p.SetColorIndex(x, y, idx)
+
+			if dither == DitherFloydSteinberg {
+				q := colors[idx]
+				for ch := 0; ch < 3; ch++ {
+					e := rgb[ch] - q[ch]
+					errCur[ex+1][ch] += e * 7
+					errNext[ex-1][ch] += e * 3
+					errNext[ex][ch] += e * 5
+					errNext[ex+1][ch] += e * 1
+				}
+			}
+		}
+		errCur, errNext = errNext, errCur
+		for j := range errNext {
+			errNext[j] = [3]int{}
+		}
+	}
+	return p
+}
+
+// nearestIndex は colors のうち rgb に最も近い色の添字を返す。
+// skip は透過色の添字。
+func nearestIndex(colors [][3]int, rgb [3]int, skip int) uint8 {
+	var (
+		best     int
+		bestDist = -1
+	)
+	for j, c := range colors {
+		if j == skip {
+			continue
+		}
+		var (
+			dr = rgb[0] - c[0]
+			dg = rgb[1] - c[1]
+			db = rgb[2] - c[2]
+			d  = dr*dr + dg*dg + db*db
+		)
+		if bestDist < 0 || d < bestDist {
+			best, bestDist = j, d
+		}
+	}
+	return uint8(best)
+}
+
+func key(rgb [3]int) uint32 {
+	return uint32(rgb[0])<<16 | uint32(rgb[1])<<8 | uint32(rgb[2])
+}
+
+func toRGBA(rgb [3]int) color.RGBA {
+	return color.RGBA{R: uint8(rgb[0]), G: uint8(rgb[1]), B: uint8(rgb[2]), A: 0xff}
+}
+
+func clamp(v int) int {
+	return min(max(v, 0), 255)
+}
+
+// cbrt は n の立方根を切り捨てた値を返す。
+func cbrt(n int) int {
+	r := 1
+	for (r+1)*(r+1)*(r+1) <= n {
+		r++
+	}
+	return r
+}
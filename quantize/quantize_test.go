@@ -0,0 +1,156 @@
+package quantize
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newImage(w, h int, f func(x, y int) color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, f(x, y))
+		}
+	}
+	return img
+}
+
+var (
+	red   = color.NRGBA{R: 255, A: 255}
+	green = color.NRGBA{G: 255, A: 255}
+	blue  = color.NRGBA{B: 255, A: 255}
+	clear = color.NRGBA{}
+)
+
+func TestPalette(t *testing.T) {
+	tests := []struct {
+		desc string
+		imgs []image.Image
+		n    int
+		want color.Palette
+	}{
+		{
+			desc: "正常系: 色数が少ない場合は全ての色を使う",
+			imgs: []image.Image{
+				newImage(3, 1, func(x, y int) color.NRGBA { return []color.NRGBA{blue, red, blue}[x] }),
+			},
+			n:    256,
+			want: color.Palette{color.RGBA{B: 255, A: 255}, color.RGBA{R: 255, A: 255}},
+		},
+		{
+			desc: "正常系: 透過色を末尾に含める",
+			imgs: []image.Image{
+				newImage(2, 1, func(x, y int) color.NRGBA { return []color.NRGBA{green, clear}[x] }),
+			},
+			n:    256,
+			want: color.Palette{color.RGBA{G: 255, A: 255}, color.RGBA{}},
+		},
+		{
+			desc: "正常系: 全てのフレームの色を使う",
+			imgs: []image.Image{
+				newImage(1, 1, func(x, y int) color.NRGBA { return red }),
+				newImage(1, 1, func(x, y int) color.NRGBA { return green }),
+			},
+			n:    256,
+			want: color.Palette{color.RGBA{G: 255, A: 255}, color.RGBA{R: 255, A: 255}},
+		},
+		{
+			desc: "正常系: 色数が多い場合は近い色をまとめる",
+			imgs: []image.Image{
+				newImage(4, 1, func(x, y int) color.NRGBA {
+					return []color.NRGBA{{R: 10, A: 255}, {R: 12, A: 255}, {B: 200, A: 255}, {B: 202, A: 255}}[x]
+				}),
+			},
+			n:    2,
+			want: color.Palette{color.RGBA{B: 201, A: 255}, color.RGBA{R: 11, A: 255}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got := Palette(tt.imgs, tt.n)
+			assert.ElementsMatch(t, tt.want, got)
+		})
+	}
+}
+
+func TestPalette_size(t *testing.T) {
+	img := newImage(64, 64, func(x, y int) color.NRGBA {
+		return color.NRGBA{R: uint8(x * 4), G: uint8(y * 4), B: uint8(x + y), A: 255}
+	})
+	for _, n := range []int{2, 16, 256} {
+		assert.Len(t, Palette([]image.Image{img}, n), n)
+	}
+}
+
+func TestPaletted(t *testing.T) {
+	gradient := newImage(32, 8, func(x, y int) color.NRGBA {
+		return color.NRGBA{R: uint8(x * 8), G: uint8(x * 8), B: uint8(x * 8), A: 255}
+	})
+	bw := color.Palette{color.RGBA{A: 255}, color.RGBA{R: 255, G: 255, B: 255, A: 255}}
+
+	tests := []struct {
+		desc   string
+		img    image.Image
+		pal    color.Palette
+		dither string
+	}{
+		{desc: "正常系: ディザリングなし", img: gradient, pal: bw, dither: DitherNone},
+		{desc: "正常系: Floyd-Steinberg", img: gradient, pal: bw, dither: DitherFloydSteinberg},
+		{desc: "正常系: ordered", img: gradient, pal: bw, dither: DitherOrdered},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			got := Paletted(tt.img, tt.pal, tt.dither)
+			assert.Equal(tt.img.Bounds(), got.Bounds())
+
+			// 左端は黒、右端は白になる
+			assert.Equal(uint8(0), got.ColorIndexAt(0, 0))
+			assert.Equal(uint8(1), got.ColorIndexAt(31, 0))
+
+			// 中間の明るさは、ディザリングすると同じ列に白と黒が混ざる
+			var mixed bool
+			for x := 8; x < 24; x++ {
+				for y := 1; y < 8; y++ {
+					mixed = mixed || got.ColorIndexAt(x, y) != got.ColorIndexAt(x, 0)
+				}
+			}
+			assert.Equal(tt.dither != DitherNone, mixed)
+		})
+	}
+}
+
+func TestPaletted_exact(t *testing.T) {
+	img := newImage(16, 16, func(x, y int) color.NRGBA {
+		switch {
+		case x < 4:
+			return clear
+		case (x+y)%3 == 0:
+			return red
+		case (x+y)%3 == 1:
+			return green
+		}
+		return blue
+	})
+	for _, d := range Dithers {
+		t.Run(d, func(t *testing.T) {
+			pal := Palette([]image.Image{img}, 256)
+			got := Paletted(img, pal, d)
+			for y := 0; y < 16; y++ {
+				for x := 0; x < 16; x++ {
+					want := color.RGBAModel.Convert(img.At(x, y))
+					assert.Equal(t, want, color.RGBAModel.Convert(got.At(x, y)))
+				}
+			}
+		})
+	}
+}
+
+func TestValidateDither(t *testing.T) {
+	assert.NoError(t, ValidateDither(DitherOrdered))
+	assert.Error(t, ValidateDither("random"))
+}
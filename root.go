@@ -1,14 +1,17 @@
 package main
 
 import (
-	"image/color"
+	"context"
+	"io"
+	"os"
 	"runtime"
 	"strings"
 
 	"github.com/jiro4989/textimg/v3/config"
 	"github.com/jiro4989/textimg/v3/image"
 	"github.com/jiro4989/textimg/v3/internal/global"
-	"github.com/jiro4989/textimg/v3/parser"
+	"github.com/jiro4989/textimg/v3/textimg"
+	"github.com/jiro4989/textimg/v3/token"
 
 	"github.com/spf13/cobra"
 )
@@ -27,7 +30,11 @@ func init() {
 available color types are [black|red|green|yellow|blue|magenta|cyan|white]
 or (R,G,B,A(0~255))`)
 	RootCommand.Flags().StringVarP(&conf.Background, "background", "b", "black", `background text color.
-color types are same as "foreground" option`)
+color types are same as "foreground" option or hex (#RRGGBB).
+gradients are also available: linear([DEGdeg,]COLOR,COLOR...) or radial(COLOR,COLOR...)`)
+	RootCommand.Flags().StringVarP(&conf.BackgroundImageFile, "background-image", "", "", "background image file path")
+	RootCommand.Flags().StringVarP(&conf.BackgroundImageMode, "background-image-mode", "", image.BackgroundImageModeScale, `how to place background image.
+available modes are [`+strings.Join(image.BackgroundImageModes, "|")+`]`)
 
 	var font string
 	envFontFile := envvars.FontFile
@@ -48,22 +55,90 @@ You can change this default value with environment variables TEXTIMG_FONT_FILE`)
 
 	RootCommand.Flags().IntVarP(&conf.FontSize, "fontsize", "F", 20, "font size")
 	RootCommand.Flags().StringVarP(&conf.Outpath, "out", "o", "", `output image file path.
-available image formats are [png | jpg | gif]`)
+available image formats are [png | apng | jpg | gif | webp | svg | pdf | html]`)
+	RootCommand.Flags().StringVarP(&conf.Format, "format", "", "", `output format. overrides the format detected from the extension of output file path.
+available formats are [`+strings.Join(image.FormatNames(), "|")+`]`)
 	RootCommand.Flags().BoolVarP(&conf.AddTimeStamp, "timestamp", "t", false, `add time stamp to output image file path.`)
 	RootCommand.Flags().BoolVarP(&conf.SaveNumberedFile, "numbered", "n", false, `add number-suffix to filename when the output file was existed.
 ex: t_2.png`)
 	RootCommand.Flags().BoolVarP(&conf.UseShellgeiImagedir, "shellgei-imagedir", "s", false, `image directory path (path: "$HOME/Pictures/t.png" or "$TEXTIMG_OUTPUT_DIR/t.png")`)
 
-	RootCommand.Flags().BoolVarP(&conf.UseAnimation, "animation", "a", false, "generate animation gif")
+	RootCommand.Flags().BoolVarP(&conf.UseAnimation, "animation", "a", false, "generate animation gif, apng or webp")
 	RootCommand.Flags().IntVarP(&conf.Delay, "delay", "d", 20, "animation delay time")
+	RootCommand.Flags().StringVarP(&conf.Dither, "dither", "", "floyd-steinberg", "dithering method for gif color reduction [none|floyd-steinberg|ordered]")
+	RootCommand.Flags().BoolVarP(&conf.GlobalPalette, "global-palette", "", false, "use a single palette shared across all animation gif frames")
+	RootCommand.Flags().IntVarP(&conf.Loop, "loop", "", 0, "animation loop count. 0 means infinite")
+	RootCommand.Flags().IntVarP(&conf.LastFrameDelay, "last-frame-delay", "", 0, "delay time of the last animation frame. 0 means the same as --delay")
 	RootCommand.Flags().IntVarP(&conf.LineCount, "line-count", "l", 1, "animation input line count")
+	RootCommand.Flags().StringVarP(&conf.FrameSeparator, "frame-separator", "", "", `line prefix that separates animation frames in input instead of "line-count" option.
+frames are not separated when empty. a delay time after the prefix sets the delay of the previous frame.
+ex: --frame-separator $'\f' with a "\f 150" line`)
 	RootCommand.Flags().BoolVarP(&conf.UseSlideAnimation, "slide", "S", false, "use slide animation")
 	RootCommand.Flags().IntVarP(&conf.SlideWidth, "slide-width", "W", 1, "sliding animation width")
-	RootCommand.Flags().BoolVarP(&conf.SlideForever, "forever", "E", false, "sliding forever")
+	RootCommand.Flags().BoolVarP(&conf.SlideForever, "forever", "E", false, "sliding forever. also repeats marquee animation without a break")
+	RootCommand.Flags().StringVarP(&conf.Playback, "playback", "", image.PlaybackForward, `playback order of animation frames.
+available orders are [`+strings.Join(image.Playbacks, "|")+`]`)
+	RootCommand.Flags().StringVarP(&conf.Transition, "transition", "", image.TransitionNone, `transition effect between animation frames.
+available transitions are [`+strings.Join(image.Transitions, "|")+`]`)
+	RootCommand.Flags().IntVarP(&conf.TransitionSteps, "transition-steps", "", 4, "number of intermediate frames of transition effect")
+	RootCommand.Flags().BoolVarP(&conf.Typewriter, "typewriter", "", false, "use typewriter animation that shows text little by little with a cursor")
+	RootCommand.Flags().StringVarP(&conf.TypeUnit, "type-unit", "", image.TypeUnitChar, `unit of text to show at once in typewriter animation.
+available units are [`+strings.Join(image.TypeUnits, "|")+`]`)
+	RootCommand.Flags().IntVarP(&conf.TypeStep, "type-step", "", 1, "number of units to show per frame in typewriter animation")
+	RootCommand.Flags().IntVarP(&conf.NewlinePause, "newline-pause", "", 50, "pause time at the end of lines in typewriter animation. the cursor blinks while pausing")
+	RootCommand.Flags().StringVarP(&conf.Cursor, "cursor", "", image.CursorBlock, `cursor style of typewriter animation.
+available styles are [`+strings.Join(image.CursorStyles, "|")+`]`)
+	RootCommand.Flags().BoolVarP(&conf.Marquee, "marquee", "", false, "use marquee animation that scrolls text horizontally through a fixed width viewport")
+	RootCommand.Flags().IntVarP(&conf.MarqueeWidth, "marquee-width", "", 20, "viewport width of marquee animation (columns)")
+	RootCommand.Flags().StringVarP(&conf.MarqueeStep, "marquee-step", "", "1", `scroll amount per frame of marquee animation.
+N scrolls N columns and Npx scrolls N pixels`)
 	RootCommand.Flags().BoolVarP(&conf.PrintEnvironments, "environments", "", false, "print environment variables")
 	RootCommand.Flags().BoolVarP(&conf.ToSlackIcon, "slack", "", false, "resize to slack icon size (128x128 px)")
+	RootCommand.Flags().BoolVarP(&conf.HTMLFragment, "html-fragment", "", false, "output only the <pre> element instead of a standalone html page")
+	RootCommand.Flags().BoolVarP(&conf.HTMLClasses, "html-classes", "", false, "use css classes instead of inline styles for the 16 palette colors in html")
+	RootCommand.Flags().IntVarP(&conf.PageRows, "page-rows", "", 0, "number of rows per pdf page. 0 puts all rows on a single page")
+	RootCommand.Flags().IntVarP(&conf.PageMargin, "page-margin", "", 36, "margin of pdf pages (pt)")
+	RootCommand.Flags().BoolVarP(&conf.EmbedFont, "embed-font", "", false, "embed the subset of the font into the svg file")
+	RootCommand.Flags().StringVarP(&conf.Preset, "preset", "", "", "resize for the platform and fit within its file size limit ["+strings.Join(textimg.PresetNames(), "|")+"]")
 	RootCommand.Flags().IntVarP(&conf.ResizeWidth, "resize-width", "", 0, "resize width")
 	RootCommand.Flags().IntVarP(&conf.ResizeHeight, "resize-height", "", 0, "resize height")
+	RootCommand.Flags().StringVarP(&conf.Fit, "fit", "", "", "how to keep aspect ratio when resizing [fill|contain|cover|pad] (default: fill, or the fit mode of --preset)")
+	RootCommand.Flags().IntVarP(&conf.Scale, "scale", "", 1, "render at N times font size, padding and spacing for HiDPI displays")
+	RootCommand.Flags().StringVarP(&conf.Resample, "resample", "", "catmullrom", "resampling filter for resizing [nearest|bilinear|catmullrom|lanczos]")
+	RootCommand.Flags().StringVarP(&conf.Anchor, "anchor", "", "center", "position to crop or pad when resizing [center|top|bottom|left|right|top-left|top-right|bottom-left|bottom-right]")
+	RootCommand.Flags().StringVarP(&conf.Padding, "padding", "", "0", `padding of image (px).
+format is same as CSS padding: all | vertical,horizontal | top,horizontal,bottom | top,right,bottom,left`)
+	RootCommand.Flags().IntVarP(&conf.LineSpacing, "line-spacing", "", 0, "additional spacing between lines (px)")
+	RootCommand.Flags().IntVarP(&conf.LetterSpacing, "letter-spacing", "", 0, "additional spacing between letters (px)")
+	RootCommand.Flags().BoolVarP(&conf.LineNumbers, "line-numbers", "", false, "show line numbers")
+	RootCommand.Flags().IntVarP(&conf.StartLine, "start-line", "", 1, "line number of the first input line")
+	RootCommand.Flags().StringVarP(&conf.HighlightLines, "highlight-lines", "", "", `highlight lines.
+ex: 3,7-9`)
+	RootCommand.Flags().StringVarP(&conf.HighlightColor, "highlight-color", "", "255,255,0,64", `color to tint highlighted lines.
+color types are same as "foreground" option`)
+	RootCommand.Flags().StringVarP(&conf.Lines, "lines", "", "", `range of input lines to draw.
+ex: 10-40, 10-, -40`)
+	RootCommand.Flags().IntVarP(&conf.Columns, "columns", "", 0, "wrap lines at this display width (columns)")
+	RootCommand.Flags().StringVarP(&conf.WrapMode, "wrap-mode", "", token.WrapModeChar, `how to wrap lines with "columns" option.
+available modes are [`+strings.Join(token.WrapModes, "|")+`]`)
+	RootCommand.Flags().BoolVarP(&conf.Truncate, "truncate", "", false, `truncate lines with ellipsis instead of wrapping with "columns" option`)
+	RootCommand.Flags().IntVarP(&conf.Cols, "cols", "", 0, `number of columns of canvas like terminal.
+overflowed characters are cropped`)
+	RootCommand.Flags().IntVarP(&conf.Rows, "rows", "", 0, `number of rows of canvas like terminal.
+overflowed lines are cropped`)
+	RootCommand.Flags().BoolVarP(&conf.Tail, "tail", "", false, `keep the last lines instead of the first lines with "rows" option`)
+	RootCommand.Flags().IntVarP(&conf.SplitRows, "split-rows", "", 0, `split output into multiple images of N rows each. ex: out_001.png, out_002.png
+input is read and rendered incrementally, so large input can be converted with bounded memory.
+all images have the same width. piped input is buffered in a temporary file to measure the width`)
+	RootCommand.Flags().StringVarP(&conf.Frame, "frame", "", image.FrameStyleNone, `window frame style around the image.
+available styles are [`+strings.Join(image.FrameStyles, "|")+`]`)
+	RootCommand.Flags().StringVarP(&conf.Title, "title", "", "", "title of window frame")
+	RootCommand.Flags().IntVarP(&conf.FrameMargin, "frame-margin", "", 20, "margin around window frame (px)")
+	RootCommand.Flags().BoolVarP(&conf.Transparent, "transparent", "", false, `make background transparent.
+supported formats are [`+strings.Join(image.FormatExtensions(func(f image.Format) bool { return f.Transparency }), "|")+`]`)
+	RootCommand.Flags().BoolVarP(&conf.KeepANSIBackground, "keep-ansi-background", "", false, `keep background colors of escape sequences opaque with "transparent" option`)
+	RootCommand.Flags().StringVarP(&conf.FrameBackground, "frame-background", "", "0,0,0,0", `color of margin around window frame.
+color types are same as "foreground" option`)
 }
 
 var RootCommand = &cobra.Command{
@@ -78,75 +153,34 @@ var RootCommand = &cobra.Command{
 
 func RunRootCommand(c config.Config, args []string, envs config.EnvVars) error {
 	if c.PrintEnvironments {
-		config.PrintEnvs()
+		envs.PrintEnvs(os.Stdout)
 		return nil
 	}
 
 	if err := c.Adjust(args, envs); err != nil {
 		return err
 	}
-	defer c.Writer.Close()
-
-	tokens, err := parser.Parse(strings.Join(c.Texts, "\n"))
-	if err != nil {
-		return err
-	}
 
-	bw := tokens.MaxStringWidth()
-	bh := len(tokens.StringLines())
-
-	if !c.ToSlackIcon {
-		// TODO: コピペコードになってるので共通化する
-		var (
-			f          = c.FontSize
-			charWidth  = f / 2
-			charHeight = int(float64(f) * 1.1)
-			w          = bw * charWidth
-			h          = bh * charHeight
-		)
-		c.ResizeWidth, c.ResizeHeight = complementWidthHeight(w, h, c.ResizeWidth, c.ResizeHeight)
-	}
+	opt := c.Options()
 
-	param := &image.ImageParam{
-		BaseWidth:          bw,
-		BaseHeight:         bh,
-		ForegroundColor:    color.RGBA(c.ForegroundColor),
-		BackgroundColor:    color.RGBA(c.BackgroundColor),
-		FontFace:           c.FontFace,
-		EmojiFontFace:      c.EmojiFontFace,
-		EmojiDir:           c.EmojiDir,
-		FontSize:           c.FontSize,
-		Delay:              c.Delay,
-		UseAnimation:       c.UseAnimation,
-		AnimationLineCount: c.LineCount,
-		ResizeWidth:        c.ResizeWidth,
-		ResizeHeight:       c.ResizeHeight,
-		UseEmoji:           c.UseEmojiFont,
-	}
-	img := image.NewImage(param)
-	if err := img.Draw(tokens); err != nil {
+	// 分割する場合は入力を逐次読み込んで、画像ごとにファイルを作る
+	if 0 < c.SplitRows {
+		_, err := textimg.RenderChunks(context.Background(), c.Input, c.OutputFormat.Name, c.SplitRows, opt, func(n int) (io.WriteCloser, error) {
+			return os.Create(c.ChunkPath(n))
+		})
 		return err
 	}
-	if err := img.Encode(c.Writer, c.FileExtension); err != nil {
+	defer c.Writer.Close()
+
+	// 各行を改行で終わるテキストとして渡す
+	text := strings.Join(c.Texts, "\n") + "\n"
+	if err := textimg.RenderTo(context.Background(), c.Writer, strings.NewReader(text), c.OutputFormat.Name, opt); err != nil {
+		// 描画に失敗した時は、作った出力先のファイルを空のまま残さない
+		if f, ok := c.Writer.(*os.File); ok && f.Name() == c.Outpath {
+			f.Close()
+			os.Remove(c.Outpath)
+		}
 		return err
 	}
-
 	return nil
 }
-
-// complementWidthHeight は width, height の片方が 0 の時、サイズを調整する。
-func complementWidthHeight(x, y, w, h int) (int, int) {
-	if w == 0 {
-		hh := y
-		d := float64(h) / float64(hh)
-		w = int(float64(x) * d)
-		return w, h
-	}
-	if h == 0 {
-		ww := x
-		d := float64(w) / float64(ww)
-		h = int(float64(y) * d)
-		return w, h
-	}
-	return w, h
-}
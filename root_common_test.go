@@ -27,6 +27,8 @@ func newDefaultConfig() config.Config {
 		UseShellgeiEmojiFontfile: false,
 		ResizeWidth:              0,
 		ResizeHeight:             0,
+		StartLine:                1,
+		HighlightColor:           "255,255,0,64",
 		Writer:                   config.NewMockWriter(false, false),
 	}
 }
@@ -426,6 +426,1383 @@ func TestRunRootCommand(t *testing.T) {
 			wantErr:    false,
 			existsFile: outDir + "/root_test_index.png",
 		},
+		{
+			desc: "正常系: 余白を指定できる",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_padding.png"
+				c.Writer = nil
+				c.Padding = "10,20"
+				return c
+			}(),
+			args:       []string{"\x1b[31mpadding\x1b[0m\nあいう"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_padding.png",
+		},
+		{
+			desc: "正常系: 行間と文字間を指定できる",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_line_letter_spacing.png"
+				c.Writer = nil
+				c.LineSpacing = 10
+				c.LetterSpacing = 4
+				return c
+			}(),
+			args:       []string{"spacing\n\x1b[42mspacing\x1b[0m"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_line_letter_spacing.png",
+		},
+		{
+			desc: "正常系: 余白を指定してアニメーションを生成できる",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_padding_animation.gif"
+				c.Writer = nil
+				c.Padding = "8"
+				c.UseAnimation = true
+				c.LineCount = 2
+				return c
+			}(),
+			args:       []string{"1\n2\n3\n4\n5"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_padding_animation.gif",
+		},
+		{
+			desc: "異常系: 余白の指定が不正",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_padding_illegal.png"
+				c.Writer = nil
+				c.Padding = "1,2,3,4,5"
+				return c
+			}(),
+			args:    []string{"padding"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "正常系: macスタイルのウィンドウ枠で囲う",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_frame_mac.png"
+				c.Writer = nil
+				c.Frame = "mac"
+				c.Title = "bash"
+				c.FrameMargin = 20
+				c.Padding = "10"
+				return c
+			}(),
+			args:       []string{"\x1b[32m$\x1b[0m echo hello\nhello"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_frame_mac.png",
+		},
+		{
+			desc: "正常系: windowsスタイルのウィンドウ枠で囲う",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_frame_windows.png"
+				c.Writer = nil
+				c.Frame = "windows"
+				c.Title = "cmd.exe"
+				c.Background = "white"
+				c.Foreground = "black"
+				c.FrameBackground = "0,128,128,255"
+				c.FrameMargin = 16
+				return c
+			}(),
+			args:       []string{"C:\\> echo hello\nhello"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_frame_windows.png",
+		},
+		{
+			desc: "正常系: ウィンドウ枠付きのアニメーションを生成できる",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_frame_animation.gif"
+				c.Writer = nil
+				c.Frame = "mac"
+				c.UseAnimation = true
+				return c
+			}(),
+			args:       []string{"1\n2\n3"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_frame_animation.gif",
+		},
+		{
+			desc: "異常系: ウィンドウ枠のスタイルが不正",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_frame_illegal.png"
+				c.Writer = nil
+				c.Frame = "linux"
+				return c
+			}(),
+			args:    []string{"frame"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "正常系: 背景を透過したPNGを生成できる",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_transparent.png"
+				c.Writer = nil
+				c.Transparent = true
+				return c
+			}(),
+			args:       []string{"\x1b[31mred\x1b[42mgreen\x1b[0m"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_transparent.png",
+		},
+		{
+			desc: "正常系: 背景を透過したGIFを生成できる",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_transparent.gif"
+				c.Writer = nil
+				c.Transparent = true
+				return c
+			}(),
+			args:       []string{"\x1b[31mred\x1b[42mgreen\x1b[0m"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_transparent.gif",
+		},
+		{
+			desc: "正常系: 背景を透過したアニメーションGIFを生成できる",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_transparent_animation.gif"
+				c.Writer = nil
+				c.Transparent = true
+				c.UseAnimation = true
+				return c
+			}(),
+			args:       []string{"\x1b[31m1\n\x1b[32m2\n\x1b[33m3"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_transparent_animation.gif",
+		},
+		{
+			desc: "正常系: 透過時もエスケープシーケンスの背景色を残せる",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_transparent_keep_ansi_background.png"
+				c.Writer = nil
+				c.Transparent = true
+				c.KeepANSIBackground = true
+				return c
+			}(),
+			args:       []string{"\x1b[31mred\x1b[42mgreen\x1b[0m"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_transparent_keep_ansi_background.png",
+		},
+		{
+			desc: "異常系: JPEGは透過できない",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_transparent.jpg"
+				c.Writer = nil
+				c.Transparent = true
+				return c
+			}(),
+			args:    []string{"jpeg"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "正常系: 線形グラデーションの背景を描画できる",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_background_linear.png"
+				c.Writer = nil
+				c.Background = "linear(90deg,#000,#335,#000)"
+				c.Padding = "10"
+				return c
+			}(),
+			args:       []string{"\x1b[31mred\x1b[42mgreen\x1b[0m\ngradient"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_background_linear.png",
+		},
+		{
+			desc: "正常系: 円形グラデーションの背景を描画できる",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_background_radial.png"
+				c.Writer = nil
+				c.Background = "radial(#335,#000)"
+				return c
+			}(),
+			args:       []string{"radial\ngradient"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_background_radial.png",
+		},
+		{
+			desc: "正常系: 背景画像をタイル状に並べられる",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_background_image_tile.png"
+				c.Writer = nil
+				c.BackgroundImageFile = inDir + "/background.png"
+				c.BackgroundImageMode = "tile"
+				return c
+			}(),
+			args:       []string{"background\nimage"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_background_image_tile.png",
+		},
+		{
+			desc: "正常系: 背景画像を中央に配置できる",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_background_image_center.png"
+				c.Writer = nil
+				c.BackgroundImageFile = inDir + "/background.png"
+				c.BackgroundImageMode = "center"
+				return c
+			}(),
+			args:       []string{"background\nimage"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_background_image_center.png",
+		},
+		{
+			desc: "正常系: 背景画像を拡大できる",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_background_image_scale.png"
+				c.Writer = nil
+				c.BackgroundImageFile = inDir + "/background.png"
+				return c
+			}(),
+			args:       []string{"background\nimage"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_background_image_scale.png",
+		},
+		{
+			desc: "正常系: グラデーションの背景でアニメーションを生成できる",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_background_linear_animation.gif"
+				c.Writer = nil
+				c.Background = "linear(#000,#335)"
+				c.UseAnimation = true
+				return c
+			}(),
+			args:       []string{"1\n2\n3"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_background_linear_animation.gif",
+		},
+		{
+			desc: "異常系: グラデーションの指定が不正",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_background_illegal.png"
+				c.Writer = nil
+				c.Background = "linear(#000)"
+				return c
+			}(),
+			args:    []string{"gradient"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "異常系: 背景画像の配置方法が不正",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_background_image_illegal_mode.png"
+				c.Writer = nil
+				c.BackgroundImageFile = inDir + "/background.png"
+				c.BackgroundImageMode = "stretch"
+				return c
+			}(),
+			args:    []string{"background"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "異常系: 背景画像が存在しない",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_background_image_not_found.png"
+				c.Writer = nil
+				c.BackgroundImageFile = inDir + "/not_found.png"
+				return c
+			}(),
+			args:    []string{"background"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "正常系: 行番号を表示できる",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_line_numbers.png"
+				c.Writer = nil
+				c.LineNumbers = true
+				c.StartLine = 8
+				return c
+			}(),
+			args:       []string{"\x1b[31m1\n\x1b[32m2\n3\n4"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_line_numbers.png",
+		},
+		{
+			desc: "正常系: 行を強調表示できる",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_highlight_lines.png"
+				c.Writer = nil
+				c.LineNumbers = true
+				c.HighlightLines = "2,4-5"
+				c.HighlightColor = "255,255,0,64"
+				return c
+			}(),
+			args:       []string{"1\n\x1b[31m2\n3\n4\n5\n6"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_highlight_lines.png",
+		},
+		{
+			desc: "正常系: 入力の一部の行を描画できる",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_lines.png"
+				c.Writer = nil
+				c.LineNumbers = true
+				c.Lines = "3-4"
+				return c
+			}(),
+			args:       []string{"\x1b[31m1\n\x1b[32m2\n3\n4\n5"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_lines.png",
+		},
+		{
+			desc: "異常系: 行の範囲指定が不正",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_lines_illegal.png"
+				c.Writer = nil
+				c.Lines = "4-3"
+				return c
+			}(),
+			args:    []string{"1\n2\n3\n4"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "異常系: 範囲内に行が存在しない",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_lines_empty.png"
+				c.Writer = nil
+				c.Lines = "10-"
+				return c
+			}(),
+			args:    []string{"1\n2\n3\n4"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "異常系: 強調表示する行の指定が不正",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_highlight_lines_illegal.png"
+				c.Writer = nil
+				c.HighlightLines = "a"
+				return c
+			}(),
+			args:    []string{"1\n2"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "正常系: 指定の幅で折り返す",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_columns.png"
+				c.Writer = nil
+				c.Columns = 6
+				c.LineNumbers = true
+				return c
+			}(),
+			args:       []string{"\x1b[31mhello \x1b[42mworld\x1b[0m foo\nbar"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_columns.png",
+		},
+		{
+			desc: "正常系: 単語単位で折り返す",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_columns_word.png"
+				c.Writer = nil
+				c.Columns = 8
+				c.WrapMode = "word"
+				c.LineNumbers = true
+				c.HighlightLines = "1"
+				return c
+			}(),
+			args:       []string{"\x1b[31mhello \x1b[42mworld\x1b[0m foo\nbar"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_columns_word.png",
+		},
+		{
+			desc: "正常系: 折り返さずに切り詰める",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_columns_truncate.png"
+				c.Writer = nil
+				c.Columns = 6
+				c.Truncate = true
+				return c
+			}(),
+			args:       []string{"\x1b[31mhello \x1b[42mworld\x1b[0m foo\nbar"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_columns_truncate.png",
+		},
+		{
+			desc: "異常系: 折り返し方法が不正",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_columns_illegal.png"
+				c.Writer = nil
+				c.Columns = 6
+				c.WrapMode = "line"
+				return c
+			}(),
+			args:    []string{"hello"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "正常系: 行数と列数を指定して余白を埋める",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_cols_rows_pad.png"
+				c.Writer = nil
+				c.Cols = 20
+				c.Rows = 5
+				c.LineNumbers = true
+				return c
+			}(),
+			args:       []string{"\x1b[31mhello\n\x1b[42mworld"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_cols_rows_pad.png",
+		},
+		{
+			desc: "正常系: 行数と列数を超える部分は切り捨てる",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_cols_rows_crop.png"
+				c.Writer = nil
+				c.Cols = 4
+				c.Rows = 2
+				c.LineNumbers = true
+				return c
+			}(),
+			args:       []string{"\x1b[31mhello\n\x1b[42mworld\x1b[0m\nfoo"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_cols_rows_crop.png",
+		},
+		{
+			desc: "正常系: 行数を超える場合に末尾の行を残す",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_rows_tail.png"
+				c.Writer = nil
+				c.Rows = 2
+				c.Tail = true
+				c.LineNumbers = true
+				return c
+			}(),
+			args:       []string{"\x1b[31mhello\n\x1b[42mworld\x1b[0m\nfoo"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_rows_tail.png",
+		},
+		{
+			desc: "正常系: 縦横比を保って全体が収まるように縮小する",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_fit_contain.png"
+				c.Writer = nil
+				c.ResizeWidth = 128
+				c.ResizeHeight = 128
+				c.Fit = "contain"
+				return c
+			}(),
+			args:       []string{"\x1b[31mhello world"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_fit_contain.png",
+		},
+		{
+			desc: "正常系: 縦横比を保って全体を覆うように拡大し左上を基準に切り抜く",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_fit_cover_anchor.png"
+				c.Writer = nil
+				c.ResizeWidth = 128
+				c.ResizeHeight = 128
+				c.Fit = "cover"
+				c.Anchor = "top-left"
+				return c
+			}(),
+			args:       []string{"\x1b[31mhello world"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_fit_cover_anchor.png",
+		},
+		{
+			desc: "正常系: 拡大せずに下を基準に余白を埋める",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_fit_pad.png"
+				c.Writer = nil
+				c.ResizeWidth = 400
+				c.ResizeHeight = 200
+				c.Fit = "pad"
+				c.Anchor = "bottom"
+				return c
+			}(),
+			args:       []string{"\x1b[31mhello world"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_fit_pad.png",
+		},
+		{
+			desc: "正常系: Slackのアイコンは縦横比を保つ",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_fit_slack.png"
+				c.Writer = nil
+				c.ToSlackIcon = true
+				return c
+			}(),
+			args:       []string{"\x1b[31mhello world"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_fit_slack.png",
+		},
+		{
+			desc: "正常系: アニメーションの各フレームも縦横比を保つ",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_fit_animation.gif"
+				c.Writer = nil
+				c.ResizeWidth = 128
+				c.ResizeHeight = 128
+				c.Fit = "contain"
+				c.UseAnimation = true
+				c.LineCount = 1
+				return c
+			}(),
+			args:       []string{"\x1b[31mhello world\nfoo"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_fit_animation.gif",
+		},
+		{
+			desc: "異常系: 不正なfit",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_fit_err.png"
+				c.Writer = nil
+				c.Fit = "sushi"
+				return c
+			}(),
+			args:    []string{"hello"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "正常系: 描画倍率を指定すると大きなフォントで描画する",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_scale.png"
+				c.Writer = nil
+				c.Scale = 2
+				c.Padding = "4"
+				c.Frame = "mac"
+				return c
+			}(),
+			args:       []string{"\x1b[31mhello"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_scale.png",
+		},
+		{
+			desc: "正常系: 最近傍補間で拡大する",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_resample_nearest.png"
+				c.Writer = nil
+				c.ResizeWidth = 400
+				c.Resample = "nearest"
+				return c
+			}(),
+			args:       []string{"\x1b[31mhello"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_resample_nearest.png",
+		},
+		{
+			desc: "正常系: Lanczos補間で縮小する",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_resample_lanczos.png"
+				c.Writer = nil
+				c.ResizeWidth = 40
+				c.Resample = "lanczos"
+				return c
+			}(),
+			args:       []string{"\x1b[31mhello"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_resample_lanczos.png",
+		},
+		{
+			desc: "異常系: 不正な描画倍率",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_scale_err.png"
+				c.Writer = nil
+				c.Scale = -1
+				return c
+			}(),
+			args:    []string{"hello"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "正常系: プリセットの大きさで出力する",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_preset_discord.png"
+				c.Writer = nil
+				c.Preset = "discord"
+				return c
+			}(),
+			args:       []string{"\x1b[31mhello world"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_preset_discord.png",
+		},
+		{
+			desc: "正常系: プリセットの大きさでアニメーションを出力する",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_preset_line.gif"
+				c.Writer = nil
+				c.Preset = "line"
+				c.UseAnimation = true
+				c.LineCount = 1
+				return c
+			}(),
+			args:       []string{"\x1b[31mhello\n\x1b[32mworld"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_preset_line.gif",
+		},
+		{
+			desc: "異常系: 存在しないプリセット",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_preset_err.png"
+				c.Writer = nil
+				c.Preset = "sushi"
+				return c
+			}(),
+			args:    []string{"hello"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "正常系: SVGで出力する",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_svg.svg"
+				c.Writer = nil
+				c.LineNumbers = true
+				c.HighlightLines = "2"
+				c.Padding = "8"
+				return c
+			}(),
+			args:       []string{"\x1b[31mhello \x1b[42m<world>\x1b[0m\nあいう & é"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_svg.svg",
+		},
+		{
+			desc: "正常系: SVGにフォントを埋め込む",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_svg_embed_font.svg"
+				c.Writer = nil
+				c.EmbedFont = true
+				c.Transparent = true
+				c.ResizeWidth = 400
+				c.ResizeHeight = 400
+				c.Fit = "contain"
+				c.Anchor = "top"
+				return c
+			}(),
+			args:       []string{"\x1b[31mhello\n\x1b[32mworld"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_svg_embed_font.svg",
+		},
+		{
+			desc: "異常系: SVGでアニメーションは指定できない",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_svg_animation.svg"
+				c.Writer = nil
+				c.UseAnimation = true
+				return c
+			}(),
+			args:    []string{"hello"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "異常系: SVGでウィンドウ枠は指定できない",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_svg_frame.svg"
+				c.Writer = nil
+				c.Frame = "mac"
+				return c
+			}(),
+			args:    []string{"hello"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "正常系: HTMLで出力する",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_html.html"
+				c.Writer = nil
+				c.HTMLClasses = true
+				return c
+			}(),
+			args:       []string{"\x1b[1;31mhello\x1b[0m \x1b[42m<world>"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_html.html",
+		},
+		{
+			desc: "正常系: HTMLを断片で出力する",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_html_fragment.htm"
+				c.Writer = nil
+				c.HTMLFragment = true
+				c.Columns = 3
+				return c
+			}(),
+			args:       []string{"\x1b[31mhello world"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_html_fragment.htm",
+		},
+		{
+			desc: "正常系: PDFで出力する",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_pdf.pdf"
+				c.Writer = nil
+				c.LineNumbers = true
+				c.HighlightLines = "2"
+				c.Padding = "8"
+				return c
+			}(),
+			args:       []string{"\x1b[31mhello \x1b[42mworld\x1b[0m\nあいう"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_pdf.pdf",
+		},
+		{
+			desc: "正常系: PDFを複数ページで出力する",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_pdf_pages.pdf"
+				c.Writer = nil
+				c.PageRows = 2
+				c.PageMargin = 10
+				return c
+			}(),
+			args:       []string{"1\n\x1b[31m2\n3\n\x1b[44m4\n5"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_pdf_pages.pdf",
+		},
+		{
+			desc: "異常系: PDFでウィンドウ枠は指定できない",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_pdf_frame.pdf"
+				c.Writer = nil
+				c.Frame = "mac"
+				return c
+			}(),
+			args:    []string{"hello"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "正常系: WebPで出力する",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_webp.webp"
+				c.Writer = nil
+				c.Transparent = true
+				return c
+			}(),
+			args:       []string{"\x1b[31mhello \x1b[42mworld"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_webp.webp",
+		},
+		{
+			desc: "正常系: アニメーションWebPを生成できる",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_animation.webp"
+				c.Writer = nil
+				c.UseAnimation = true
+				c.LineCount = 1
+				return c
+			}(),
+			args:       []string{"\x1b[31m1\n\x1b[32m2\n\x1b[33m3"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_animation.webp",
+		},
+		{
+			desc: "正常系: アニメーションPNGを生成できる",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_animation.png"
+				c.Writer = nil
+				c.UseAnimation = true
+				c.LineCount = 1
+				c.Loop = 3
+				return c
+			}(),
+			args:       []string{"\x1b[31m1\n\x1b[32m2\n\x1b[33m3"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_animation.png",
+		},
+		{
+			desc: "正常系: apngの拡張子でアニメーションPNGを生成できる",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_animation.apng"
+				c.Writer = nil
+				c.UseAnimation = true
+				c.LineCount = 2
+				c.MaxBytes = 600
+				return c
+			}(),
+			args:       []string{"\x1b[38;2;255;0;0m1\n\x1b[38;2;250;10;0m2\n\x1b[38;2;240;20;0m3"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_animation.apng",
+		},
+		{
+			desc: "異常系: ループ回数は負数にできない",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_loop.apng"
+				c.Writer = nil
+				c.UseAnimation = true
+				c.Loop = -1
+				return c
+			}(),
+			args:    []string{"1"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "正常系: 共通のパレットでアニメーションGIFを生成できる",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_animation_global_palette.gif"
+				c.Writer = nil
+				c.UseAnimation = true
+				c.LineCount = 1
+				c.GlobalPalette = true
+				c.Dither = "ordered"
+				return c
+			}(),
+			args:       []string{"\x1b[38;2;255;0;0m1\n\x1b[38;2;0;128;255m2\n\x1b[38;2;20;200;20m3"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_animation_global_palette.gif",
+		},
+		{
+			desc: "正常系: ループ回数と最後のフレームのディレイを指定してアニメーションGIFを生成できる",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_animation_loop.gif"
+				c.Writer = nil
+				c.UseAnimation = true
+				c.LineCount = 1
+				c.Loop = 2
+				c.LastFrameDelay = 100
+				return c
+			}(),
+			args:       []string{"1\n1\n2\n\x1b[31m3"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_animation_loop.gif",
+		},
+		{
+			desc: "異常系: 最後のフレームのディレイは負数にできない",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_last_frame_delay.gif"
+				c.Writer = nil
+				c.UseAnimation = true
+				c.LastFrameDelay = -1
+				return c
+			}(),
+			args:    []string{"1"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "正常系: タイプライターアニメーション",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_typewriter.gif"
+				c.Writer = nil
+				c.Typewriter = true
+				c.NewlinePause = 50
+				return c
+			}(),
+			args:       []string{"hello \x1b[31mworld\nあいう"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_typewriter.gif",
+		},
+		{
+			desc: "正常系: 単語ごとのタイプライターアニメーション",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_typewriter_word.webp"
+				c.Writer = nil
+				c.Typewriter = true
+				c.TypeUnit = "word"
+				c.TypeStep = 2
+				c.Cursor = "bar"
+				c.LineNumbers = true
+				return c
+			}(),
+			args:       []string{"hello world foo\nbar"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_typewriter_word.webp",
+		},
+		{
+			desc: "正常系: カーソルを表示しないタイプライターアニメーション",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_typewriter_no_cursor.png"
+				c.Writer = nil
+				c.Typewriter = true
+				c.Cursor = "none"
+				c.Transparent = true
+				return c
+			}(),
+			args:       []string{"hello"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_typewriter_no_cursor.png",
+		},
+		{
+			desc: "異常系: タイプライターアニメーションとスライドアニメーションは併用できない",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_typewriter_slide.gif"
+				c.Writer = nil
+				c.Typewriter = true
+				c.UseSlideAnimation = true
+				return c
+			}(),
+			args:    []string{"1\n2"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "異常系: 不正なカーソルのスタイル",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_typewriter_cursor.gif"
+				c.Writer = nil
+				c.Typewriter = true
+				c.Cursor = "sushi"
+				return c
+			}(),
+			args:    []string{"1"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "異常系: 行末で止まる時間は負数にできない",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_typewriter_pause.gif"
+				c.Writer = nil
+				c.Typewriter = true
+				c.NewlinePause = -1
+				return c
+			}(),
+			args:    []string{"1"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "正常系: マーキーアニメーション",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_marquee.gif"
+				c.Writer = nil
+				c.Marquee = true
+				c.MarqueeWidth = 5
+				c.MarqueeStep = "2"
+				return c
+			}(),
+			args:       []string{"hello \x1b[31mworld\nあいう"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_marquee.gif",
+		},
+		{
+			desc: "正常系: 途切れずに繰り返すマーキーアニメーション",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_marquee_forever.png"
+				c.Writer = nil
+				c.Marquee = true
+				c.MarqueeStep = "7px"
+				c.SlideForever = true
+				c.LineNumbers = true
+				c.ResizeWidth = 100
+				return c
+			}(),
+			args:       []string{"hello world "},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_marquee_forever.png",
+		},
+		{
+			desc: "異常系: マーキーアニメーションとタイプライターアニメーションは併用できない",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_marquee_typewriter.gif"
+				c.Writer = nil
+				c.Marquee = true
+				c.Typewriter = true
+				return c
+			}(),
+			args:    []string{"1"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "異常系: 不正なマーキーアニメーションのスクロール幅",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_marquee_step.gif"
+				c.Writer = nil
+				c.Marquee = true
+				c.MarqueeStep = "0px"
+				return c
+			}(),
+			args:    []string{"1"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "正常系: 区切り行でフレームを分けるアニメーション",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_frame_separator.gif"
+				c.Writer = nil
+				c.UseAnimation = true
+				c.FrameSeparator = "\f"
+				return c
+			}(),
+			args:       []string{"1\n\f 100\n2\n2\n\f\n\x1b[31m3\n\f 300"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_frame_separator.gif",
+		},
+		{
+			desc: "正常系: 任意の区切り行でフレームを分けるアニメーション",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_frame_separator_marker.webp"
+				c.Writer = nil
+				c.UseAnimation = true
+				c.FrameSeparator = "---"
+				return c
+			}(),
+			args:       []string{"1\n--- 50\n2"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_frame_separator_marker.webp",
+		},
+		{
+			desc: "正常系: スクロールで切り替えるスライドアニメーション",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_transition_scroll.gif"
+				c.Writer = nil
+				c.UseSlideAnimation = true
+				c.LineCount = 2
+				c.Transition = "scroll"
+				c.TransitionSteps = 3
+				c.Padding = "4"
+				return c
+			}(),
+			args:       []string{"1\n2\n\x1b[31m3\n4"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_transition_scroll.gif",
+		},
+		{
+			desc: "正常系: フェードで切り替えるアニメーション",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_transition_fade.webp"
+				c.Writer = nil
+				c.UseAnimation = true
+				c.FrameSeparator = "\f"
+				c.Transition = "fade"
+				c.TransitionSteps = 2
+				c.Transparent = true
+				return c
+			}(),
+			args:       []string{"AAA\n\f 100\nBBB"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_transition_fade.webp",
+		},
+		{
+			desc: "正常系: ワイプで切り替えるアニメーション",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_transition_wipe.png"
+				c.Writer = nil
+				c.UseAnimation = true
+				c.Transition = "wipe"
+				c.TransitionSteps = 2
+				c.Frame = "mac"
+				return c
+			}(),
+			args:       []string{"AAA\nBBB"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_transition_wipe.png",
+		},
+		{
+			desc: "異常系: タイプライターアニメーションは切り替え途中のフレームを作れない",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_transition_typewriter.gif"
+				c.Writer = nil
+				c.Typewriter = true
+				c.Transition = "fade"
+				return c
+			}(),
+			args:    []string{"1"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "正常系: 逆順に再生するスライドアニメーション",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_playback_reverse.gif"
+				c.Writer = nil
+				c.UseSlideAnimation = true
+				c.LineCount = 2
+				c.Playback = "reverse"
+				return c
+			}(),
+			args:       []string{"1\n2\n\x1b[31m3\n4"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_playback_reverse.gif",
+		},
+		{
+			desc: "正常系: 往復して再生するアニメーション",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_playback_pingpong.apng"
+				c.Writer = nil
+				c.UseAnimation = true
+				c.Playback = "pingpong"
+				c.LastFrameDelay = 100
+				c.Transition = "fade"
+				c.TransitionSteps = 1
+				return c
+			}(),
+			args:       []string{"1\n2\n3"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_playback_pingpong.apng",
+		},
+		{
+			desc: "異常系: 不正な再生方法",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_playback.gif"
+				c.Writer = nil
+				c.UseAnimation = true
+				c.Playback = "sushi"
+				return c
+			}(),
+			args:    []string{"1"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "正常系: 拡張子ではなく形式名で出力形式を指定する",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_format.out"
+				c.Writer = nil
+				c.Format = "webp"
+				return c
+			}(),
+			args:       []string{"hello"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_format.out",
+		},
+		{
+			desc: "正常系: 拡張子より形式名を優先する",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_format_override.png"
+				c.Writer = nil
+				c.Format = "jpeg"
+				return c
+			}(),
+			args:       []string{"hello"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_format_override.png",
+		},
+		{
+			desc: "異常系: 未対応の形式名",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_format_unknown.png"
+				c.Writer = nil
+				c.Format = "bmp"
+				return c
+			}(),
+			args:    []string{"hello"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "異常系: アニメーションに対応していない形式",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_format_animation.jpg"
+				c.Writer = nil
+				c.UseAnimation = true
+				return c
+			}(),
+			args:    []string{"hello"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "正常系: 行数ごとに画像を分割する",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_split.png"
+				c.Writer = nil
+				c.SplitRows = 2
+				c.LineNumbers = true
+				return c
+			}(),
+			args:       []string{"\x1b[31m1\n2\n3"},
+			envs:       config.EnvVars{},
+			wantErr:    false,
+			existsFile: outDir + "/root_test_split_002.png",
+		},
+		{
+			desc: "異常系: 分割する行数が負の数",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_split_negative.png"
+				c.Writer = nil
+				c.SplitRows = -1
+				return c
+			}(),
+			args:    []string{"1"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "異常系: 分割とアニメーションは併用できない",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_split_animation.gif"
+				c.Writer = nil
+				c.SplitRows = 2
+				c.UseAnimation = true
+				return c
+			}(),
+			args:    []string{"1"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
+		{
+			desc: "異常系: 分割と末尾の行の指定は併用できない",
+			c: func() config.Config {
+				c := newDefaultConfig()
+				c.Outpath = outDir + "/root_test_split_tail.png"
+				c.Writer = nil
+				c.SplitRows = 2
+				c.Tail = true
+				return c
+			}(),
+			args:    []string{"1"},
+			envs:    config.EnvVars{},
+			wantErr: true,
+		},
 		{
 			desc: "異常系: 空文字列は不正",
 			c: func() config.Config {
@@ -522,58 +1899,3 @@ func TestRunRootCommand(t *testing.T) {
 		})
 	}
 }
-
-func TestComplementWidthHeight(t *testing.T) {
-	type TestData struct {
-		desc       string
-		x, y, w, h int
-		wantWidth  int
-		wantHeight int
-	}
-	tds := []TestData{
-		{
-			desc:       "正常系: wが0のときはwidthが調整される",
-			x:          200,
-			y:          100,
-			w:          0,
-			h:          200,
-			wantWidth:  400,
-			wantHeight: 200,
-		},
-		{
-			desc:       "正常系: hが0のときはheightが調整される",
-			x:          200,
-			y:          100,
-			w:          100,
-			h:          0,
-			wantWidth:  100,
-			wantHeight: 50,
-		},
-		{
-			desc:       "正常系: hが0のときはheightが調整される",
-			x:          200,
-			y:          100,
-			w:          100,
-			h:          0,
-			wantWidth:  100,
-			wantHeight: 50,
-		},
-		{
-			desc:       "正常系: wとhが0出ないときはwとhが返る",
-			x:          200,
-			y:          100,
-			w:          400,
-			h:          300,
-			wantWidth:  400,
-			wantHeight: 300,
-		},
-	}
-	for _, v := range tds {
-		t.Run(v.desc, func(t *testing.T) {
-			a := assert.New(t)
-			w, h := complementWidthHeight(v.x, v.y, v.w, v.h)
-			a.Equal(v.wantWidth, w)
-			a.Equal(v.wantHeight, h)
-		})
-	}
-}
@@ -0,0 +1,216 @@
+package textimg
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jiro4989/textimg/v3/image"
+	"github.com/jiro4989/textimg/v3/parser"
+	"github.com/jiro4989/textimg/v3/token"
+	"github.com/mattn/go-runewidth"
+)
+
+// chunk は1枚の画像に描画する行のまとまり。
+type chunk struct {
+	tokens   token.Tokens
+	rowLines []int // 各行が入力の何行目 (0始まり) だったか
+}
+
+// RenderChunks は r から1行ずつテキストを読み込んで、rows 行ごとに別の画像に
+// 描画し、format の形式で出力する。
+//
+// 描画し終えた行は破棄するので、入力が大きくても rows 行分のメモリで描画でき
+// る。色と文字の装飾の指定は次の画像に引き継ぎ、行番号は入力の行番号を続けて
+// 表示する。n 枚目 (1始まり) の画像は create(n) で作った出力先に出力して閉じる。
+// 出力した画像の枚数を返す。入力が空の場合は ErrEmptyInput を返す。
+//
+// 全ての画像の幅と行番号の桁数を揃えるために、入力を2回読み込む。1回目は最も
+// 広い行の幅と行数だけを求める。r が読み直せない場合 (パイプなど) は一時ファイ
+// ルに書き出してから読み込む。
+//
+// アニメーションと、入力の全ての行が必要な LinesFrom, LinesTo, Tail は使えない。
+// 1枚あたりの行数は rows で指定するので Rows も使えない。
+func RenderChunks(ctx context.Context, r io.Reader, format string, rows int, opt Options, create func(n int) (io.WriteCloser, error)) (int, error) {
+	if rows < 1 {
+		return 0, fmt.Errorf("rows must be positive. rows = %d", rows)
+	}
+	f, err := image.LookupFormat(format)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case opt.animated():
+		return 0, fmt.Errorf("animation can not be rendered in chunks")
+	case 1 < opt.LinesFrom || 0 < opt.LinesTo:
+		return 0, fmt.Errorf("lines can not be rendered in chunks")
+	case opt.Tail:
+		return 0, fmt.Errorf("tail can not be rendered in chunks")
+	case 0 < opt.Rows:
+		return 0, fmt.Errorf("rows option can not be rendered in chunks. rows argument sets rows of each image")
+	}
+	rd, err := newRenderer(opt, f)
+	if err != nil {
+		return 0, err
+	}
+
+	rs, start, cleanup, err := seekable(r)
+	if err != nil {
+		return 0, err
+	}
+	defer cleanup()
+
+	// 全ての画像の幅と行番号の桁数を入力全体に合わせる
+	var width, lines int
+	err = rd.eachLine(ctx, rs, func(line int, tokens token.Tokens, k int) error {
+		width = max(width, tokens.MaxStringWidth(rd.cond))
+		lines = line + 1
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if lines < 1 {
+		return 0, ErrEmptyInput
+	}
+	if rd.opt.Cols < 1 {
+		rd.opt.Cols = width
+	}
+	rd.param.LastLineNumber = rd.param.StartLineNumber + lines - 1
+	if _, err := rs.Seek(start, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	var (
+		c     chunk
+		carry token.Tokens // 前の画像から引き継ぐ色と装飾の指定
+		n     int
+	)
+	flush := func() error {
+		n++
+		w, err := create(n)
+		if err != nil {
+			return err
+		}
+		doc := &document{tokens: c.tokens, rowLines: c.rowLines, lineCount: 1}
+		if err := rd.encode(ctx, w, doc, f); err != nil {
+			w.Close()
+			return err
+		}
+		carry = c.tokens.StyleState()
+		c = chunk{}
+		return w.Close()
+	}
+
+	err = rd.eachLine(ctx, rs, func(line int, tokens token.Tokens, k int) error {
+		// 折り返した行が画像の境目をまたぐ場合は分けて描画する
+		for from := 0; from < k; {
+			to := min(k, from+rows-len(c.rowLines))
+			part := tokens
+			if 1 < k {
+				part = tokens.Rows(from, to)
+			}
+			if len(c.rowLines) < 1 {
+				c.tokens = append(c.tokens, carry...)
+			} else {
+				c.tokens = append(c.tokens, token.NewText("\n"))
+			}
+			c.tokens = append(c.tokens, part...)
+			for ; from < to; from++ {
+				c.rowLines = append(c.rowLines, line)
+			}
+
+			if len(c.rowLines) == rows {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return n, err
+	}
+
+	if 0 < len(c.rowLines) {
+		if err := flush(); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// eachLine は r から1行ずつテキストを読み込んで、折り返したトークンと折り返し
+// た後の行数を fn に渡す。line は入力の何行目 (0始まり) か。
+func (rd *renderer) eachLine(ctx context.Context, r io.Reader, fn func(line int, tokens token.Tokens, k int) error) error {
+	br := bufio.NewReader(r)
+	for line := 0; ; line++ {
+		s, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if s == "" && err == io.EOF {
+			return nil
+		}
+
+		s = strings.TrimSuffix(strings.TrimSuffix(s, "\n"), "\r")
+		tokens, perr := parser.Parse(normalizeText(s))
+		if perr != nil {
+			return perr
+		}
+		tokens, k := wrapLine(tokens, &rd.opt, rd.cond)
+		if err := fn(line, tokens, k); err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// seekable は r を読み直せる入力と、読み込み始める位置を返す。
+// 読み直せない入力は一時ファイルに書き出し、cleanup で削除する。
+func seekable(r io.Reader) (rs io.ReadSeeker, start int64, cleanup func(), err error) {
+	if rs, ok := r.(io.ReadSeeker); ok {
+		if start, err := rs.Seek(0, io.SeekCurrent); err == nil {
+			return rs, start, func() {}, nil
+		}
+	}
+
+	f, err := os.CreateTemp("", "textimg-*")
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	cleanup = func() {
+		f.Close()
+		os.Remove(f.Name())
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		cleanup()
+		return nil, 0, nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return nil, 0, nil, err
+	}
+	return f, 0, cleanup, nil
+}
+
+// wrapLine は1行分のトークンを折り返したトークンと、折り返した後の行数を返す。
+func wrapLine(tokens token.Tokens, opt *Options, cond *runewidth.Condition) (token.Tokens, int) {
+	if opt.Truncate {
+		return tokens.Truncate(opt.Columns, cond), 1
+	}
+	if 0 < opt.Columns {
+		tokens, rowLines := tokens.Wrap(opt.Columns, opt.WrapMode, cond)
+		return tokens, max(len(rowLines), 1)
+	}
+	return tokens, 1
+}
@@ -0,0 +1,207 @@
+package textimg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	stdimage "image"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// bufferCloser は Close できる bytes.Buffer。
+type bufferCloser struct {
+	bytes.Buffer
+}
+
+func (b *bufferCloser) Close() error {
+	return nil
+}
+
+// renderChunks は RenderChunks で出力した画像の中身を返す。
+func renderChunks(text, format string, rows int, opt Options) ([]string, error) {
+	var bufs []*bufferCloser
+	_, err := RenderChunks(context.Background(), strings.NewReader(text), format, rows, opt, func(n int) (io.WriteCloser, error) {
+		b := &bufferCloser{}
+		bufs = append(bufs, b)
+		return b, nil
+	})
+	var ret []string
+	for _, b := range bufs {
+		ret = append(ret, b.String())
+	}
+	return ret, err
+}
+
+func TestRenderChunks(t *testing.T) {
+	tests := []struct {
+		desc    string
+		text    string
+		rows    int
+		opt     Options
+		want    []string
+		wantErr bool
+	}{
+		{
+			desc: "正常系: 行数ごとに分割する",
+			text: "1\n2\n3\n4\n5\n",
+			rows: 2,
+			want: []string{"1\n2", "3\n4", "5"},
+		},
+		{
+			desc: "正常系: 末尾に改行がない",
+			text: "1\r\n2\r\n3",
+			rows: 2,
+			want: []string{"1\n2", "3"},
+		},
+		{
+			desc: "正常系: 空行も1行として数える",
+			text: "1\n\n\n4\n",
+			rows: 2,
+			want: []string{"1\n", "\n4"},
+		},
+		{
+			desc: "正常系: 折り返した行は画像をまたいで分割する",
+			text: "abcdefg\nh",
+			rows: 2,
+			opt:  Options{Columns: 3},
+			want: []string{"abc\ndef", "g\nh"},
+		},
+		{
+			desc:    "異常系: 入力が空",
+			text:    "",
+			rows:    2,
+			wantErr: true,
+		},
+		{
+			desc:    "異常系: Rows は使えない",
+			text:    "1",
+			rows:    2,
+			opt:     Options{Rows: 2},
+			wantErr: true,
+		},
+		{
+			desc:    "異常系: 行数が0",
+			text:    "1",
+			rows:    0,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// HTML の <pre> 要素の中身で分割した行を比較する
+			opt := tt.opt
+			opt.HTMLFragment = true
+			got, err := renderChunks(tt.text, "html", tt.rows, opt)
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			var texts []string
+			for _, g := range got {
+				g = g[strings.Index(g, ">")+1:]
+				texts = append(texts, g[:strings.Index(g, "</pre>")])
+			}
+			assert.Equal(tt.want, texts)
+		})
+	}
+}
+
+func TestRenderChunks_CarryColor(t *testing.T) {
+	assert := assert.New(t)
+
+	opt := DefaultOptions()
+	opt.HTMLFragment = true
+	got, err := renderChunks("\x1b[31mred\nstill red\x1b[0m\nwhite", "html", 1, opt)
+	assert.NoError(err)
+	assert.Len(got, 3)
+	assert.Contains(got[1], `<span style="color:#ff0000">still red</span>`)
+	assert.NotContains(got[2], "#ff0000")
+
+	// 太字などの装飾も引き継ぐ
+	got, err = renderChunks("\x1b[1mbold\nstill bold", "html", 1, opt)
+	assert.NoError(err)
+	assert.Len(got, 2)
+	assert.Contains(got[1], "font-weight:bold")
+}
+
+func TestRenderChunks_ImageSize(t *testing.T) {
+	numbered := DefaultOptions()
+	numbered.LineNumbers = true
+
+	tests := []struct {
+		desc string
+		text string
+		rows int
+		opt  Options
+		// 各画像と同じ大きさになる、Render に渡すテキスト
+		want []string
+	}{
+		{
+			desc: "正常系: 全ての画像を最も広い行の幅にする",
+			text: "abcdef\nab\nabc",
+			rows: 2,
+			opt:  DefaultOptions(),
+			want: []string{"abcdef\nab", "abcdef"},
+		},
+		{
+			desc: "正常系: 行番号の桁数を最後の行に揃える",
+			text: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10",
+			rows: 9,
+			opt:  numbered,
+			want: []string{"1\n2\n3\n4\n5\n6\n7\n8\n9\n10", "1\n2\n3\n4\n5\n6\n7\n8\n9\n10"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			got, err := renderChunks(tt.text, "png", tt.rows, tt.opt)
+			assert.NoError(err)
+			assert.Len(got, len(tt.want))
+			for j, text := range tt.want {
+				img, err := Render(context.Background(), strings.NewReader(text), tt.opt)
+				assert.NoError(err)
+				cfg, _, err := stdimage.DecodeConfig(strings.NewReader(got[j]))
+				assert.NoError(err)
+				assert.Equal(img.Bounds().Dx(), cfg.Width)
+			}
+		})
+	}
+}
+
+func TestRenderChunks_Pipe(t *testing.T) {
+	assert := assert.New(t)
+
+	// 読み直せない入力も一時ファイルに書き出して分割できる
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write([]byte("1\n2\n3"))
+		pw.Close()
+	}()
+	var n int
+	got, err := RenderChunks(context.Background(), pr, "png", 2, DefaultOptions(), func(int) (io.WriteCloser, error) {
+		n++
+		return &bufferCloser{}, nil
+	})
+	assert.NoError(err)
+	assert.Equal(2, got)
+	assert.Equal(2, n)
+}
+
+func TestRenderChunks_CreateError(t *testing.T) {
+	assert := assert.New(t)
+
+	want := errors.New("create error")
+	n, err := RenderChunks(context.Background(), strings.NewReader("1\n2"), "png", 1, DefaultOptions(), func(n int) (io.WriteCloser, error) {
+		return nil, want
+	})
+	assert.ErrorIs(err, want)
+	assert.Equal(1, n)
+}
@@ -0,0 +1,148 @@
+package textimg
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// sgrPattern は文字色や背景色を指定するエスケープシーケンスにマッチする。
+var sgrPattern = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// selectLines は from 行目から to 行目までのテキストを返す。
+// to が 0 の場合は最後までを返す。
+// 範囲より前の行で指定された色を引き継ぐため、範囲より前の行のエスケープシー
+// ケンスは先頭の行に付与する。
+func selectLines(texts []string, from, to int) []string {
+	if from <= 1 && to == 0 {
+		return texts
+	}
+	if len(texts) < from {
+		return nil
+	}
+	if to == 0 || len(texts) < to {
+		to = len(texts)
+	}
+
+	var prefix string
+	for _, v := range texts[:from-1] {
+		prefix += strings.Join(sgrPattern.FindAllString(v, -1), "")
+	}
+
+	ret := make([]string, to-from+1)
+	copy(ret, texts[from-1:to])
+	ret[0] = prefix + ret[0]
+	return ret
+}
+
+// splitFrames は区切り行でテキストをアニメーションのフレームに分割する。
+//
+// 区切り行は sep で始まる行で、後ろに空白区切りでディレイ時間を書くと直前の
+// フレームのディレイ時間になる。例: "\f 150"
+// 全てのフレームを最も行数の多いフレームと同じ行数にして連結したテキストと、
+// 1フレームの行数、フレームごとのディレイ時間を返す。ディレイ時間を省略した
+// フレームは 0 にする。
+// 先頭の区切り行や連続した区切り行による行のないフレームは、ディレイ時間ごと
+// 取り除く。
+// 区切り行が存在しない場合は ok が false になる。
+func splitFrames(texts []string, sep string) (ret []string, lineCount int, delays []int, ok bool) {
+	if sep == "" {
+		return nil, 0, nil, false
+	}
+
+	var (
+		frames [][]string
+		frame  []string
+	)
+	for _, line := range texts {
+		delay, isSep := parseFrameSeparator(line, sep)
+		if !isSep {
+			frame = append(frame, line)
+			continue
+		}
+		ok = true
+		if 0 < len(frame) {
+			frames = append(frames, frame)
+			delays = append(delays, delay)
+		}
+		frame = nil
+	}
+	if !ok {
+		return nil, 0, nil, false
+	}
+	// 最後の区切り行の後ろに何もない場合はフレームにしない
+	if 0 < len(frame) {
+		frames = append(frames, frame)
+		delays = append(delays, 0)
+	}
+
+	lineCount = 1
+	for _, f := range frames {
+		lineCount = max(lineCount, len(f))
+	}
+	for _, f := range frames {
+		ret = append(ret, f...)
+		for j := len(f); j < lineCount; j++ {
+			ret = append(ret, "")
+		}
+	}
+	return ret, lineCount, delays, true
+}
+
+// parseFrameSeparator は line が区切り行かを判定して、区切り行に書かれたディレイ
+// 時間を返す。
+// ディレイ時間が数値でない行は区切り行とみなさない。
+func parseFrameSeparator(line, sep string) (delay int, ok bool) {
+	rest, found := strings.CutPrefix(line, sep)
+	if !found {
+		return 0, false
+	}
+	rest = strings.TrimSpace(rest)
+	if rest == "" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(rest)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// toSlideStrings は文字列をスライドアニメーション用の文字列に変換する。
+func toSlideStrings(src []string, lineCount, slideWidth int, slideForever bool) (ret []string) {
+	if 1 < slideWidth {
+		var loopCount int
+		for i := 0; i < len(src); i += slideWidth {
+			loopCount++
+		}
+		for i := 0; i < (loopCount*slideWidth+1)-len(src); i++ {
+			if !slideForever {
+				src = append(src, "")
+			}
+		}
+	}
+
+	for i := 0; i < len(src); i += slideWidth {
+		n := i + lineCount
+		if len(src) < n {
+			if slideForever {
+				for j := i; j < n; j++ {
+					m := j
+					if len(src) <= m {
+						m -= len(src)
+					}
+					line := src[m]
+					ret = append(ret, line)
+				}
+				continue
+			}
+			return
+		}
+		// lineCountの数ずつ行を取得して戻り値に追加
+		for j := i; j < n; j++ {
+			line := src[j]
+			ret = append(ret, line)
+		}
+	}
+	return
+}
@@ -0,0 +1,351 @@
+package textimg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToSlideStrings(t *testing.T) {
+	type TestData struct {
+		desc                  string
+		src, expect           []string
+		lineCount, slideWidth int
+		slideForever          bool
+	}
+	tds := []TestData{
+		{
+			desc: "2行描画、スライド幅1、無限なし",
+			src:  []string{"1", "2", "3", "4", "5"},
+			expect: []string{
+				"1", "2",
+				"2", "3",
+				"3", "4",
+				"4", "5",
+			},
+			lineCount:    2,
+			slideWidth:   1,
+			slideForever: false,
+		},
+		{
+			desc: "2行描画、スライド幅2、無限なし",
+			src:  []string{"1", "2", "3", "4", "5"},
+			expect: []string{
+				"1", "2",
+				"3", "4",
+				"5", "",
+			},
+			lineCount:    2,
+			slideWidth:   2,
+			slideForever: false,
+		},
+		{
+			desc: "3行描画、スライド幅1、無限なし",
+			src:  []string{"1", "2", "3", "4", "5"},
+			expect: []string{
+				"1", "2", "3",
+				"2", "3", "4",
+				"3", "4", "5",
+			},
+			lineCount:    3,
+			slideWidth:   1,
+			slideForever: false,
+		},
+		{
+			desc: "3行描画、スライド幅2、無限なし、不足あり",
+			src:  []string{"1", "2", "3", "4", "5", "6"},
+			expect: []string{
+				"1", "2", "3",
+				"3", "4", "5",
+				"5", "6", "",
+			},
+			lineCount:    3,
+			slideWidth:   2,
+			slideForever: false,
+		},
+		{
+			desc: "3行描画、スライド幅2、無限なし、不足なし",
+			src:  []string{"1", "2", "3", "4", "5", "6", "7"},
+			expect: []string{
+				"1", "2", "3",
+				"3", "4", "5",
+				"5", "6", "7",
+			},
+			lineCount:    3,
+			slideWidth:   2,
+			slideForever: false,
+		},
+		{
+			desc: "3行描画、スライド幅3、無限なし、不足なし",
+			src:  []string{"1", "2", "3", "4", "5", "6"},
+			expect: []string{
+				"1", "2", "3",
+				"4", "5", "6",
+			},
+			lineCount:    3,
+			slideWidth:   3,
+			slideForever: false,
+		},
+		{
+			desc: "3行描画、スライド幅3、無限なし、不足あり",
+			src:  []string{"1", "2", "3", "4", "5", "6", "7"},
+			expect: []string{
+				"1", "2", "3",
+				"4", "5", "6",
+				"7", "", "",
+			},
+			lineCount:    3,
+			slideWidth:   3,
+			slideForever: false,
+		},
+		{
+			desc: "3行描画、スライド幅3、無限なし、不足あり",
+			src:  []string{"1", "2", "3", "4", "5", "6", "7", "8"},
+			expect: []string{
+				"1", "2", "3",
+				"4", "5", "6",
+				"7", "8", "",
+			},
+			lineCount:    3,
+			slideWidth:   3,
+			slideForever: false,
+		},
+		{
+			desc: "2行描画、スライド幅2、無限あり",
+			src:  []string{"1", "2", "3", "4", "5"},
+			expect: []string{
+				"1", "2",
+				"3", "4",
+				"5", "1",
+			},
+			lineCount:    2,
+			slideWidth:   2,
+			slideForever: true,
+		},
+		{
+			desc: "2行描画、スライド幅2、無限あり",
+			src:  []string{"1", "2", "3", "4", "5", "6"},
+			expect: []string{
+				"1", "2",
+				"3", "4",
+				"5", "6",
+			},
+			lineCount:    2,
+			slideWidth:   2,
+			slideForever: true,
+		},
+		{
+			desc: "3行描画、スライド幅1、無限あり",
+			src:  []string{"1", "2", "3", "4", "5"},
+			expect: []string{
+				"1", "2", "3",
+				"2", "3", "4",
+				"3", "4", "5",
+				"4", "5", "1",
+				"5", "1", "2",
+			},
+			lineCount:    3,
+			slideWidth:   1,
+			slideForever: true,
+		},
+		{
+			desc: "3行描画、スライド幅1、無限あり",
+			src:  []string{"1", "2", "3", "4", "5", "6"},
+			expect: []string{
+				"1", "2", "3",
+				"2", "3", "4",
+				"3", "4", "5",
+				"4", "5", "6",
+				"5", "6", "1",
+				"6", "1", "2",
+			},
+			lineCount:    3,
+			slideWidth:   1,
+			slideForever: true,
+		},
+		{
+			desc: "3行描画、スライド幅2、無限あり",
+			src:  []string{"1", "2", "3", "4", "5"},
+			expect: []string{
+				"1", "2", "3",
+				"3", "4", "5",
+				"5", "1", "2",
+			},
+			lineCount:    3,
+			slideWidth:   2,
+			slideForever: true,
+		},
+		{
+			desc: "3行描画、スライド幅2、無限あり",
+			src:  []string{"1", "2", "3", "4", "5", "6"},
+			expect: []string{
+				"1", "2", "3",
+				"3", "4", "5",
+				"5", "6", "1",
+			},
+			lineCount:    3,
+			slideWidth:   2,
+			slideForever: true,
+		},
+		{
+			desc: "3行描画、スライド幅3、無限あり",
+			src:  []string{"1", "2", "3", "4", "5", "6"},
+			expect: []string{
+				"1", "2", "3",
+				"4", "5", "6",
+			},
+			lineCount:    3,
+			slideWidth:   3,
+			slideForever: true,
+		},
+		{
+			desc: "3行描画、スライド幅3、無限あり",
+			src:  []string{"1", "2", "3", "4", "5", "6", "7"},
+			expect: []string{
+				"1", "2", "3",
+				"4", "5", "6",
+				"7", "1", "2",
+			},
+			lineCount:    3,
+			slideWidth:   3,
+			slideForever: true,
+		},
+	}
+	for _, v := range tds {
+		t.Run(v.desc, func(t *testing.T) {
+			got := toSlideStrings(v.src, v.lineCount, v.slideWidth, v.slideForever)
+			assert.Equal(t, v.expect, got, v.desc)
+		})
+	}
+}
+
+func TestSplitFrames(t *testing.T) {
+	tests := []struct {
+		desc          string
+		texts         []string
+		sep           string
+		want          []string
+		wantLineCount int
+		wantDelays    []int
+		wantOK        bool
+	}{
+		{
+			desc:          "正常系: 区切り行でフレームを分けて行数を揃える",
+			texts:         []string{"1", "\f 100", "2", "2", "\f", "3"},
+			sep:           "\f",
+			want:          []string{"1", "", "2", "2", "3", ""},
+			wantLineCount: 2,
+			wantDelays:    []int{100, 0, 0},
+			wantOK:        true,
+		},
+		{
+			desc:          "正常系: 最後の区切り行の後ろはフレームにしない",
+			texts:         []string{"1", "--- 50", "2", "---  70 "},
+			sep:           "---",
+			want:          []string{"1", "2"},
+			wantLineCount: 1,
+			wantDelays:    []int{50, 70},
+			wantOK:        true,
+		},
+		{
+			desc:          "正常系: 数値でない区切り行は普通の行",
+			texts:         []string{"--- a", "---", "2"},
+			sep:           "---",
+			want:          []string{"--- a", "2"},
+			wantLineCount: 1,
+			wantDelays:    []int{0, 0},
+			wantOK:        true,
+		},
+		{
+			desc:          "正常系: 先頭の区切り行と連続した区切り行は空のフレームを作らない",
+			texts:         []string{"\f 30", "1", "\f 100", "\f 200", "2"},
+			sep:           "\f",
+			want:          []string{"1", "2"},
+			wantLineCount: 1,
+			wantDelays:    []int{100, 0},
+			wantOK:        true,
+		},
+		{
+			desc:          "正常系: 区切り行だけの場合はフレームがない",
+			texts:         []string{"\f", "\f 100"},
+			sep:           "\f",
+			wantLineCount: 1,
+			wantOK:        true,
+		},
+		{
+			desc:   "正常系: 区切り行がない",
+			texts:  []string{"1", "2"},
+			sep:    "\f",
+			wantOK: false,
+		},
+		{
+			desc:   "正常系: 区切り行が空文字列の場合は区切らない",
+			texts:  []string{"1", "", "2"},
+			sep:    "",
+			wantOK: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			got, lineCount, delays, ok := splitFrames(tt.texts, tt.sep)
+			assert.Equal(tt.wantOK, ok)
+			assert.Equal(tt.want, got)
+			assert.Equal(tt.wantLineCount, lineCount)
+			assert.Equal(tt.wantDelays, delays)
+		})
+	}
+}
+
+func TestSelectLines(t *testing.T) {
+	tests := []struct {
+		desc  string
+		texts []string
+		from  int
+		to    int
+		want  []string
+	}{
+		{
+			desc:  "正常系: 範囲指定なし",
+			texts: []string{"1", "2", "3"},
+			from:  1,
+			to:    0,
+			want:  []string{"1", "2", "3"},
+		},
+		{
+			desc:  "正常系: 範囲を取得する",
+			texts: []string{"1", "2", "3", "4"},
+			from:  2,
+			to:    3,
+			want:  []string{"2", "3"},
+		},
+		{
+			desc:  "正常系: 範囲より前のエスケープシーケンスを引き継ぐ",
+			texts: []string{"\x1b[31m1", "\x1b[42m2\x1b[0m\x1b[32m", "3", "4"},
+			from:  3,
+			to:    0,
+			want:  []string{"\x1b[31m\x1b[42m\x1b[0m\x1b[32m3", "4"},
+		},
+		{
+			desc:  "正常系: 終わりが行数を超える場合は最後まで",
+			texts: []string{"1", "2"},
+			from:  2,
+			to:    10,
+			want:  []string{"2"},
+		},
+		{
+			desc:  "正常系: 始まりが行数を超える場合は空",
+			texts: []string{"1", "2"},
+			from:  3,
+			to:    0,
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got := selectLines(tt.texts, tt.from, tt.to)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,363 @@
+package textimg
+
+import (
+	"fmt"
+	stdimage "image"
+	"image/color"
+	"io/fs"
+
+	"github.com/jiro4989/textimg/v3/fontfile"
+	"github.com/jiro4989/textimg/v3/image"
+	"github.com/jiro4989/textimg/v3/quantize"
+	"github.com/jiro4989/textimg/v3/token"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gomono"
+)
+
+// DefaultFontSize は FontSize を指定しない場合のフォントサイズ。
+const DefaultFontSize = 20
+
+// Options は描画の設定。
+//
+// DefaultOptions の値を元に、必要な項目だけを変更して使う。
+// 0 や空文字の項目の多くは DefaultOptions と同じ値として扱うけれど、色の
+// ゼロ値は透明色になる。
+type Options struct {
+	ForegroundColor     color.RGBA // 文字色
+	BackgroundColor     color.RGBA // 背景色
+	Transparent         bool       // 背景を透過する
+	KeepANSIBackground  bool       // 透過時もエスケープシーケンスで指定された背景色は描画する
+	BackgroundGradient  *image.Gradient
+	BackgroundImage     stdimage.Image
+	BackgroundImageMode string
+
+	// フォントファイル (.ttf, .otf, .ttc, .otc) の中身。nil の場合はビルトイン
+	// のフォント (Go Mono) を使う。SVG と PDF にもこのフォントで出力する
+	FontData       []byte
+	FontIndex      int // フォントコレクションの場合に使うフォントの番号
+	FontSize       int
+	EmojiFontData  []byte // 絵文字用のフォントファイルの中身
+	EmojiFontIndex int
+	UseEmojiFont   bool  // 絵文字を EmojiFontData のフォントで描画する
+	EmojiFS        fs.FS // 絵文字の画像ファイル (emoji_uXXXX.png) を置いたファイルシステム
+	EastAsianWidth bool  // East Asian Width が曖昧な文字を幅2として扱う
+	EmbedFont      bool  // SVG にフォントを埋め込む
+
+	Padding       image.Padding
+	LineSpacing   int // 行間 (px)
+	LetterSpacing int // 文字間 (px)
+	// 描画倍率。フォントサイズ、余白、行間、文字間、ウィンドウ枠の外側の余白、
+	// マーキーアニメーションのスクロール幅を倍率倍にして描画する
+	Scale int
+
+	FrameStyle       string     // ウィンドウ枠のスタイル
+	Title            string     // ウィンドウ枠のタイトル
+	FrameMargin      int        // ウィンドウ枠の外側の余白 (px)
+	FrameMarginColor color.RGBA // ウィンドウ枠の外側の余白の色
+
+	LineNumbers     bool              // 行番号を表示する
+	StartLineNumber int               // 入力の1行目の行番号
+	HighlightLines  []image.LineRange // 強調表示する行の範囲
+	HighlightColor  color.RGBA        // 強調表示する行に重ねる色
+
+	LinesFrom int    // 描画する入力の最初の行 (1始まり)。0 の場合は1行目から
+	LinesTo   int    // 描画する入力の最後の行。0 の場合は最後まで
+	Columns   int    // 折り返す列数。0 の場合は折り返さない
+	WrapMode  string // 折り返し方
+	Truncate  bool   // Columns を超える文字を折り返さずに切り捨てる
+	Cols      int    // 画像の列数。0 の場合は最も長い行に合わせる
+	Rows      int    // 画像の行数。0 の場合は全ての行を描画する
+	Tail      bool   // Rows を超える行がある場合に末尾の行を残す
+
+	UseAnimation       bool   // アニメーションにする
+	AnimationLineCount int    // 1フレームの行数
+	FrameSeparator     string // 入力をフレームに区切る行の先頭の文字列。空の場合は区切らない
+	Delay              int    // フレームの表示時間 (1/100 秒)
+	Loop               int    // ループ回数。0 の場合は無限にループする
+	LastFrameDelay     int    // 最後のフレームの表示時間。0 の場合は Delay と同じ
+	Dither             string // GIF の減色時のディザリングの方法
+	GlobalPalette      bool   // アニメーションGIFの全てのフレームで同じパレットを使う
+	Slide              bool   // スライドアニメーションにする
+	SlideWidth         int    // スライドアニメーションで1フレームごとに進む行数
+	SlideForever       bool   // スライドアニメーションを途切れずに繰り返す
+	Typewriter         bool   // 文字が1つずつ現れるアニメーションにする
+	TypeUnit           string // タイプライターアニメーションで一度に表示する単位
+	TypeStep           int    // タイプライターアニメーションの1フレームで表示する単位の数
+	NewlinePause       int    // タイプライターアニメーションの行末で止まる時間
+	Cursor             string // タイプライターアニメーションのカーソルのスタイル
+	MarqueeWidth       int    // マーキーアニメーションの表示領域の列数。0 の場合はマーキーにしない
+	MarqueeStep        int    // マーキーアニメーションの1フレームでスクロールする列数
+	MarqueeStepPixels  int    // マーキーアニメーションの1フレームでスクロールする幅 (px)。MarqueeStep より優先する
+	MarqueeForever     bool   // マーキーアニメーションを途切れずに繰り返す
+	Transition         string // フレームの切り替え方
+	TransitionSteps    int    // フレームの間に挿入する切り替え途中のフレームの数
+	Playback           string // フレームの再生方法
+
+	ResizeWidth  int
+	ResizeHeight int
+	Fit          string // リサイズ時の縦横比の扱い
+	Anchor       string // リサイズ時に切り抜く、または余白を空ける基準位置
+	Resample     string // リサイズ時の補間方法
+	MaxBytes     int    // 出力するファイルの最大サイズ (byte)。0 の場合は制限なし
+
+	PageRows     int  // PDF の1ページの行数。0 の場合は1ページにまとめる
+	PageMargin   int  // PDF のページの余白 (pt)
+	HTMLFragment bool // HTML に <pre> 要素だけを出力する
+	HTMLClasses  bool // HTML の16色の色指定をクラスにする
+}
+
+// DefaultOptions はコマンドラインのデフォルトと同じ描画の設定を返す。
+// 黒い背景に白い文字をビルトインのフォントで描画する。
+func DefaultOptions() Options {
+	return Options{
+		ForegroundColor:     color.RGBA{R: 255, G: 255, B: 255, A: 255},
+		BackgroundColor:     color.RGBA{A: 255},
+		BackgroundImageMode: image.BackgroundImageModeScale,
+		FontSize:            DefaultFontSize,
+		Scale:               1,
+		FrameStyle:          image.FrameStyleNone,
+		FrameMargin:         20,
+		StartLineNumber:     1,
+		HighlightColor:      color.RGBA{R: 255, G: 255, A: 64},
+		WrapMode:            token.WrapModeChar,
+		AnimationLineCount:  1,
+		Delay:               20,
+		Dither:              quantize.DitherFloydSteinberg,
+		SlideWidth:          1,
+		TypeUnit:            image.TypeUnitChar,
+		TypeStep:            1,
+		NewlinePause:        50,
+		Cursor:              image.CursorBlock,
+		MarqueeStep:         1,
+		Transition:          image.TransitionNone,
+		TransitionSteps:     4,
+		Playback:            image.PlaybackForward,
+		Fit:                 image.FitFill,
+		Anchor:              image.AnchorCenter,
+		Resample:            image.ResampleCatmullRom,
+		PageMargin:          36,
+	}
+}
+
+// Validate は設定で描画できるかを検証する。
+// Render などの描画の関数も、描画する前に同じ検証をする。
+func (o Options) Validate() error {
+	_, err := newRenderer(o, image.Format{})
+	return err
+}
+
+// animated はアニメーションにするかを返す。
+func (o *Options) animated() bool {
+	return o.UseAnimation || o.Slide || o.Typewriter || 0 < o.MarqueeWidth
+}
+
+// setDefaults は 0 や空文字の項目に DefaultOptions の値を設定する。
+func (o *Options) setDefaults() {
+	d := DefaultOptions()
+	setDefaultInt := func(v *int, def int) {
+		if *v == 0 {
+			*v = def
+		}
+	}
+	setDefaultInt(&o.FontSize, d.FontSize)
+	setDefaultInt(&o.Scale, d.Scale)
+	setDefaultInt(&o.AnimationLineCount, d.AnimationLineCount)
+	setDefaultInt(&o.SlideWidth, d.SlideWidth)
+	setDefaultInt(&o.TypeStep, d.TypeStep)
+	if o.MarqueeStepPixels == 0 {
+		setDefaultInt(&o.MarqueeStep, d.MarqueeStep)
+	}
+
+	setDefaultString := func(v *string, def string) {
+		if *v == "" {
+			*v = def
+		}
+	}
+	setDefaultString(&o.BackgroundImageMode, d.BackgroundImageMode)
+	setDefaultString(&o.FrameStyle, d.FrameStyle)
+	setDefaultString(&o.WrapMode, d.WrapMode)
+	setDefaultString(&o.Dither, d.Dither)
+	setDefaultString(&o.TypeUnit, d.TypeUnit)
+	setDefaultString(&o.Cursor, d.Cursor)
+	setDefaultString(&o.Transition, d.Transition)
+	setDefaultString(&o.Playback, d.Playback)
+	setDefaultString(&o.Fit, d.Fit)
+	setDefaultString(&o.Anchor, d.Anchor)
+	setDefaultString(&o.Resample, d.Resample)
+}
+
+// validate は setDefaults した後の値を検証する。
+// フォントから決まるセルの大きさは newRenderer で検証する。
+func (o *Options) validate() error {
+	for _, v := range []struct {
+		name string
+		n    int
+	}{
+		{"font size", o.FontSize},
+		{"scale", o.Scale},
+		{"padding top", o.Padding.Top},
+		{"padding right", o.Padding.Right},
+		{"padding bottom", o.Padding.Bottom},
+		{"padding left", o.Padding.Left},
+		{"frame margin", o.FrameMargin},
+		{"start line number", o.StartLineNumber},
+		{"lines from", o.LinesFrom},
+		{"lines to", o.LinesTo},
+		{"columns", o.Columns},
+		{"cols", o.Cols},
+		{"rows", o.Rows},
+		{"animation line count", o.AnimationLineCount},
+		{"delay", o.Delay},
+		{"loop", o.Loop},
+		{"last frame delay", o.LastFrameDelay},
+		{"slide width", o.SlideWidth},
+		{"type step", o.TypeStep},
+		{"newline pause", o.NewlinePause},
+		{"marquee width", o.MarqueeWidth},
+		{"marquee step", o.MarqueeStep},
+		{"marquee step pixels", o.MarqueeStepPixels},
+		{"transition steps", o.TransitionSteps},
+		{"resize width", o.ResizeWidth},
+		{"resize height", o.ResizeHeight},
+		{"max bytes", o.MaxBytes},
+		{"page rows", o.PageRows},
+		{"page margin", o.PageMargin},
+	} {
+		if v.n < 0 {
+			return fmt.Errorf("%s must not be negative. %s = %d", v.name, v.name, v.n)
+		}
+	}
+	if 0 < o.LinesTo && o.LinesTo < o.LinesFrom {
+		return fmt.Errorf("lines to must not be less than lines from. lines = %d-%d", o.LinesFrom, o.LinesTo)
+	}
+
+	for _, validate := range []func() error{
+		func() error { return image.ValidateBackgroundImageMode(o.BackgroundImageMode) },
+		func() error { return image.ValidateFrameStyle(o.FrameStyle) },
+		func() error { return token.ValidateWrapMode(o.WrapMode) },
+		func() error { return quantize.ValidateDither(o.Dither) },
+		func() error { return image.ValidateTypeUnit(o.TypeUnit) },
+		func() error { return image.ValidateCursorStyle(o.Cursor) },
+		func() error { return image.ValidateTransition(o.Transition) },
+		func() error { return image.ValidatePlayback(o.Playback) },
+		func() error { return image.ValidateFitMode(o.Fit) },
+		func() error { return image.ValidateAnchor(o.Anchor) },
+		func() error { return image.ValidateResample(o.Resample) },
+	} {
+		if err := validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scale は描画倍率倍にする項目を倍率倍にする。
+// 描画後に拡大するのではなく大きく描画するので、文字がぼやけない。
+func (o *Options) scale() {
+	if o.Scale <= 1 {
+		return
+	}
+	o.FontSize *= o.Scale
+	o.Padding.Top *= o.Scale
+	o.Padding.Right *= o.Scale
+	o.Padding.Bottom *= o.Scale
+	o.Padding.Left *= o.Scale
+	o.LineSpacing *= o.Scale
+	o.LetterSpacing *= o.Scale
+	o.FrameMargin *= o.Scale
+	o.MarqueeStepPixels *= o.Scale
+}
+
+// faces は FontSize の文字用と絵文字用の font.Face を返す。
+// 絵文字用のフォントを指定していない場合は nil を返す。
+func (o *Options) faces() (face, emojiFace font.Face, err error) {
+	data, index := o.FontData, o.FontIndex
+	if data == nil {
+		data, index = gomono.TTF, 0
+	}
+	face, err = fontfile.NewFace(data, index, float64(o.FontSize))
+	if err != nil {
+		return nil, nil, err
+	}
+	if o.EmojiFontData != nil {
+		emojiFace, err = fontfile.NewFace(o.EmojiFontData, o.EmojiFontIndex, float64(o.FontSize))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return face, emojiFace, nil
+}
+
+// imageParam は描画するテキストから決まる値以外の画像の設定を返す。
+// 描画倍率は scale で適用しておくこと。
+func (o *Options) imageParam(face, emojiFace font.Face) image.ImageParam {
+	p := image.ImageParam{
+		ForegroundColor:     o.ForegroundColor,
+		BackgroundColor:     o.BackgroundColor,
+		FontSize:            o.FontSize,
+		FontFace:            face,
+		EmojiFontFace:       emojiFace,
+		EmojiFS:             o.EmojiFS,
+		EastAsianWidth:      o.EastAsianWidth,
+		UseEmoji:            o.UseEmojiFont,
+		UseAnimation:        o.animated(),
+		AnimationLineCount:  o.AnimationLineCount,
+		Typewriter:          o.Typewriter,
+		TypeUnit:            o.TypeUnit,
+		TypeStep:            o.TypeStep,
+		NewlinePause:        o.NewlinePause,
+		Cursor:              o.Cursor,
+		MarqueeWidth:        o.MarqueeWidth,
+		MarqueeStep:         o.MarqueeStep,
+		MarqueeStepPixels:   o.MarqueeStepPixels,
+		MarqueeForever:      o.MarqueeForever,
+		Transition:          o.Transition,
+		TransitionSteps:     o.TransitionSteps,
+		Playback:            o.Playback,
+		ResizeWidth:         o.ResizeWidth,
+		ResizeHeight:        o.ResizeHeight,
+		Fit:                 o.Fit,
+		Anchor:              o.Anchor,
+		Resample:            o.Resample,
+		Scale:               o.Scale,
+		MaxBytes:            o.MaxBytes,
+		EmbedFont:           o.EmbedFont,
+		PageRows:            o.PageRows,
+		PageMargin:          o.PageMargin,
+		Delay:               o.Delay,
+		Loop:                o.Loop,
+		LastFrameDelay:      o.LastFrameDelay,
+		Dither:              o.Dither,
+		GlobalPalette:       o.GlobalPalette,
+		Padding:             o.Padding,
+		LineSpacing:         o.LineSpacing,
+		LetterSpacing:       o.LetterSpacing,
+		FrameStyle:          o.FrameStyle,
+		Title:               o.Title,
+		FrameMargin:         o.FrameMargin,
+		FrameMarginColor:    o.FrameMarginColor,
+		Transparent:         o.Transparent,
+		KeepANSIBackground:  o.KeepANSIBackground,
+		BackgroundGradient:  o.BackgroundGradient,
+		BackgroundImage:     o.BackgroundImage,
+		BackgroundImageMode: o.BackgroundImageMode,
+		LineNumbers:         o.LineNumbers,
+		StartLineNumber:     o.StartLineNumber,
+		HighlightLines:      o.HighlightLines,
+		HighlightColor:      o.HighlightColor,
+		HTMLFragment:        o.HTMLFragment,
+		HTMLClasses:         o.HTMLClasses,
+	}
+	if o.Transparent {
+		p.BackgroundColor = color.RGBA{}
+	}
+	// スクロールで切り替える場合は、スライドする行数だけスクロールする
+	if o.Slide {
+		p.SlideWidth = o.SlideWidth
+	}
+	// 行の範囲を指定した場合は、入力の行番号を表示する
+	if 1 < o.LinesFrom {
+		p.StartLineNumber += o.LinesFrom - 1
+	}
+	return p
+}
@@ -0,0 +1,95 @@
+package textimg
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jiro4989/textimg/v3/image"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptions_scale(t *testing.T) {
+	base := Options{FontSize: 20, Padding: image.Padding{Top: 1, Right: 2}, LineSpacing: 3, LetterSpacing: 4, FrameMargin: 5, MarqueeStepPixels: 6}
+	tests := []struct {
+		desc  string
+		scale int
+		want  Options
+	}{
+		{
+			desc:  "正常系: 未指定の場合は等倍",
+			scale: 0,
+			want:  Options{Scale: 1, FontSize: 20, Padding: image.Padding{Top: 1, Right: 2}, LineSpacing: 3, LetterSpacing: 4, FrameMargin: 5, MarqueeStepPixels: 6},
+		},
+		{
+			desc:  "正常系: フォントサイズと余白と間隔を倍にする",
+			scale: 2,
+			want:  Options{Scale: 2, FontSize: 40, Padding: image.Padding{Top: 2, Right: 4}, LineSpacing: 6, LetterSpacing: 8, FrameMargin: 10, MarqueeStepPixels: 12},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			o := base
+			o.Scale = tt.scale
+			if o.Scale == 0 {
+				o.Scale = 1
+			}
+			o.scale()
+			assert.Equal(tt.want, o)
+		})
+	}
+}
+
+func TestOptions_Validate(t *testing.T) {
+	tests := []struct {
+		desc    string
+		opt     func(o *Options)
+		wantErr bool
+	}{
+		{desc: "正常系: デフォルト", opt: func(o *Options) {}},
+		{desc: "正常系: 0 の行数はデフォルトの1行にする", opt: func(o *Options) { o.UseAnimation, o.AnimationLineCount = true, 0 }},
+		{desc: "異常系: 負の行数", opt: func(o *Options) { o.UseAnimation, o.AnimationLineCount = true, -1 }, wantErr: true},
+		{desc: "異常系: セルの高さが1未満になる行間", opt: func(o *Options) { o.LineSpacing = -100 }, wantErr: true},
+		{desc: "異常系: セルの幅が1未満になる文字間", opt: func(o *Options) { o.LetterSpacing = -100 }, wantErr: true},
+		{desc: "異常系: 負の余白", opt: func(o *Options) { o.Padding.Left = -1 }, wantErr: true},
+		{desc: "異常系: 負の倍率", opt: func(o *Options) { o.Scale = -1 }, wantErr: true},
+		{desc: "異常系: 負のリサイズ幅", opt: func(o *Options) { o.ResizeWidth = -1 }, wantErr: true},
+		{desc: "異常系: 負の行番号", opt: func(o *Options) { o.StartLineNumber = -100 }, wantErr: true},
+		{desc: "異常系: 不正な fit", opt: func(o *Options) { o.Fit = "sushi" }, wantErr: true},
+		{desc: "異常系: フォントではないデータ", opt: func(o *Options) { o.FontData = []byte("sushi") }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			o := DefaultOptions()
+			tt.opt(&o)
+			err := o.Validate()
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+		})
+	}
+}
+
+func TestRender_Scale(t *testing.T) {
+	assert := assert.New(t)
+
+	render := func(scale int) (int, int) {
+		o := DefaultOptions()
+		o.Scale = scale
+		o.Padding = image.Padding{Top: 10, Right: 10, Bottom: 10, Left: 10}
+		img, err := Render(context.Background(), strings.NewReader("abc"), o)
+		assert.NoError(err)
+		return img.Bounds().Dx(), img.Bounds().Dy()
+	}
+	w1, h1 := render(1)
+	w2, h2 := render(2)
+	// フォントの大きさも倍になるので、画像の大きさもほぼ倍になる
+	assert.InDelta(2*w1, w2, 2)
+	assert.InDelta(2*h1, h2, 2)
+}
@@ -0,0 +1,57 @@
+package textimg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jiro4989/textimg/v3/image"
+)
+
+// Preset は投稿先のサービスに合わせた出力画像の設定。
+// Options の ResizeWidth, ResizeHeight, Fit, MaxBytes に設定して使う。
+type Preset struct {
+	Name     string
+	Width    int
+	Height   int
+	Fit      string
+	MaxBytes int // 出力するファイルの最大サイズ (byte)。0 の場合は制限なし
+}
+
+const (
+	PresetSlack   = "slack"
+	PresetDiscord = "discord"
+	PresetTwitter = "twitter"
+	PresetOGP     = "ogp"
+	PresetGitHub  = "github"
+	PresetLINE    = "line"
+)
+
+var (
+	Presets = []Preset{
+		{Name: PresetSlack, Width: 128, Height: 128, Fit: image.FitContain, MaxBytes: 128 * 1024},
+		{Name: PresetDiscord, Width: 128, Height: 128, Fit: image.FitContain, MaxBytes: 256 * 1024},
+		{Name: PresetTwitter, Width: 1200, Height: 675, Fit: image.FitContain, MaxBytes: 5 * 1024 * 1024},
+		{Name: PresetOGP, Width: 1200, Height: 630, Fit: image.FitContain, MaxBytes: 8 * 1024 * 1024},
+		{Name: PresetGitHub, Width: 1280, Height: 640, Fit: image.FitContain, MaxBytes: 1024 * 1024},
+		{Name: PresetLINE, Width: 370, Height: 320, Fit: image.FitContain, MaxBytes: 1024 * 1024},
+	}
+)
+
+// PresetNames はプリセット名の一覧を返す。
+func PresetNames() []string {
+	var names []string
+	for _, p := range Presets {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// FindPreset は名前に一致するプリセットを返す。
+func FindPreset(name string) (Preset, error) {
+	for _, p := range Presets {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return Preset{}, fmt.Errorf("%s is not supported preset. supported presets are [%s]", name, strings.Join(PresetNames(), "|"))
+}
@@ -0,0 +1,40 @@
+package textimg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindPreset(t *testing.T) {
+	tests := []struct {
+		desc    string
+		name    string
+		want    Preset
+		wantErr bool
+	}{
+		{
+			desc: "正常系: 名前に一致するプリセット",
+			name: "twitter",
+			want: Preset{Name: PresetTwitter, Width: 1200, Height: 675, Fit: "contain", MaxBytes: 5 * 1024 * 1024},
+		},
+		{desc: "異常系: 存在しないプリセット", name: "sushi", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			got, err := FindPreset(tt.name)
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tt.want, got)
+		})
+	}
+}
+
+func TestPresetNames(t *testing.T) {
+	assert.Equal(t, []string{"slack", "discord", "twitter", "ogp", "github", "line"}, PresetNames())
+}
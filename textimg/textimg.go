@@ -0,0 +1,295 @@
+// Package textimg は ANSI エスケープシーケンスで色付けされたテキストを画像に
+// 変換する。
+//
+// コマンドライン引数や標準入力、環境変数、ファイルには依存しないので、他のプロ
+// グラムに組み込んで使える。フォントはフォントファイルの中身で渡す。
+package textimg
+
+import (
+	"context"
+	"errors"
+	stdimage "image"
+	"io"
+	"strings"
+
+	"github.com/jiro4989/textimg/v3/fontfile"
+	"github.com/jiro4989/textimg/v3/image"
+	"github.com/jiro4989/textimg/v3/internal/width"
+	"github.com/jiro4989/textimg/v3/parser"
+	"github.com/jiro4989/textimg/v3/token"
+	"github.com/mattn/go-runewidth"
+	"golang.org/x/image/font/gofont/gomono"
+)
+
+// ErrEmptyInput は描画するテキストが空の場合のエラー。
+var ErrEmptyInput = errors.New("input text is empty")
+
+type (
+	// renderer は Options を検証して、フォントを読み込んだ描画の設定。
+	renderer struct {
+		opt   Options
+		param image.ImageParam
+		cond  *runewidth.Condition
+	}
+
+	// document は描画するトークンと、トークンから決まる描画の設定。
+	document struct {
+		tokens      token.Tokens
+		rowLines    []int // 各行が入力の何行目 (0始まり) だったか
+		lineCount   int   // アニメーションの1フレームの行数
+		frameDelays []int // 区切り行で指定したフレームごとの表示時間
+	}
+)
+
+// Render は r から読み込んだテキストを描画した画像を返す。
+// アニメーションの場合も、全ての行を1枚に描画した画像を返す。
+func Render(ctx context.Context, r io.Reader, opt Options) (stdimage.Image, error) {
+	rd, err := newRenderer(opt, image.Format{})
+	if err != nil {
+		return nil, err
+	}
+	doc, err := rd.read(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	img, err := rd.draw(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
+	return img.RGBA(), nil
+}
+
+// RenderTo は r から読み込んだテキストを format の形式で w に出力する。
+// format は "png" のような形式名か、".png" のような拡張子で指定する。
+// image.RegisterFormat で登録した形式も使える。
+func RenderTo(ctx context.Context, w io.Writer, r io.Reader, format string, opt Options) error {
+	f, err := image.LookupFormat(format)
+	if err != nil {
+		return err
+	}
+	rd, err := newRenderer(opt, f)
+	if err != nil {
+		return err
+	}
+	doc, err := rd.read(ctx, r)
+	if err != nil {
+		return err
+	}
+	return rd.encode(ctx, w, doc, f)
+}
+
+// newRenderer は opt を検証して、f の形式で出力するための描画の設定を返す。
+func newRenderer(opt Options, f image.Format) (*renderer, error) {
+	opt.setDefaults()
+	if err := opt.validate(); err != nil {
+		return nil, err
+	}
+	opt.scale()
+
+	face, emojiFace, err := opt.faces()
+	if err != nil {
+		return nil, err
+	}
+	if err := image.ValidateCellSize(face, opt.LineSpacing, opt.LetterSpacing); err != nil {
+		return nil, err
+	}
+
+	rd := &renderer{
+		opt:   opt,
+		param: opt.imageParam(face, emojiFace),
+		cond:  width.New(opt.EastAsianWidth),
+	}
+	// ベクタ形式はフォントコレクションから取り出したフォントで出力する
+	if f.Vector {
+		data, index := opt.FontData, opt.FontIndex
+		if data == nil {
+			data, index = gomono.TTF, 0
+		}
+		rd.param.FontData, err = fontfile.Extract(data, index)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rd, nil
+}
+
+// encode は doc を f の形式で w に出力する。
+func (rd *renderer) encode(ctx context.Context, w io.Writer, doc *document, f image.Format) error {
+	var img *image.Image
+	if f.Text {
+		// フォントを描画せずにトークンをそのまま出力する
+		p := rd.param
+		img = image.NewImage(&p)
+		img.SetTokens(doc.tokens)
+	} else {
+		var err error
+		img, err = rd.draw(ctx, doc)
+		if err != nil {
+			return err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return img.Encode(w, f.Name)
+}
+
+// read は r からテキストを読み込んで、行の選択、フレームの分割、折り返しと
+// 行の切り出しをしたトークンを返す。
+func (rd *renderer) read(ctx context.Context, r io.Reader) (*document, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// 行末の改行は行の区切りとして扱い、空行を増やさない
+	s := strings.ReplaceAll(string(b), "\r\n", "\n")
+	s = strings.TrimSuffix(s, "\n")
+	lines := selectLines(strings.Split(s, "\n"), rd.opt.LinesFrom, rd.opt.LinesTo)
+	if isEmpty(lines) {
+		return nil, ErrEmptyInput
+	}
+
+	opt := &rd.opt
+	doc := &document{lineCount: opt.AnimationLineCount}
+	// 区切り行がある場合は、行数ではなく区切り行でフレームに分ける
+	if opt.UseAnimation && !opt.Slide && !opt.Typewriter && opt.MarqueeWidth < 1 {
+		if texts, lineCount, delays, ok := splitFrames(lines, opt.FrameSeparator); ok {
+			// 区切り行だけの入力はフレームが残らない
+			if isEmpty(texts) {
+				return nil, ErrEmptyInput
+			}
+			lines, doc.lineCount, doc.frameDelays = texts, lineCount, delays
+		}
+	}
+	// スライドアニメーションを使うときはテキストを加工する
+	if opt.Slide {
+		lines = toSlideStrings(lines, doc.lineCount, opt.SlideWidth, opt.SlideForever)
+	}
+
+	tokens, err := parser.Parse(normalizeText(strings.Join(lines, "\n")))
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var rowLines []int
+	if opt.Truncate {
+		tokens = tokens.Truncate(opt.Columns, rd.cond)
+	} else if 0 < opt.Columns {
+		tokens, rowLines = tokens.Wrap(opt.Columns, opt.WrapMode, rd.cond)
+	}
+
+	if rowLines == nil {
+		rowLines = make([]int, len(tokens.StringLines()))
+		for i := range rowLines {
+			rowLines[i] = i
+		}
+	}
+
+	// 行数を超える行は切り捨てる
+	if lines := len(rowLines); 0 < opt.Rows && opt.Rows < lines {
+		var from int
+		if opt.Tail {
+			from = lines - opt.Rows
+		}
+		tokens = tokens.Rows(from, from+opt.Rows)
+		rowLines = rowLines[from : from+opt.Rows]
+	}
+	doc.tokens, doc.rowLines = tokens, rowLines
+
+	return doc, nil
+}
+
+// draw は doc を描画した画像を返す。
+func (rd *renderer) draw(ctx context.Context, doc *document) (*image.Image, error) {
+	p := rd.param
+	p.RowLines = doc.rowLines
+	p.AnimationLineCount = doc.lineCount
+	p.FrameDelays = doc.frameDelays
+	p.BaseWidth = doc.tokens.MaxStringWidth(rd.cond)
+	if 0 < rd.opt.Cols {
+		p.BaseWidth = rd.opt.Cols
+	}
+	p.BaseHeight = len(doc.tokens.StringLines())
+	if 0 < rd.opt.Rows {
+		p.BaseHeight = rd.opt.Rows
+	}
+
+	w, h := p.ImageSize()
+	p.ResizeWidth, p.ResizeHeight = complementWidthHeight(w, h, p.ResizeWidth, p.ResizeHeight)
+
+	img := image.NewImage(&p)
+	if err := img.Draw(doc.tokens); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// isEmpty は全ての行が空かを判定する。
+func isEmpty(lines []string) bool {
+	for _, v := range lines {
+		if v != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// complementWidthHeight は width, height の片方が 0 の時、サイズを調整する。
+func complementWidthHeight(x, y, w, h int) (int, int) {
+	if w == 0 {
+		hh := y
+		d := float64(h) / float64(hh)
+		w = int(float64(x) * d)
+		return w, h
+	}
+	if h == 0 {
+		ww := x
+		d := float64(w) / float64(ww)
+		h = int(float64(y) * d)
+		return w, h
+	}
+	return w, h
+}
+
+// normalizeText はテキストを正規化する。
+func normalizeText(s string) string {
+	// タブ文字は画像描画時に表示されないので暫定対応で半角スペースに置換
+	s = strings.ReplaceAll(s, "\t", "  ")
+
+	// ゼロ幅文字を削除
+	return removeZeroWidthCharacters(s)
+}
+
+// removeZeroWidthSpace はゼロ幅文字が存在したときに削除する。
+//
+// 参考
+// * ゼロ幅スペース https://ja.wikipedia.org/wiki/%E3%82%BC%E3%83%AD%E5%B9%85%E3%82%B9%E3%83%9A%E3%83%BC%E3%82%B9
+func removeZeroWidthCharacters(s string) string {
+	zwc := []rune{
+		0x200b, // zero width space
+		0x200c, // zero width joiner
+		0x200d, // zero width joiner
+		0xfeff, // zero width no-break-space
+	}
+	var ret []rune
+chars:
+	for _, v := range s {
+		for _, c := range zwc {
+			if v == c {
+				continue chars
+			}
+		}
+		ret = append(ret, v)
+	}
+	return string(ret)
+}
@@ -0,0 +1,332 @@
+package textimg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	stdimage "image"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jiro4989/textimg/v3/image"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/font/gofont/gomono"
+)
+
+func TestRender(t *testing.T) {
+	// 比較用の1行と2行の画像の大きさ
+	size := func(text string) stdimage.Point {
+		img, err := Render(context.Background(), strings.NewReader(text), Options{})
+		assert.NoError(t, err)
+		return img.Bounds().Size()
+	}
+	oneLine := size("abc")
+	twoLines := size("abc\nabc")
+	fourColumns := size("abcd")
+
+	tests := []struct {
+		desc string
+		text string
+		opt  Options
+		want stdimage.Point
+	}{
+		{
+			desc: "正常系: 末尾の改行は空行にならない",
+			text: "abc\n",
+			want: oneLine,
+		},
+		{
+			desc: "正常系: CRLF の改行",
+			text: "abc\r\nabc\r\n",
+			want: twoLines,
+		},
+		{
+			desc: "正常系: 列数で折り返す",
+			text: "abcabc",
+			opt:  Options{Columns: 3},
+			want: twoLines,
+		},
+		{
+			desc: "正常系: 行数を超える行は切り捨てる",
+			text: "abc\nabc\nabc",
+			opt:  Options{Rows: 1},
+			want: oneLine,
+		},
+		{
+			desc: "正常系: タブは空白2つに置換する",
+			text: "a\tb",
+			want: fourColumns,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			got, err := Render(context.Background(), strings.NewReader(tt.text), tt.opt)
+			assert.NoError(err)
+			assert.Equal(tt.want, got.Bounds().Size())
+		})
+	}
+}
+
+func TestRenderTo(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		desc    string
+		ctx     context.Context
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{desc: "正常系: PNG", ctx: context.Background(), format: "png", want: "\x89PNG"},
+		{desc: "正常系: 拡張子で指定", ctx: context.Background(), format: ".png", want: "\x89PNG"},
+		{desc: "正常系: 大文字の形式", ctx: context.Background(), format: "GIF", want: "GIF89a"},
+		{desc: "正常系: ビルトインのフォントで SVG", ctx: context.Background(), format: "svg", want: "<svg"},
+		{desc: "正常系: HTML", ctx: context.Background(), format: "html", want: "<!DOCTYPE html>"},
+		{desc: "異常系: 未対応の形式", ctx: context.Background(), format: "bmp", wantErr: true},
+		{desc: "異常系: キャンセル済みのコンテキスト", ctx: canceled, format: "png", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			var buf bytes.Buffer
+			err := RenderTo(tt.ctx, &buf, strings.NewReader("\x1b[31mred\x1b[0m"), tt.format, Options{})
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Contains(buf.String(), tt.want)
+		})
+	}
+}
+
+func TestRender_Options(t *testing.T) {
+	tests := []struct {
+		desc    string
+		text    string
+		opt     func(o *Options)
+		wantErr error
+	}{
+		{
+			desc: "正常系: 行数を指定しないアニメーション",
+			text: "abc\nabc",
+			opt:  func(o *Options) { o.UseAnimation, o.AnimationLineCount = true, 0 },
+		},
+		{
+			desc: "正常系: 区切り行でフレームに分ける",
+			text: "a\n\f 50\nb\nc",
+			opt:  func(o *Options) { o.UseAnimation, o.FrameSeparator = true, "\f" },
+		},
+		{
+			desc: "正常系: 先頭の区切り行と連続した区切り行は無視する",
+			text: "\f\na\n\f\n\f 50\nb",
+			opt:  func(o *Options) { o.UseAnimation, o.FrameSeparator = true, "\f" },
+		},
+		{
+			desc: "正常系: スライドアニメーション",
+			text: "a\nb\nc",
+			opt:  func(o *Options) { o.Slide, o.AnimationLineCount = true, 2 },
+		},
+		{
+			desc:    "異常系: 入力が空",
+			text:    "",
+			opt:     func(o *Options) {},
+			wantErr: ErrEmptyInput,
+		},
+		{
+			desc:    "異常系: 区切り行だけの入力",
+			text:    "\f\n",
+			opt:     func(o *Options) { o.UseAnimation, o.FrameSeparator = true, "\f" },
+			wantErr: ErrEmptyInput,
+		},
+		{
+			desc:    "異常系: 範囲内に行が存在しない",
+			text:    "abc\nabc",
+			opt:     func(o *Options) { o.LinesFrom = 3 },
+			wantErr: ErrEmptyInput,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			o := DefaultOptions()
+			tt.opt(&o)
+			var buf bytes.Buffer
+			err := RenderTo(context.Background(), &buf, strings.NewReader(tt.text), "gif", o)
+			if tt.wantErr != nil {
+				assert.ErrorIs(err, tt.wantErr)
+				return
+			}
+			assert.NoError(err)
+		})
+	}
+}
+
+func TestRender_Lines(t *testing.T) {
+	assert := assert.New(t)
+
+	// 行の範囲を指定すると、範囲外の行の色を引き継いで範囲内の行だけを描画する
+	o := DefaultOptions()
+	o.LinesFrom, o.LinesTo = 2, 2
+	got, err := Render(context.Background(), strings.NewReader("\x1b[31mab\nc\nd"), o)
+	assert.NoError(err)
+	want, err := Render(context.Background(), strings.NewReader("\x1b[31mc"), DefaultOptions())
+	assert.NoError(err)
+	assert.Equal(want, got)
+}
+
+func TestRenderTo_RegisterFormat(t *testing.T) {
+	assert := assert.New(t)
+
+	// 画像の大きさだけを出力する独自の形式
+	image.RegisterFormat(image.Format{
+		Name:       "size",
+		Extensions: []string{".size"},
+		MIMEType:   "text/plain",
+		Encoder: image.EncoderFunc(func(w io.Writer, img *image.Image, level int) error {
+			_, err := fmt.Fprint(w, img.RGBA().Bounds().Size())
+			return err
+		}),
+	})
+
+	want, err := Render(context.Background(), strings.NewReader("abc"), Options{})
+	assert.NoError(err)
+
+	var buf bytes.Buffer
+	err = RenderTo(context.Background(), &buf, strings.NewReader("abc"), ".size", Options{})
+	assert.NoError(err)
+	assert.Equal(want.Bounds().Size().String(), buf.String())
+}
+
+func TestRenderTo_PNGSize(t *testing.T) {
+	assert := assert.New(t)
+
+	want, err := Render(context.Background(), strings.NewReader("abc"), Options{})
+	assert.NoError(err)
+
+	var buf bytes.Buffer
+	err = RenderTo(context.Background(), &buf, strings.NewReader("abc"), "png", Options{})
+	assert.NoError(err)
+	got, err := png.Decode(&buf)
+	assert.NoError(err)
+	assert.Equal(want.Bounds(), got.Bounds())
+}
+
+func TestRemoveZeroWidthCharacters(t *testing.T) {
+	type TestData struct {
+		desc   string
+		s      string
+		expect string
+	}
+	tds := []TestData{
+		{desc: "Zero width space (U+200B)が削除される", s: "A\u200bB", expect: "AB"},
+		{desc: "Zero width joiner (U+200C)が削除される", s: "A\u200cB", expect: "AB"},
+		{desc: "Zero width joiner (U+200D)が削除される", s: "A\u200dB", expect: "AB"},
+		{desc: "U+200B ~ U+200Dが削除される", s: "あ\u200bい\u200cう\u200dえ", expect: "あいうえ"},
+	}
+	for _, v := range tds {
+		t.Run(v.desc, func(t *testing.T) {
+			got := removeZeroWidthCharacters(v.s)
+			assert.Equal(t, v.expect, got, v.desc)
+		})
+	}
+}
+
+func TestComplementWidthHeight(t *testing.T) {
+	type TestData struct {
+		desc       string
+		x, y, w, h int
+		wantWidth  int
+		wantHeight int
+	}
+	tds := []TestData{
+		{
+			desc:       "正常系: wが0のときはwidthが調整される",
+			x:          200,
+			y:          100,
+			w:          0,
+			h:          200,
+			wantWidth:  400,
+			wantHeight: 200,
+		},
+		{
+			desc:       "正常系: hが0のときはheightが調整される",
+			x:          200,
+			y:          100,
+			w:          100,
+			h:          0,
+			wantWidth:  100,
+			wantHeight: 50,
+		},
+		{
+			desc:       "正常系: hが0のときはheightが調整される",
+			x:          200,
+			y:          100,
+			w:          100,
+			h:          0,
+			wantWidth:  100,
+			wantHeight: 50,
+		},
+		{
+			desc:       "正常系: wとhが0出ないときはwとhが返る",
+			x:          200,
+			y:          100,
+			w:          400,
+			h:          300,
+			wantWidth:  400,
+			wantHeight: 300,
+		},
+	}
+	for _, v := range tds {
+		t.Run(v.desc, func(t *testing.T) {
+			a := assert.New(t)
+			w, h := complementWidthHeight(v.x, v.y, v.w, v.h)
+			a.Equal(v.wantWidth, w)
+			a.Equal(v.wantHeight, h)
+		})
+	}
+}
+
+func TestRenderTo_PDFFont(t *testing.T) {
+	cff, err := os.ReadFile("../fontfile/testdata/CFFTest.otf")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		desc     string
+		fontData []byte
+		want     []string
+	}{
+		{
+			desc:     "正常系: TrueType のフォントは FontFile2 で埋め込む",
+			fontData: gomono.TTF,
+			want:     []string{"%PDF-1.4", "/CIDFontType2", "/FontFile2", "/CIDToGIDMap /Identity"},
+		},
+		{
+			desc:     "正常系: CFF のフォントは OpenType のまま FontFile3 で埋め込む",
+			fontData: cff,
+			want:     []string{"%PDF-1.6", "/CIDFontType0", "/FontFile3", "/Subtype /OpenType"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			opt := DefaultOptions()
+			opt.FontData = tt.fontData
+			var buf bytes.Buffer
+			err := RenderTo(context.Background(), &buf, strings.NewReader("abc"), "pdf", opt)
+			assert.NoError(err)
+			for _, w := range tt.want {
+				assert.Contains(buf.String(), w)
+			}
+		})
+	}
+}
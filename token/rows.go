@@ -0,0 +1,30 @@
+package token
+
+import "github.com/jiro4989/textimg/v3/internal/width"
+
+// Rows は from 行目から to 行目の手前まで (0始まり) の行を返す。
+// from 行目より前の色指定のトークンは色を引き継ぐために先頭に残す。
+func (t *Tokens) Rows(from, to int) Tokens {
+	// 行に分けるだけなので表示幅は使わない
+	lines := t.toClusters(width.New(false))
+	if len(lines) < to {
+		to = len(lines)
+	}
+	if to <= from {
+		return nil
+	}
+
+	var head []cluster
+	for _, line := range lines[:from] {
+		for _, c := range line {
+			if c.token != nil {
+				head = append(head, c)
+			}
+		}
+	}
+
+	rows := make([][]cluster, to-from)
+	copy(rows, lines[from:to])
+	rows[0] = append(head, rows[0]...)
+	return fromClusters(rows)
+}
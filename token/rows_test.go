@@ -0,0 +1,63 @@
+package token
+
+import (
+	"testing"
+
+	"github.com/jiro4989/textimg/v3/color"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokens_Rows(t *testing.T) {
+	red := Token{Kind: KindColor, ColorType: ColorTypeForeground, Color: color.RGBARed}
+	green := Token{Kind: KindColor, ColorType: ColorTypeForeground, Color: color.RGBAGreen}
+
+	tests := []struct {
+		desc string
+		t    Tokens
+		from int
+		to   int
+		want Tokens
+	}{
+		{
+			desc: "正常系: 先頭の行を取得する",
+			t:    Tokens{NewText("1\n2\n3")},
+			from: 0,
+			to:   2,
+			want: Tokens{NewText("1\n2")},
+		},
+		{
+			desc: "正常系: 末尾の行を取得する",
+			t:    Tokens{NewText("1\n2\n3")},
+			from: 1,
+			to:   3,
+			want: Tokens{NewText("2\n3")},
+		},
+		{
+			desc: "正常系: 範囲より前の色指定を引き継ぐ",
+			t:    Tokens{red, NewText("1\n"), green, NewText("2\n3")},
+			from: 2,
+			to:   3,
+			want: Tokens{red, green, NewText("3")},
+		},
+		{
+			desc: "正常系: 行数を超える場合は最後まで",
+			t:    Tokens{NewText("1\n2")},
+			from: 1,
+			to:   10,
+			want: Tokens{NewText("2")},
+		},
+		{
+			desc: "正常系: 範囲が空の場合はnil",
+			t:    Tokens{NewText("1\n2")},
+			from: 2,
+			to:   4,
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got := tt.t.Rows(tt.from, tt.to)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
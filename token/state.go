@@ -0,0 +1,94 @@
+package token
+
+import "github.com/jiro4989/textimg/v3/color"
+
+const (
+	sourceForeground = iota // デフォルトの文字色
+	sourceBackground        // デフォルトの背景色
+	sourceColor             // エスケープシーケンスで指定した色
+)
+
+// colorSource は文字色や背景色がどの色から来たか。
+// 反転するとデフォルトの文字色と背景色が入れ替わるので、色そのものではなく
+// 由来で持つ。
+type colorSource struct {
+	kind  int
+	color color.RGBA
+}
+
+// StyleState は t の末尾の時点の文字色と背景色、太字などの文字の装飾を再現す
+// る、最小限の色指定のトークンを返す。
+// 分割したトークンの続きの先頭に付けると、前の部分の色と装飾を引き継げる。
+// Rows と違い、入力が長くても返すトークンの数は増えない。
+func (t Tokens) StyleState() Tokens {
+	var (
+		fg = colorSource{kind: sourceForeground}
+		bg = colorSource{kind: sourceBackground}
+		// 文字の装飾はリセットでのみ解除される
+		attrs = make(map[ColorType]bool)
+	)
+	for _, tt := range t {
+		if tt.Kind != KindColor {
+			continue
+		}
+		switch tt.ColorType {
+		case ColorTypeReset:
+			fg = colorSource{kind: sourceForeground}
+			bg = colorSource{kind: sourceBackground}
+			clear(attrs)
+		case ColorTypeBold, ColorTypeDim, ColorTypeItalic, ColorTypeUnderline,
+			ColorTypeBlink, ColorTypeSpeedyBlink, ColorTypeHide, ColorTypeDelete:
+			attrs[tt.ColorType] = true
+		case ColorTypeResetForeground:
+			fg = colorSource{kind: sourceForeground}
+		case ColorTypeResetBackground:
+			bg = colorSource{kind: sourceBackground}
+		case ColorTypeReverse:
+			fg, bg = bg, fg
+		case ColorTypeForeground:
+			fg = colorSource{kind: sourceColor, color: tt.Color}
+		case ColorTypeBackground:
+			bg = colorSource{kind: sourceColor, color: tt.Color}
+		}
+	}
+
+	ret := Tokens{NewResetColor()}
+	for ct := ColorTypeBold; ct <= ColorTypeDelete; ct++ {
+		if attrs[ct] {
+			ret = append(ret, Token{Kind: KindColor, ColorType: ct})
+		}
+	}
+	// デフォルトの色が入れ替わっている場合は、反転してから色を指定し直す
+	if fg.kind == sourceBackground || bg.kind == sourceForeground {
+		ret = append(ret, NewReverseColor())
+		switch fg.kind {
+		case sourceForeground:
+			ret = append(ret, NewResetForegroundColor())
+		case sourceColor:
+			ret = append(ret, newColor(ColorTypeForeground, fg.color))
+		}
+		switch bg.kind {
+		case sourceBackground:
+			ret = append(ret, NewResetBackgroundColor())
+		case sourceColor:
+			ret = append(ret, newColor(ColorTypeBackground, bg.color))
+		}
+		return ret
+	}
+
+	if fg.kind == sourceColor {
+		ret = append(ret, newColor(ColorTypeForeground, fg.color))
+	}
+	if bg.kind == sourceColor {
+		ret = append(ret, newColor(ColorTypeBackground, bg.color))
+	}
+	return ret
+}
+
+func newColor(t ColorType, col color.RGBA) Token {
+	return Token{
+		Kind:      KindColor,
+		ColorType: t,
+		Color:     col,
+	}
+}
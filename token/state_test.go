@@ -0,0 +1,91 @@
+package token
+
+import (
+	"testing"
+
+	"github.com/jiro4989/textimg/v3/color"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokens_StyleState(t *testing.T) {
+	var (
+		red     = newColor(ColorTypeForeground, color.RGBARed)
+		green   = newColor(ColorTypeForeground, color.RGBAGreen)
+		blueBg  = newColor(ColorTypeBackground, color.RGBABlue)
+		reset   = NewResetColor()
+		reverse = NewReverseColor()
+	)
+
+	tests := []struct {
+		desc string
+		t    Tokens
+		want Tokens
+	}{
+		{
+			desc: "正常系: 色指定がない場合はリセットのみ",
+			t:    Tokens{NewText("a")},
+			want: Tokens{reset},
+		},
+		{
+			desc: "正常系: 最後の文字色と背景色だけを残す",
+			t:    Tokens{red, NewText("a"), blueBg, green, NewText("b")},
+			want: Tokens{reset, green, blueBg},
+		},
+		{
+			desc: "正常系: リセットより前の色指定は残さない",
+			t:    Tokens{red, blueBg, NewText("a"), reset, NewText("b")},
+			want: Tokens{reset},
+		},
+		{
+			desc: "正常系: 文字色のみリセット",
+			t:    Tokens{red, blueBg, NewResetForegroundColor()},
+			want: Tokens{reset, blueBg},
+		},
+		{
+			desc: "正常系: 指定した色の反転は色を入れ替える",
+			t:    Tokens{red, blueBg, reverse},
+			want: Tokens{reset, newColor(ColorTypeForeground, color.RGBABlue), newColor(ColorTypeBackground, color.RGBARed)},
+		},
+		{
+			desc: "正常系: デフォルトの色の反転は反転で再現する",
+			t:    Tokens{reverse, NewText("a")},
+			want: Tokens{reset, reverse},
+		},
+		{
+			desc: "正常系: 反転後に文字色を指定",
+			t:    Tokens{reverse, red},
+			want: Tokens{reset, reverse, red},
+		},
+		{
+			desc: "正常系: 反転後に文字色をリセット",
+			t:    Tokens{reverse, NewResetForegroundColor()},
+			want: Tokens{reset, reverse, NewResetForegroundColor()},
+		},
+		{
+			desc: "正常系: 文字の装飾を残す",
+			t:    Tokens{NewTextAttribute("4"), red, NewTextAttribute("1"), NewText("a")},
+			want: Tokens{reset, NewTextAttribute("1"), NewTextAttribute("4"), red},
+		},
+		{
+			desc: "正常系: 反転と文字の装飾",
+			t:    Tokens{NewTextAttribute("1"), reverse},
+			want: Tokens{reset, NewTextAttribute("1"), reverse},
+		},
+		{
+			desc: "正常系: リセットで文字の装飾も解除する",
+			t:    Tokens{NewTextAttribute("1"), NewTextAttribute("3"), reset, red},
+			want: Tokens{reset, red},
+		},
+		{
+			desc: "正常系: 2回反転すると元に戻る",
+			t:    Tokens{red, reverse, reverse},
+			want: Tokens{reset, red},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got := tt.t.StyleState()
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -42,11 +42,6 @@ const (
 	ColorTypeResetBackground
 )
 
-func init() {
-	// Unicode Neutral で定義されている絵文字(例: 👁)を幅2として扱う
-	runewidth.DefaultCondition.StrictEmojiNeutral = false
-}
-
 func NewResetColor() Token {
 	return Token{
 		Kind:      KindColor,
@@ -75,6 +70,16 @@ func NewReverseColor() Token {
 	}
 }
 
+// NewTextAttribute は太字やアンダーラインなどの文字の装飾を返す。
+// text は \x1b[1m の 1 のような 1~9 の数字。
+func NewTextAttribute(text string) Token {
+	n, _ := strconv.Atoi(text)
+	return Token{
+		Kind:      KindColor,
+		ColorType: ColorTypeReset + ColorType(n),
+	}
+}
+
 func NewText(text string) Token {
 	return Token{
 		Kind: KindText,
@@ -111,7 +116,9 @@ func colorType(n int) ColorType {
 	return t
 }
 
-func (t *Tokens) MaxStringWidth() int {
+// MaxStringWidth は最も表示幅の広い行の表示幅を返す。
+// 文字の表示幅は cond で判定する。
+func (t *Tokens) MaxStringWidth(cond *runewidth.Condition) int {
 	var strs []string
 	for _, tt := range *t {
 		if tt.Kind != KindText {
@@ -124,7 +131,7 @@ func (t *Tokens) MaxStringWidth() int {
 	lines := strings.Split(s, "\n")
 	var max int
 	for _, line := range lines {
-		w := runewidth.StringWidth(line)
+		w := cond.StringWidth(line)
 		if max < w {
 			max = w
 		}
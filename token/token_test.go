@@ -4,14 +4,16 @@ import (
 	"testing"
 
 	"github.com/jiro4989/textimg/v3/color"
+	"github.com/jiro4989/textimg/v3/internal/width"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestToken_MaxStringWidth(t *testing.T) {
 	tests := []struct {
-		desc string
-		t    Tokens
-		want int
+		desc      string
+		t         Tokens
+		eastAsian bool
+		want      int
 	}{
 		{
 			desc: "正常系: hello = 5",
@@ -80,11 +82,28 @@ func TestToken_MaxStringWidth(t *testing.T) {
 			},
 			want: 5,
 		},
+		{
+			desc: "正常系: 曖昧な幅の文字は幅1",
+			t:    Tokens{NewText("○△")},
+			want: 2,
+		},
+		{
+			desc:      "正常系: 東アジアの環境では曖昧な幅の文字は幅2",
+			t:         Tokens{NewText("○△")},
+			eastAsian: true,
+			want:      4,
+		},
+		{
+			desc:      "正常系: East Asian Width の場合は Unicode Neutral の絵文字は幅2",
+			t:         Tokens{NewText("👁")},
+			eastAsian: true,
+			want:      2,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.desc, func(t *testing.T) {
 			assert := assert.New(t)
-			got := tt.t.MaxStringWidth()
+			got := tt.t.MaxStringWidth(width.New(tt.eastAsian))
 			assert.Equal(tt.want, got)
 		})
 	}
@@ -0,0 +1,224 @@
+package token
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/clipperhouse/uax29/v2/graphemes"
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+	WrapModeChar = "char"
+	WrapModeWord = "word"
+	WrapModeNone = "none"
+
+	// 行を切り詰めた時に末尾に付与する文字
+	Ellipsis = "…"
+)
+
+var (
+	WrapModes = []string{
+		WrapModeChar,
+		WrapModeWord,
+		WrapModeNone,
+	}
+)
+
+// ValidateWrapMode は折り返し方法が正しいかを検証する。
+func ValidateWrapMode(s string) error {
+	for _, v := range WrapModes {
+		if s == v {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not supported wrap mode.", s)
+}
+
+// cluster は折り返し時に分割しない文字のまとまり。
+// 書記素クラスタ (国旗の絵文字や結合文字の付いた文字など) を1つのまとまりにす
+// る。色指定のトークンの後に続く幅0の文字は、色指定の前の文字と同じまとまりに
+// する。
+// 色指定のトークンの場合は token に値が入る。
+type cluster struct {
+	token *Token
+	text  string
+	width int
+}
+
+func (c cluster) isSpace() bool {
+	return c.token == nil && (c.text == " " || c.text == "　")
+}
+
+// toClusters はトークンを行ごとのまとまりの配列に変換する。
+// 改行はまとまりに含めず、行の区切りとして扱う。
+// 文字の表示幅は cond で判定する。
+func (t *Tokens) toClusters(cond *runewidth.Condition) (lines [][]cluster) {
+	var line []cluster
+	for _, tt := range *t {
+		if tt.Kind != KindText {
+			tk := tt
+			line = append(line, cluster{token: &tk})
+			continue
+		}
+		for j, s := range strings.Split(tt.Text, "\n") {
+			if 0 < j {
+				lines = append(lines, line)
+				line = nil
+			}
+			g := graphemes.FromString(s)
+			for g.Next() {
+				v := g.Value()
+				w := cond.StringWidth(v)
+				if w == 0 && 0 < len(line) && line[len(line)-1].token == nil {
+					line[len(line)-1].text += v
+					continue
+				}
+				line = append(line, cluster{text: v, width: w})
+			}
+		}
+	}
+	lines = append(lines, line)
+	return
+}
+
+// fromClusters は行ごとのまとまりをトークンに戻す。
+// 連続する文字は1つのトークンにまとめる。
+func fromClusters(lines [][]cluster) Tokens {
+	var (
+		ret Tokens
+		sb  strings.Builder
+	)
+	flush := func() {
+		if sb.Len() < 1 {
+			return
+		}
+		ret = append(ret, NewText(sb.String()))
+		sb.Reset()
+	}
+	for i, line := range lines {
+		if 0 < i {
+			sb.WriteString("\n")
+		}
+		for _, c := range line {
+			if c.token != nil {
+				flush()
+				ret = append(ret, *c.token)
+				continue
+			}
+			sb.WriteString(c.text)
+		}
+	}
+	flush()
+	return ret
+}
+
+// Wrap は表示幅が columns を超える行を折り返したトークンを返す。
+// 色指定のトークンはそのまま残るので、折り返した後の行にも色が引き継がれる。
+// 全角文字や書記素クラスタの途中では折り返さない。columns より幅の広い文字は
+// 分割できないので、その文字だけの行にして columns からはみ出させる。
+//
+// 2つ目の戻り値は折り返した後の各行が、折り返す前の何行目 (0始まり) だったか
+// を表す。
+// 文字の表示幅は cond で判定する。
+func (t *Tokens) Wrap(columns int, mode string, cond *runewidth.Condition) (Tokens, []int) {
+	lines := t.toClusters(cond)
+	var (
+		ret      [][]cluster
+		rowLines []int
+	)
+	for n, line := range lines {
+		rows := [][]cluster{line}
+		if 0 < columns && mode != WrapModeNone {
+			rows = wrapLine(line, columns, mode == WrapModeWord)
+		}
+		for _, row := range rows {
+			ret = append(ret, row)
+			rowLines = append(rowLines, n)
+		}
+	}
+	return fromClusters(ret), rowLines
+}
+
+func wrapLine(line []cluster, columns int, byWord bool) (rows [][]cluster) {
+	var (
+		start int
+		width int
+		// 行内で最後に出現した空白の位置。単語単位で折り返す時に使う
+		lastSpace = -1
+	)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		for columns < width+c.width && 0 < width {
+			end := i
+			if byWord && start < lastSpace {
+				end = lastSpace + 1
+			}
+			rows = append(rows, line[start:end])
+			start = end
+			width = 0
+			lastSpace = -1
+			// 単語単位で折り返した場合は空白以降の文字を次の行に送る
+			for j, v := range line[start:i] {
+				width += v.width
+				if v.isSpace() {
+					lastSpace = start + j
+				}
+			}
+		}
+		if c.isSpace() {
+			lastSpace = i
+		}
+		width += c.width
+	}
+	rows = append(rows, line[start:])
+	return
+}
+
+// Truncate は表示幅が columns を超える行を切り詰め、末尾に省略記号を付与した
+// トークンを返す。
+// 切り詰めた部分の色指定のトークンは後続の行に色を引き継ぐために残す。
+// 文字の表示幅は cond で判定する。
+func (t *Tokens) Truncate(columns int, cond *runewidth.Condition) Tokens {
+	if columns < 1 {
+		return *t
+	}
+
+	var (
+		lines = t.toClusters(cond)
+		ew    = cond.StringWidth(Ellipsis)
+	)
+	for n, line := range lines {
+		var lw int
+		for _, c := range line {
+			lw += c.width
+		}
+		if lw <= columns {
+			continue
+		}
+
+		var (
+			row      []cluster
+			width    int
+			appended bool
+		)
+		for _, c := range line {
+			if c.token != nil {
+				row = append(row, c)
+				continue
+			}
+			if appended {
+				continue
+			}
+			if columns-ew < width+c.width {
+				row = append(row, cluster{text: Ellipsis, width: ew})
+				appended = true
+				continue
+			}
+			row = append(row, c)
+			width += c.width
+		}
+		lines[n] = row
+	}
+	return fromClusters(lines)
+}
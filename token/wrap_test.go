@@ -0,0 +1,166 @@
+package token
+
+import (
+	"testing"
+
+	"github.com/jiro4989/textimg/v3/color"
+	"github.com/jiro4989/textimg/v3/internal/width"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokens_Wrap(t *testing.T) {
+	tests := []struct {
+		desc         string
+		t            Tokens
+		columns      int
+		mode         string
+		want         Tokens
+		wantRowLines []int
+	}{
+		{
+			desc:         "正常系: 幅以内の場合は折り返さない",
+			t:            Tokens{NewText("hello\nworld")},
+			columns:      5,
+			mode:         WrapModeChar,
+			want:         Tokens{NewText("hello\nworld")},
+			wantRowLines: []int{0, 1},
+		},
+		{
+			desc:         "正常系: 文字単位で折り返す",
+			t:            Tokens{NewText("abcdefg\nhi")},
+			columns:      3,
+			mode:         WrapModeChar,
+			want:         Tokens{NewText("abc\ndef\ng\nhi")},
+			wantRowLines: []int{0, 0, 0, 1},
+		},
+		{
+			desc:         "正常系: 全角文字の途中では折り返さない",
+			t:            Tokens{NewText("aあいう")},
+			columns:      4,
+			mode:         WrapModeChar,
+			want:         Tokens{NewText("aあ\nいう")},
+			wantRowLines: []int{0, 0},
+		},
+		{
+			desc:         "正常系: 結合文字は直前の文字と一緒に折り返す",
+			t:            Tokens{NewText("abéf")},
+			columns:      2,
+			mode:         WrapModeChar,
+			want:         Tokens{NewText("ab\néf")},
+			wantRowLines: []int{0, 0},
+		},
+		{
+			desc:         "正常系: 国旗の絵文字は分割しない",
+			t:            Tokens{NewText("a🇯🇵b🇺🇸")},
+			columns:      2,
+			mode:         WrapModeChar,
+			want:         Tokens{NewText("a🇯🇵\nb🇺🇸")},
+			wantRowLines: []int{0, 0},
+		},
+		{
+			desc:         "正常系: 幅より広い文字はその文字だけの行にする",
+			t:            Tokens{NewText("aあbいう")},
+			columns:      1,
+			mode:         WrapModeChar,
+			want:         Tokens{NewText("a\nあ\nb\nい\nう")},
+			wantRowLines: []int{0, 0, 0, 0, 0},
+		},
+		{
+			desc:         "正常系: 単語単位で折り返す",
+			t:            Tokens{NewText("hello world foo")},
+			columns:      8,
+			mode:         WrapModeWord,
+			want:         Tokens{NewText("hello \nworld \nfoo")},
+			wantRowLines: []int{0, 0, 0},
+		},
+		{
+			desc:         "正常系: 幅を超える単語は文字単位で折り返す",
+			t:            Tokens{NewText("a abcdefgh")},
+			columns:      4,
+			mode:         WrapModeWord,
+			want:         Tokens{NewText("a \nabcd\nefgh")},
+			wantRowLines: []int{0, 0, 0},
+		},
+		{
+			desc: "正常系: 色指定は折り返した後も残る",
+			t: Tokens{
+				NewText("ab"),
+				{Kind: KindColor, ColorType: ColorTypeForeground, Color: color.RGBARed},
+				NewText("cdef"),
+			},
+			columns: 3,
+			mode:    WrapModeChar,
+			want: Tokens{
+				NewText("ab"),
+				{Kind: KindColor, ColorType: ColorTypeForeground, Color: color.RGBARed},
+				NewText("c\ndef"),
+			},
+			wantRowLines: []int{0, 0},
+		},
+		{
+			desc:         "正常系: noneの場合は折り返さない",
+			t:            Tokens{NewText("abcdefg")},
+			columns:      3,
+			mode:         WrapModeNone,
+			want:         Tokens{NewText("abcdefg")},
+			wantRowLines: []int{0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			got, rowLines := tt.t.Wrap(tt.columns, tt.mode, width.New(false))
+			assert.Equal(tt.want, got)
+			assert.Equal(tt.wantRowLines, rowLines)
+		})
+	}
+}
+
+func TestTokens_Truncate(t *testing.T) {
+	tests := []struct {
+		desc    string
+		t       Tokens
+		columns int
+		want    Tokens
+	}{
+		{
+			desc:    "正常系: 幅以内の場合は切り詰めない",
+			t:       Tokens{NewText("hello\nworld")},
+			columns: 5,
+			want:    Tokens{NewText("hello\nworld")},
+		},
+		{
+			desc:    "正常系: 幅を超える行を切り詰める",
+			t:       Tokens{NewText("abcdefg\nhi")},
+			columns: 4,
+			want:    Tokens{NewText("abc…\nhi")},
+		},
+		{
+			desc:    "正常系: 全角文字の途中では切り詰めない",
+			t:       Tokens{NewText("あいう")},
+			columns: 4,
+			want:    Tokens{NewText("あ…")},
+		},
+		{
+			desc: "正常系: 切り詰めた部分の色指定は残る",
+			t: Tokens{
+				NewText("abcdef"),
+				{Kind: KindColor, ColorType: ColorTypeForeground, Color: color.RGBARed},
+				NewText("g\nhi"),
+			},
+			columns: 3,
+			want: Tokens{
+				NewText("ab…"),
+				{Kind: KindColor, ColorType: ColorTypeForeground, Color: color.RGBARed},
+				NewText("\nhi"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got := tt.t.Truncate(tt.columns, width.New(false))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package webp
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// 符号長の最大値
+const (
+	maxCodeLength           = 15
+	maxCodeLengthCodeLength = 7
+)
+
+// 符号長を符号化するときの特別な記号
+const (
+	codeRepeatPrevious  = 16 // 直前の符号長を 3~6 回繰り返す
+	codeRepeatZeros     = 17 // 0 を 3~10 回繰り返す
+	codeRepeatManyZeros = 18 // 0 を 11~138 回繰り返す
+)
+
+// 符号長の符号の符号長を書き出す順番
+var codeLengthCodeOrder = []int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// huffmanCode は記号ごとのハフマン符号。
+type huffmanCode struct {
+	lengths []int    // 記号ごとの符号長
+	codes   []uint32 // 書き出す順にビットを反転した符号
+	// 使われている記号が1つの場合、符号は 0 ビットになる
+	single bool
+}
+
+// newHuffmanCode は出現回数 hist から符号長が maxLength 以下のハフマン符号を
+// 作る。
+func newHuffmanCode(hist []int, maxLength int) huffmanCode {
+	lengths := codeLengths(hist, maxLength)
+	var (
+		used   int
+		counts = make([]int, maxLength+1)
+	)
+	for _, l := range lengths {
+		if 0 < l {
+			used++
+			counts[l]++
+		}
+	}
+
+	// 正規ハフマン符号を割り当てる
+	next := make([]int, maxLength+1)
+	for l, code := 1, 0; l <= maxLength; l++ {
+		code = (code + counts[l-1]) << 1
+		next[l] = code
+	}
+	codes := make([]uint32, len(lengths))
+	for s, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		codes[s] = uint32(bits.Reverse16(uint16(next[l])) >> (16 - l))
+		next[l]++
+	}
+	return huffmanCode{lengths: lengths, codes: codes, single: used == 1}
+}
+
+// write は記号 s を書き出す。
+func (h huffmanCode) write(w *bitWriter, s int) {
+	if h.single {
+		return
+	}
+	w.write(h.codes[s], h.lengths[s])
+}
+
+// codeLengths は出現回数 hist から符号長を計算する。
+// 符号長が maxLength を超える場合は、少ない出現回数を底上げして計算しなおす。
+// 記号が1つも使われていない場合は、デコーダが符号を作れるように先頭の記号を
+// 使うことにする。
+func codeLengths(hist []int, maxLength int) []int {
+	lengths := make([]int, len(hist))
+	var symbols []int
+	for s, n := range hist {
+		if 0 < n {
+			symbols = append(symbols, s)
+		}
+	}
+	switch len(symbols) {
+	case 0:
+		lengths[0] = 1
+		return lengths
+	case 1:
+		lengths[symbols[0]] = 1
+		return lengths
+	}
+
+	for minCount := 1; ; minCount *= 2 {
+		type node struct {
+			count       int
+			symbol      int
+			left, right int
+		}
+		var nodes []node
+		for _, s := range symbols {
+			nodes = append(nodes, node{count: max(hist[s], minCount), symbol: s, left: -1, right: -1})
+		}
+		sort.SliceStable(nodes, func(a, b int) bool { return nodes[a].count < nodes[b].count })
+
+		// 葉と内部節点の2つのキューで木を作る
+		var (
+			leaves   = len(nodes)
+			li, qi   int
+			internal []int
+		)
+		pop := func() int {
+			if li < leaves && (len(internal) <= qi || nodes[li].count <= nodes[internal[qi]].count) {
+				li++
+				return li - 1
+			}
+			qi++
+			return internal[qi-1]
+		}
+		for j := 0; j < leaves-1; j++ {
+			a, b := pop(), pop()
+			nodes = append(nodes, node{count: nodes[a].count + nodes[b].count, symbol: -1, left: a, right: b})
+			internal = append(internal, len(nodes)-1)
+		}
+
+		tooLong := false
+		var walk func(n, depth int)
+		walk = func(n, depth int) {
+			if nodes[n].left < 0 {
+				lengths[nodes[n].symbol] = depth
+				tooLong = tooLong || maxLength < depth
+				return
+			}
+			walk(nodes[n].left, depth+1)
+			walk(nodes[n].right, depth+1)
+		}
+		walk(len(nodes)-1, 0)
+		if !tooLong {
+			return lengths
+		}
+	}
+}
+
+// writeHuffmanCode は符号長を通常の形式で書き出す。
+func writeHuffmanCode(w *bitWriter, h huffmanCode) {
+	// 符号長を連長圧縮した記号と、その追加ビット
+	type token struct {
+		symbol, extra, extraBits int
+	}
+	var (
+		tokens []token
+		hist   = make([]int, len(codeLengthCodeOrder))
+		prev   = -1
+	)
+	add := func(t token) {
+		tokens = append(tokens, t)
+		hist[t.symbol]++
+	}
+	lengths := h.lengths
+	for j := 0; j < len(lengths); {
+		l := lengths[j]
+		run := 1
+		for j+run < len(lengths) && lengths[j+run] == l {
+			run++
+		}
+		j += run
+
+		if l == 0 {
+			for 0 < run {
+				switch {
+				case 11 <= run:
+					n := min(run, 138)
+					add(token{codeRepeatManyZeros, n - 11, 7})
+					run -= n
+				case 3 <= run:
+					add(token{codeRepeatZeros, run - 3, 3})
+					run = 0
+				default:
+					add(token{0, 0, 0})
+					run--
+				}
+			}
+			continue
+		}
+
+		if l != prev {
+			add(token{l, 0, 0})
+			prev = l
+			run--
+		}
+		for 0 < run {
+			if run < 3 {
+				add(token{l, 0, 0})
+				run--
+				continue
+			}
+			n := min(run, 6)
+			add(token{codeRepeatPrevious, n - 3, 2})
+			run -= n
+		}
+	}
+
+	code := newHuffmanCode(hist, maxCodeLengthCodeLength)
+	n := len(codeLengthCodeOrder)
+	for 4 < n && code.lengths[codeLengthCodeOrder[n-1]] == 0 {
+		n--
+	}
+
+	w.write(0, 1) // 通常の形式
+	w.write(uint32(n-4), 4)
+	for _, s := range codeLengthCodeOrder[:n] {
+		w.write(uint32(code.lengths[s]), 3)
+	}
+	w.write(0, 1) // 全ての記号の符号長を書き出す
+	for _, t := range tokens {
+		code.write(w, t.symbol)
+		w.write(uint32(t.extra), t.extraBits)
+	}
+}
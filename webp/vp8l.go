@@ -0,0 +1,311 @@
+package webp
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+	"math/bits"
+)
+
+const (
+	vp8lMagic              = 0x2f
+	vp8lMaxSize            = 1 << 14
+	transformSubtractGreen = 2
+
+	// カラーキャッシュの大きさ (ビット数)
+	colorCacheBits       = 10
+	colorCacheMultiplier = 0x1e35a7bd
+
+	nLiteralCodes  = 256
+	nLengthCodes   = 24
+	nDistanceCodes = 40
+
+	// LZ77 の一致の長さと距離
+	minMatchLength = 3
+	maxMatchLength = 4096
+	maxDistance    = 1<<20 - 120
+	// ハッシュチェーンを辿る最大の回数
+	maxChainLength = 32
+	hashBits       = 16
+)
+
+// 2次元の距離の符号。添字+1 が符号になる。
+// 上位4ビットが y 方向の距離、下位4ビットが 8-x 方向の距離。
+var distanceMapTable = [120]uint8{
+	0x18, 0x07, 0x17, 0x19, 0x28, 0x06, 0x27, 0x29, 0x16, 0x1a,
+	0x26, 0x2a, 0x38, 0x05, 0x37, 0x39, 0x15, 0x1b, 0x36, 0x3a,
+	0x25, 0x2b, 0x48, 0x04, 0x47, 0x49, 0x14, 0x1c, 0x35, 0x3b,
+	0x46, 0x4a, 0x24, 0x2c, 0x58, 0x45, 0x4b, 0x34, 0x3c, 0x03,
+	0x57, 0x59, 0x13, 0x1d, 0x56, 0x5a, 0x23, 0x2d, 0x44, 0x4c,
+	0x55, 0x5b, 0x33, 0x3d, 0x68, 0x02, 0x67, 0x69, 0x12, 0x1e,
+	0x66, 0x6a, 0x22, 0x2e, 0x54, 0x5c, 0x43, 0x4d, 0x65, 0x6b,
+	0x32, 0x3e, 0x78, 0x01, 0x77, 0x79, 0x53, 0x5d, 0x11, 0x1f,
+	0x64, 0x6c, 0x42, 0x4e, 0x76, 0x7a, 0x21, 0x2f, 0x75, 0x7b,
+	0x31, 0x3f, 0x63, 0x6d, 0x52, 0x5e, 0x00, 0x74, 0x7c, 0x41,
+	0x4f, 0x10, 0x20, 0x62, 0x6e, 0x30, 0x73, 0x7d, 0x51, 0x5f,
+	0x40, 0x72, 0x7e, 0x61, 0x6f, 0x50, 0x71, 0x7f, 0x60, 0x70,
+}
+
+// symbol は LZ77 で圧縮した画素の並び。
+type symbol struct {
+	kind     symbolKind
+	argb     uint32 // 画素の値 (symbolLiteral の場合)
+	index    int    // カラーキャッシュの位置 (symbolCache の場合)
+	length   int    // 一致の長さ (symbolCopy の場合)
+	distance int    // 距離の符号 (symbolCopy の場合)
+}
+
+type symbolKind int
+
+const (
+	symbolLiteral symbolKind = iota
+	symbolCache
+	symbolCopy
+)
+
+// encodeVP8L は img を VP8L のビットストリームに変換する。
+// 戻り値の bool は半透明のピクセルが存在するかを表す。
+func encodeVP8L(img image.Image) ([]byte, bool, error) {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width < 1 || height < 1 || vp8lMaxSize < width || vp8lMaxSize < height {
+		return nil, false, errors.New("webp: invalid image size")
+	}
+
+	nrgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(nrgba, nrgba.Rect, img, b.Min, draw.Src)
+
+	var (
+		pix      = make([]uint32, width*height)
+		hasAlpha bool
+	)
+	for j := range pix {
+		p := nrgba.Pix[4*j : 4*j+4]
+		r, g, bl, a := uint32(p[0]), uint32(p[1]), uint32(p[2]), uint32(p[3])
+		hasAlpha = hasAlpha || a != 0xff
+		// 緑の成分を引く変換をする
+		r = (r - g) & 0xff
+		bl = (bl - g) & 0xff
+		pix[j] = a<<24 | r<<16 | g<<8 | bl
+	}
+
+	symbols := backwardReferences(pix, width)
+
+	var (
+		cacheSize = 1 << colorCacheBits
+		greenHist = make([]int, nLiteralCodes+nLengthCodes+cacheSize)
+		redHist   = make([]int, 256)
+		blueHist  = make([]int, 256)
+		alphaHist = make([]int, 256)
+		distHist  = make([]int, nDistanceCodes)
+	)
+	for _, s := range symbols {
+		switch s.kind {
+		case symbolLiteral:
+			greenHist[s.argb>>8&0xff]++
+			redHist[s.argb>>16&0xff]++
+			blueHist[s.argb&0xff]++
+			alphaHist[s.argb>>24]++
+		case symbolCache:
+			greenHist[nLiteralCodes+nLengthCodes+s.index]++
+		case symbolCopy:
+			code, _, _ := prefixEncode(s.length)
+			greenHist[nLiteralCodes+code]++
+			code, _, _ = prefixEncode(s.distance)
+			distHist[code]++
+		}
+	}
+	var (
+		green = newHuffmanCode(greenHist, maxCodeLength)
+		red   = newHuffmanCode(redHist, maxCodeLength)
+		blue  = newHuffmanCode(blueHist, maxCodeLength)
+		alpha = newHuffmanCode(alphaHist, maxCodeLength)
+		dist  = newHuffmanCode(distHist, maxCodeLength)
+	)
+
+	w := &bitWriter{}
+	w.write(vp8lMagic, 8)
+	w.write(uint32(width-1), 14)
+	w.write(uint32(height-1), 14)
+	if hasAlpha {
+		w.write(1, 1)
+	} else {
+		w.write(0, 1)
+	}
+	w.write(0, 3) // バージョン
+
+	w.write(1, 1)
+	w.write(transformSubtractGreen, 2)
+	w.write(0, 1) // 変換の終わり
+
+	w.write(1, 1)
+	w.write(colorCacheBits, 4)
+	w.write(0, 1) // メタ符号は使わない
+
+	for _, h := range []huffmanCode{green, red, blue, alpha, dist} {
+		writeHuffmanCode(w, h)
+	}
+
+	for _, s := range symbols {
+		switch s.kind {
+		case symbolLiteral:
+			green.write(w, int(s.argb>>8&0xff))
+			red.write(w, int(s.argb>>16&0xff))
+			blue.write(w, int(s.argb&0xff))
+			alpha.write(w, int(s.argb>>24))
+		case symbolCache:
+			green.write(w, nLiteralCodes+nLengthCodes+s.index)
+		case symbolCopy:
+			code, n, extra := prefixEncode(s.length)
+			green.write(w, nLiteralCodes+code)
+			w.write(uint32(extra), n)
+			code, n, extra = prefixEncode(s.distance)
+			dist.write(w, code)
+			w.write(uint32(extra), n)
+		}
+	}
+	return w.bytes(), hasAlpha, nil
+}
+
+// backwardReferences は pix を LZ77 とカラーキャッシュで圧縮した記号に変換する。
+func backwardReferences(pix []uint32, width int) []symbol {
+	var (
+		distanceCodes = planeCodes(width)
+		cache         = make([]uint32, 1<<colorCacheBits)
+		cacheValid    = make([]bool, len(cache))
+		head          = make([]int32, 1<<hashBits)
+		prev          = make([]int32, len(pix))
+		symbols       []symbol
+	)
+	for j := range head {
+		head[j] = -1
+	}
+	hash := func(p int) int {
+		h := pix[p]*colorCacheMultiplier ^ pix[p+1]*0x9e3779b1
+		return int(h >> (32 - hashBits))
+	}
+	insert := func(p int) {
+		if len(pix) <= p+1 {
+			return
+		}
+		h := hash(p)
+		prev[p] = head[h]
+		head[h] = int32(p)
+	}
+	matchLength := func(p, q int) int {
+		n := 0
+		for p+n < len(pix) && n < maxMatchLength && pix[p+n] == pix[q+n] {
+			n++
+		}
+		return n
+	}
+
+	for p := 0; p < len(pix); {
+		// 直前と真上の画素は候補から漏れないように先に調べる
+		bestLength, bestDistance := 0, 0
+		for _, d := range []int{1, width} {
+			if 0 < d && d <= p {
+				if n := matchLength(p, p-d); bestLength < n {
+					bestLength, bestDistance = n, d
+				}
+			}
+		}
+		if p+1 < len(pix) {
+			q := int(head[hash(p)])
+			for chain := 0; 0 <= q && chain < maxChainLength; chain++ {
+				d := p - q
+				if maxDistance < d {
+					break
+				}
+				if n := matchLength(p, q); bestLength < n {
+					bestLength, bestDistance = n, d
+				}
+				q = int(prev[q])
+			}
+		}
+
+		n := 1
+		if minMatchLength <= bestLength {
+			code, ok := distanceCodes[bestDistance]
+			if !ok {
+				code = bestDistance + len(distanceMapTable)
+			}
+			symbols = append(symbols, symbol{kind: symbolCopy, length: bestLength, distance: code})
+			n = bestLength
+		} else {
+			argb := pix[p]
+			k := int((argb * colorCacheMultiplier) >> (32 - colorCacheBits))
+			if cacheValid[k] && cache[k] == argb {
+				symbols = append(symbols, symbol{kind: symbolCache, index: k})
+			} else {
+				symbols = append(symbols, symbol{kind: symbolLiteral, argb: argb})
+			}
+		}
+
+		// デコーダと同じように、全ての画素をカラーキャッシュに入れる
+		for end := p + n; p < end; p++ {
+			argb := pix[p]
+			k := int((argb * colorCacheMultiplier) >> (32 - colorCacheBits))
+			cache[k], cacheValid[k] = argb, true
+			insert(p)
+		}
+	}
+	return symbols
+}
+
+// planeCodes は幅が width の画像で、2次元の距離の符号で表現できる距離と、
+// その符号の対応を返す。
+func planeCodes(width int) map[int]int {
+	m := make(map[int]int, len(distanceMapTable))
+	for j, v := range distanceMapTable {
+		var (
+			yOffset = int(v >> 4)
+			xOffset = 8 - int(v&0xf)
+			d       = yOffset*width + xOffset
+		)
+		if d < 1 {
+			d = 1
+		}
+		if _, ok := m[d]; !ok {
+			m[d] = j + 1
+		}
+	}
+	return m
+}
+
+// prefixEncode は LZ77 の長さや距離の値 v を、記号と追加ビットに変換する。
+func prefixEncode(v int) (code, extraBits, extra int) {
+	d := v - 1
+	if d < 4 {
+		return d, 0, 0
+	}
+	h := bits.Len(uint(d)) - 1
+	second := d >> (h - 1) & 1
+	return 2*h + second, h - 1, d & (1<<(h-1) - 1)
+}
+
+// bitWriter は下位ビットから順にビットを書き出す。
+type bitWriter struct {
+	buf   []byte
+	bits  uint64
+	nBits int
+}
+
+func (w *bitWriter) write(v uint32, n int) {
+	w.bits |= uint64(v) << w.nBits
+	w.nBits += n
+	for 8 <= w.nBits {
+		w.buf = append(w.buf, byte(w.bits))
+		w.bits >>= 8
+		w.nBits -= 8
+	}
+}
+
+// bytes は書き出したビット列を返す。端数のビットは 0 で埋める。
+func (w *bitWriter) bytes() []byte {
+	if 0 < w.nBits {
+		w.buf = append(w.buf, byte(w.bits))
+		w.bits, w.nBits = 0, 0
+	}
+	return w.buf
+}
@@ -0,0 +1,128 @@
+// Package webp は画像を可逆圧縮 (VP8L) の WebP 形式で出力する。
+// 静止画に加えて、フレームごとに表示時間を指定したアニメーションも出力できる。
+package webp
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+)
+
+// VP8X チャンクのフラグ
+const (
+	flagAnimation = 0x02
+	flagAlpha     = 0x10
+)
+
+// ANMF チャンクのフラグ
+const (
+	// 前のフレームと合成せず、フレームの領域を上書きする
+	flagNoBlend = 0x02
+)
+
+// 24ビットで表現できる最大値
+const maxUint24 = 1<<24 - 1
+
+var errNoFrames = errors.New("webp: no frames")
+
+// Animation はアニメーション WebP の各フレームと表示時間。
+// 各フレームは画像の左上に配置する。
+type Animation struct {
+	Image     []image.Image
+	Delay     []int // 各フレームの表示時間 (ミリ秒)
+	LoopCount int   // ループ回数。0 の場合は無限にループする
+}
+
+// Encode は img を可逆圧縮の WebP として w に出力する。
+func Encode(w io.Writer, img image.Image) error {
+	data, _, err := encodeVP8L(img)
+	if err != nil {
+		return err
+	}
+	var body []byte
+	body = appendChunk(body, "VP8L", data)
+	return writeRIFF(w, body)
+}
+
+// EncodeAll は a をアニメーション WebP として w に出力する。
+func EncodeAll(w io.Writer, a *Animation) error {
+	if len(a.Image) < 1 {
+		return errNoFrames
+	}
+	if len(a.Image) != len(a.Delay) {
+		return errors.New("webp: mismatched image and delay lengths")
+	}
+
+	var (
+		frames        []byte
+		flags         byte = flagAnimation
+		width, height int
+	)
+	for j, img := range a.Image {
+		data, hasAlpha, err := encodeVP8L(img)
+		if err != nil {
+			return err
+		}
+		if hasAlpha {
+			flags |= flagAlpha
+		}
+
+		b := img.Bounds()
+		width = max(width, b.Dx())
+		height = max(height, b.Dy())
+
+		// フレームの位置は左上で固定する
+		header := make([]byte, 16)
+		putUint24(header[6:], uint32(b.Dx()-1))
+		putUint24(header[9:], uint32(b.Dy()-1))
+		putUint24(header[12:], uint32(min(max(a.Delay[j], 0), maxUint24)))
+		header[15] = flagNoBlend
+		frames = appendChunk(frames, "ANMF", appendChunk(header, "VP8L", data))
+	}
+
+	vp8x := make([]byte, 10)
+	vp8x[0] = flags
+	putUint24(vp8x[4:], uint32(width-1))
+	putUint24(vp8x[7:], uint32(height-1))
+
+	// 背景色は透明にする
+	anim := make([]byte, 6)
+	binary.LittleEndian.PutUint16(anim[4:], uint16(min(max(a.LoopCount, 0), 0xffff)))
+
+	var body []byte
+	body = appendChunk(body, "VP8X", vp8x)
+	body = appendChunk(body, "ANIM", anim)
+	body = append(body, frames...)
+	return writeRIFF(w, body)
+}
+
+func writeRIFF(w io.Writer, body []byte) error {
+	header := make([]byte, 12)
+	copy(header, "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(4+len(body)))
+	copy(header[8:], "WEBP")
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(body)
+	return err
+}
+
+// appendChunk は tag のチャンクを dst に追加する。
+// チャンクの長さが奇数の場合は末尾を 0 で埋める。
+func appendChunk(dst []byte, tag string, data []byte) []byte {
+	dst = append(dst, tag...)
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(len(data)))
+	dst = append(dst, data...)
+	if len(data)%2 != 0 {
+		dst = append(dst, 0)
+	}
+	return dst
+}
+
+func putUint24(b []byte, v uint32) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+}
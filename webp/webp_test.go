@@ -0,0 +1,220 @@
+package webp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	xwebp "golang.org/x/image/webp"
+)
+
+func newImage(w, h int, f func(x, y int) color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, f(x, y))
+		}
+	}
+	return img
+}
+
+// assertSameImage は want と got の全てのピクセルが一致するかを検証する。
+func assertSameImage(t *testing.T, want image.Image, got image.Image) {
+	b := want.Bounds()
+	assert.Equal(t, b.Size(), got.Bounds().Size())
+	g := image.NewNRGBA(got.Bounds())
+	draw.Draw(g, g.Rect, got, got.Bounds().Min, draw.Src)
+	w := image.NewNRGBA(b)
+	draw.Draw(w, w.Rect, want, b.Min, draw.Src)
+	assert.Equal(t, w.Pix, g.Pix)
+}
+
+type chunk struct {
+	tag  string
+	data []byte
+}
+
+func readChunks(t *testing.T, data []byte) (ret []chunk) {
+	for 8 <= len(data) {
+		n := int(binary.LittleEndian.Uint32(data[4:]))
+		ret = append(ret, chunk{tag: string(data[:4]), data: data[8 : 8+n]})
+		data = data[8+n+n%2:]
+	}
+	assert.Empty(t, data)
+	return
+}
+
+func TestEncode(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	tests := []struct {
+		desc string
+		img  image.Image
+	}{
+		{
+			desc: "正常系: 1ピクセル",
+			img:  newImage(1, 1, func(x, y int) color.NRGBA { return color.NRGBA{R: 255, A: 255} }),
+		},
+		{
+			desc: "正常系: 単色",
+			img:  newImage(40, 30, func(x, y int) color.NRGBA { return color.NRGBA{R: 1, G: 2, B: 3, A: 255} }),
+		},
+		{
+			desc: "正常系: 文字のような少ない色の画像",
+			img: newImage(64, 48, func(x, y int) color.NRGBA {
+				if (x/3+y/5)%4 == 0 {
+					return color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+				}
+				return color.NRGBA{A: 255}
+			}),
+		},
+		{
+			desc: "正常系: グラデーション",
+			img: newImage(300, 20, func(x, y int) color.NRGBA {
+				return color.NRGBA{R: uint8(x), G: uint8(y * 10), B: uint8(x + y), A: 255}
+			}),
+		},
+		{
+			desc: "正常系: ランダムな半透明の画像",
+			img: newImage(50, 50, func(x, y int) color.NRGBA {
+				return color.NRGBA{R: uint8(rnd.Intn(256)), G: uint8(rnd.Intn(256)), B: uint8(rnd.Intn(256)), A: uint8(rnd.Intn(256))}
+			}),
+		},
+		{
+			desc: "正常系: 原点が0でない画像",
+			img: newImage(20, 20, func(x, y int) color.NRGBA {
+				return color.NRGBA{G: uint8(x * y), A: 255}
+			}).SubImage(image.Rect(5, 3, 17, 20)),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			var buf bytes.Buffer
+			err := Encode(&buf, tt.img)
+			assert.NoError(err)
+
+			got, err := xwebp.Decode(&buf)
+			assert.NoError(err)
+			assertSameImage(t, tt.img, got)
+		})
+	}
+}
+
+func TestEncodeAll(t *testing.T) {
+	frames := []image.Image{
+		newImage(10, 8, func(x, y int) color.NRGBA { return color.NRGBA{R: uint8(x * 20), A: 255} }),
+		newImage(10, 8, func(x, y int) color.NRGBA { return color.NRGBA{B: uint8(y * 20), A: uint8(x * 20)} }),
+	}
+	tests := []struct {
+		desc      string
+		anim      *Animation
+		wantFlags byte
+		wantErr   bool
+	}{
+		{
+			desc:      "正常系: 半透明のフレームを含む",
+			anim:      &Animation{Image: frames, Delay: []int{100, 250}, LoopCount: 3},
+			wantFlags: flagAnimation | flagAlpha,
+		},
+		{
+			desc:      "正常系: 不透明なフレームだけ",
+			anim:      &Animation{Image: frames[:1], Delay: []int{100}},
+			wantFlags: flagAnimation,
+		},
+		{
+			desc:    "異常系: フレームがない",
+			anim:    &Animation{},
+			wantErr: true,
+		},
+		{
+			desc:    "異常系: フレームと表示時間の数が違う",
+			anim:    &Animation{Image: frames, Delay: []int{100}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			var buf bytes.Buffer
+			err := EncodeAll(&buf, tt.anim)
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+
+			data := buf.Bytes()
+			assert.Equal("RIFF", string(data[:4]))
+			assert.Equal(len(data)-8, int(binary.LittleEndian.Uint32(data[4:])))
+			assert.Equal("WEBP", string(data[8:12]))
+
+			chunks := readChunks(t, data[12:])
+			assert.Equal("VP8X", chunks[0].tag)
+			assert.Equal(tt.wantFlags, chunks[0].data[0])
+			assert.Equal("ANIM", chunks[1].tag)
+			assert.Equal(uint16(tt.anim.LoopCount), binary.LittleEndian.Uint16(chunks[1].data[4:]))
+
+			frames := chunks[2:]
+			assert.Len(frames, len(tt.anim.Image))
+			for j, f := range frames {
+				assert.Equal("ANMF", f.tag)
+				delay := int(f.data[12]) | int(f.data[13])<<8 | int(f.data[14])<<16
+				assert.Equal(tt.anim.Delay[j], delay)
+
+				// フレームの画像だけの WebP にしてデコードする
+				var still bytes.Buffer
+				assert.NoError(writeRIFF(&still, f.data[16:]))
+				got, err := xwebp.Decode(&still)
+				assert.NoError(err)
+				assertSameImage(t, tt.anim.Image[j], got)
+			}
+		})
+	}
+}
+
+func TestCodeLengths(t *testing.T) {
+	// フィボナッチ数列の出現回数は符号長が最も長くなる
+	fib := []int{1, 1}
+	for len(fib) < 30 {
+		fib = append(fib, fib[len(fib)-1]+fib[len(fib)-2])
+	}
+	tests := []struct {
+		desc      string
+		hist      []int
+		maxLength int
+	}{
+		{desc: "正常系: 記号が1つもない", hist: []int{0, 0, 0}, maxLength: 15},
+		{desc: "正常系: 記号が1つだけ", hist: []int{0, 5, 0}, maxLength: 15},
+		{desc: "正常系: 符号長を制限する", hist: fib, maxLength: 15},
+		{desc: "正常系: 符号長の符号の制限", hist: fib[:19], maxLength: 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert := assert.New(t)
+
+			var (
+				lengths = codeLengths(tt.hist, tt.maxLength)
+				used    int
+				kraft   float64
+			)
+			for _, l := range lengths {
+				assert.LessOrEqual(l, tt.maxLength)
+				if 0 < l {
+					used++
+					kraft += 1 / float64(int(1)<<l)
+				}
+			}
+			assert.LessOrEqual(1, used)
+			if 1 < used {
+				assert.Equal(1.0, kraft)
+			}
+		})
+	}
+}